@@ -21,11 +21,30 @@ func (es ArrayRankUnitSlice) Less(i, j int) bool {
 	return es[i].Key > es[j].Key
 }
 
+// 按照排行榜配置的排序方向和并列规则排序
+type arrayRankUnitSorter struct {
+	ArrayRankUnitSlice
+	config RankEngineConfig
+}
+
+func (s arrayRankUnitSorter) Less(i, j int) bool {
+	return s.config.Before(RankUnit(s.ArrayRankUnitSlice[i]),
+		RankUnit(s.ArrayRankUnitSlice[j]))
+}
+
 type ArrayRankEngine struct {
 	config           RankEngineConfig
 	data             ArrayRankUnitSlice
+	nextSeq          uint64
 	lastClearTime    time.Time
 	lastSnapshotTime time.Time
+	groups           keyGroups
+}
+
+func init() {
+	RegisterRankEngine(EngineKindArray, func(config RankEngineConfig) RankEngine {
+		return NewArrayRankEngine(config)
+	})
 }
 
 func NewArrayRankEngine(config RankEngineConfig) *ArrayRankEngine {
@@ -36,6 +55,15 @@ func (e *ArrayRankEngine) Config() RankEngineConfig {
 	return e.config
 }
 
+func (e *ArrayRankEngine) SetConfig(config RankEngineConfig) error {
+	if err := ConfigChangeError(e.config, config); err != nil {
+		return err
+	}
+	e.config = config
+	e.evict()
+	return nil
+}
+
 func (e *ArrayRankEngine) Size() uint32 {
 	return uint32(e.data.Len())
 }
@@ -62,7 +90,7 @@ func (e *ArrayRankEngine) GetRange(pos, num uint32) []RankUnit {
 		return nil
 	}
 	n := num
-	if pos+num >= e.Size() {
+	if num >= e.Size()-pos {
 		n = e.Size() - pos
 	}
 	result := make([]RankUnit, n)
@@ -72,34 +100,123 @@ func (e *ArrayRankEngine) GetRange(pos, num uint32) []RankUnit {
 	return result
 }
 
-func (e *ArrayRankEngine) Update(u RankUnit) (bool, uint32, RankUnit) {
-	aru := ArrayRankUnit(u)
+func (e *ArrayRankEngine) GetAround(id uint64,
+	before, after uint32) (bool, uint32, uint32, []RankUnit) {
+	return getAround(e, id, before, after)
+}
+
+func (e *ArrayRankEngine) RankOfKey(key uint64) uint32 {
+	return uint32(sort.Search(e.data.Len(), func(i int) bool {
+		return !e.config.SortOrder.Before(e.data[i].Key, key)
+	}))
+}
+
+func (e *ArrayRankEngine) GetByKeyRange(min, max uint64,
+	limit uint32) (uint32, []RankUnit) {
+	return getByKeyRange(e, min, max, limit)
+}
+
+func (e *ArrayRankEngine) DistinctKeysBefore(pos uint32) uint32 {
+	if !e.groups.valid {
+		for i := range e.data {
+			e.groups.add(uint32(i), e.data[i].Key)
+		}
+		e.groups.valid = true
+	}
+	return e.groups.before(pos)
+}
+
+func (e *ArrayRankEngine) Update(u RankUnit,
+	mode UpdateMode) (bool, uint32, RankUnit, bool) {
 	exist, index, old := e.Get(u.ID)
+	u, ok := mode.Apply(exist, old, u)
+	if !ok {
+		return exist, index, old, false
+	}
+	changed := !exist || old.Key != u.Key
+	if !changed {
+		u.Seq = old.Seq
+	} else {
+		u.Seq = e.nextSeq
+		e.nextSeq++
+	}
+	aru := ArrayRankUnit(u)
 	if exist {
 		e.data[index] = aru
-	} else if e.config.MaxSize != 0 && e.Size() >= e.config.MaxSize {
-		// 已经达到最大上限, 淘汰最后一个
-		last := e.Size() - 1
-		e.data[last] = aru
 	} else {
 		e.data = append(e.data, aru)
 	}
-	sort.Stable(e.data)
-	return exist, index, old
+	e.sort()
+	e.evict()
+	return exist, index, old, changed
+}
+
+func (e *ArrayRankEngine) UpdateMany(units []RankUnit,
+	mode UpdateMode) []bool {
+	changed := make([]bool, len(units))
+	indexes := make(map[uint64]int, e.data.Len())
+	for i := range e.data {
+		indexes[e.data[i].ID] = i
+	}
+	for i, u := range units {
+		index, exist := indexes[u.ID]
+		var old RankUnit
+		if exist {
+			old = RankUnit(e.data[index])
+		}
+		u, ok := mode.Apply(exist, old, u)
+		if !ok {
+			continue
+		}
+		if exist && old.Key == u.Key {
+			u.Seq = old.Seq
+			e.data[index] = ArrayRankUnit(u)
+			continue
+		}
+		u.Seq = e.nextSeq
+		e.nextSeq++
+		changed[i] = true
+		if exist {
+			e.data[index] = ArrayRankUnit(u)
+		} else {
+			indexes[u.ID] = e.data.Len()
+			e.data = append(e.data, ArrayRankUnit(u))
+		}
+	}
+	e.sort()
+	e.evict()
+	return changed
+}
+
+// 超过最大上限时淘汰排在最后的数据, 可能就是刚上报的数据
+func (e *ArrayRankEngine) evict() {
+	e.groups.reset()
+	if e.config.MaxSize != 0 && e.Size() > e.config.MaxSize {
+		e.data = e.data[:e.config.MaxSize]
+	}
+}
+
+func (e *ArrayRankEngine) sort() {
+	e.groups.reset()
+	sort.Stable(arrayRankUnitSorter{e.data, e.config})
 }
 
 func (e *ArrayRankEngine) Delete(id uint64) (bool, uint32, RankUnit) {
 	exist, pos, u := e.Get(id)
 	if exist {
 		e.data = append(e.data[:pos], e.data[pos+1:]...)
+		e.groups.reset()
 	}
 	return exist, pos, u
 }
 
 func (e *ArrayRankEngine) CreateSnapshot() RankEngine {
 	snapshot := &ArrayRankEngine{
-		config: e.config,
-		data:   make(ArrayRankUnitSlice, len(e.data)),
+		config:           e.config,
+		data:             make(ArrayRankUnitSlice, len(e.data)),
+		nextSeq:          e.nextSeq,
+		lastClearTime:    e.lastClearTime,
+		lastSnapshotTime: e.lastSnapshotTime,
 	}
 	for i := 0; i < len(e.data); i++ {
 		buffer := bytes.NewBuffer(e.data[i].Value)
@@ -107,6 +224,7 @@ func (e *ArrayRankEngine) CreateSnapshot() RankEngine {
 			ID:    e.data[i].ID,
 			Key:   e.data[i].Key,
 			Value: buffer.Bytes(),
+			Seq:   e.data[i].Seq,
 		}
 	}
 	return snapshot
@@ -114,6 +232,7 @@ func (e *ArrayRankEngine) CreateSnapshot() RankEngine {
 
 func (e *ArrayRankEngine) Clear() {
 	e.data = nil
+	e.groups.reset()
 }
 
 func (e *ArrayRankEngine) CopyFrom(rank RankEngine) {
@@ -121,9 +240,47 @@ func (e *ArrayRankEngine) CopyFrom(rank RankEngine) {
 	e.Clear()
 	e.SetLastClearTime(rank.LastClearTime())
 	e.SetLastSnapshotTime(rank.LastSnapshotTime())
+	// 保留原有的更新序号, 使并列数据的先后顺序与原排行榜一致
 	for i := 0; i < len(units); i++ {
-		e.Update(units[i])
+		e.data = append(e.data, ArrayRankUnit(units[i]))
+		if units[i].Seq >= e.nextSeq {
+			e.nextSeq = units[i].Seq + 1
+		}
+	}
+	e.sort()
+	e.evict()
+}
+
+func (e *ArrayRankEngine) MarshalBinary() ([]byte, error) {
+	state := rankEngineState{
+		kind:             e.config.EngineKind(),
+		fingerprint:      e.config.Fingerprint(),
+		lastClearTime:    e.lastClearTime,
+		lastSnapshotTime: e.lastSnapshotTime,
+		nextSeq:          e.nextSeq,
+		units:            make([]RankUnit, len(e.data)),
+	}
+	for i := range e.data {
+		state.units[i] = RankUnit(e.data[i])
+	}
+	return marshalRankEngineState(state)
+}
+
+func (e *ArrayRankEngine) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalRankEngineState(data, e.config)
+	if err != nil {
+		return err
+	}
+	e.data = make(ArrayRankUnitSlice, len(state.units))
+	for i := range state.units {
+		e.data[i] = ArrayRankUnit(state.units[i])
 	}
+	e.nextSeq = state.nextSeq
+	e.lastClearTime = state.lastClearTime
+	e.lastSnapshotTime = state.lastSnapshotTime
+	e.sort()
+	e.evict()
+	return nil
 }
 
 func (e *ArrayRankEngine) LastClearTime() time.Time {
@@ -8,8 +8,8 @@ func TestArrayEngineGet(t *testing.T) {
 	e := NewArrayRankEngine(RankEngineConfig{MaxSize: 10})
 	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
-	e.Update(u)
-	e.Update(u2)
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
 
 	exist, pos, out := e.Get(u.ID)
 	if !exist {
@@ -43,8 +43,8 @@ func TestArrayEngineGetByRank(t *testing.T) {
 
 	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
-	e.Update(u)
-	e.Update(u2)
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
 	exist, out = e.GetByRank(0)
 	if !exist {
 		t.Error("Rank 0 not found")
@@ -75,17 +75,17 @@ func TestArrayEngineGetByRank(t *testing.T) {
 func TestArrayEngineUpdate(t *testing.T) {
 	e := NewArrayRankEngine(RankEngineConfig{MaxSize: 10})
 	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
-	exist, pos, out := e.Update(u)
+	exist, pos, out, _ := e.Update(u, UpdateModeReplace)
 	if exist {
 		t.Errorf("Found same ID: %d", u.ID)
 	}
 	u2 := RankUnit{ID: 1024, Key: 12, Value: []byte("McCree")}
-	exist, pos, out = e.Update(u2)
+	exist, pos, out, _ = e.Update(u2, UpdateModeReplace)
 	if !exist {
 		t.Errorf("ID %d not found", u2.ID)
 	}
 	if pos != 0 {
-		t.Errorf("Expect pos 0, got: ", pos)
+		t.Errorf("Expect pos 0, got: %d", pos)
 	}
 	if err := checkUnitEqual(u, out); err != nil {
 		t.Error(err)
@@ -104,9 +104,9 @@ func TestArrayEngineDelete(t *testing.T) {
 	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
-	e.Update(u)
-	e.Update(u2)
-	e.Update(u3)
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	exist, pos, out := e.Delete(1000)
 	if exist {
@@ -143,9 +143,9 @@ func TestArrayEngineGetRange(t *testing.T) {
 	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
-	e.Update(u)
-	e.Update(u2)
-	e.Update(u3)
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	units := e.GetRange(0, 1)
 	if len(units) != 1 {
@@ -157,7 +157,7 @@ func TestArrayEngineGetRange(t *testing.T) {
 
 	units = e.GetRange(0, e.Size())
 	if len(units) != int(e.Size()) {
-		t.Error("Expect %d units, got: %d", e.Size(), len(units))
+		t.Errorf("Expect %d units, got: %d", e.Size(), len(units))
 	}
 
 	units = e.GetRange(e.Size()+1, 1)
@@ -167,7 +167,7 @@ func TestArrayEngineGetRange(t *testing.T) {
 
 	units = e.GetRange(0, e.Size()+100)
 	if len(units) != int(e.Size()) {
-		t.Error("Expect %d units, got: %d", e.Size(), len(units))
+		t.Errorf("Expect %d units, got: %d", e.Size(), len(units))
 	}
 }
 
@@ -176,12 +176,12 @@ func TestArrayEngineSize(t *testing.T) {
 	u := RankUnit{ID: 1024, Key: 10}
 	u2 := RankUnit{ID: 1025, Key: 10}
 	u3 := RankUnit{ID: 1026, Key: 20}
-	e.Update(u2)
-	e.Update(u)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u, UpdateModeReplace)
 	if e.Size() != 2 {
 		t.Error("Expect 2, got ", e.Size())
 	}
-	e.Update(u3)
+	e.Update(u3, UpdateModeReplace)
 	if e.Size() != 3 {
 		t.Error("Expect 3, got ", e.Size())
 	}
@@ -192,9 +192,9 @@ func TestArrayEngineMaxSize(t *testing.T) {
 	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
-	e.Update(u)
-	e.Update(u2)
-	e.Update(u3)
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	if e.Size() != 2 {
 		t.Errorf("Expect size 2, got: %d", e.Size())
@@ -220,14 +220,14 @@ func TestArrayEngineSnapshot(t *testing.T) {
 	snapshot := e.CreateSnapshot()
 	s1, _ := snapshot.(*ArrayRankEngine)
 	if s1.Size() != 0 {
-		t.Error("Expect empty size, got %d", s1.Size())
+		t.Errorf("Expect empty size, got %d", s1.Size())
 	}
-	e.Update(u)
-	e.Update(u2)
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
 	snapshot = e.CreateSnapshot()
 	s2, _ := snapshot.(*ArrayRankEngine)
 	if s2.Size() != 2 {
-		t.Error("Expect size 2, got %d", s2.Size())
+		t.Errorf("Expect size 2, got %d", s2.Size())
 	}
 	_, _, v := s2.Get(u2.ID)
 	if err := checkUnitEqual(v, u2); err != nil {
@@ -237,8 +237,8 @@ func TestArrayEngineSnapshot(t *testing.T) {
 	if s1.Size() != 0 {
 		t.Error("Snapshot changed")
 	}
-	e.Update(u3)
-	e.Update(u4)
+	e.Update(u3, UpdateModeReplace)
+	e.Update(u4, UpdateModeReplace)
 
 	_, _, v = s2.Get(u2.ID)
 	if err := checkUnitEqual(v, u2); err != nil {
@@ -256,12 +256,48 @@ func TestArrayEngineClear(t *testing.T) {
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
 
-	e.Update(u)
-	e.Update(u2)
-	e.Update(u3)
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	e.Clear()
 	if e.Size() != 0 {
 		t.Errorf("Expect empty rank, got: %d", e.Size())
 	}
 }
+
+func TestArrayEngineAscending(t *testing.T) {
+	e := NewArrayRankEngine(RankEngineConfig{MaxSize: 2,
+		SortOrder: SortOrderAscending})
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
+	u4 := RankUnit{ID: 1027, Key: 8, Value: []byte("D.Va")}
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u, UpdateModeReplace)
+
+	_, out := e.GetByRank(0)
+	if err := checkUnitEqual(u, out); err != nil {
+		t.Error(err)
+	}
+
+	// 最大值u3是最差的数据, 不会挤掉已有的数据
+	e.Update(u3, UpdateModeReplace)
+	exist, _, _ := e.Get(u3.ID)
+	if exist {
+		t.Errorf("Expect ID %d not exist", u3.ID)
+	}
+
+	// 最小值u4排在第一, 挤掉u2
+	e.Update(u4, UpdateModeReplace)
+	if e.Size() != 2 {
+		t.Fatalf("Expect size 2, got: %d", e.Size())
+	}
+	units := e.GetRange(0, e.Size())
+	if err := checkUnitEqual(u4, units[0]); err != nil {
+		t.Error(err)
+	}
+	if err := checkUnitEqual(u, units[1]); err != nil {
+		t.Error(err)
+	}
+}
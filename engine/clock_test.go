@@ -0,0 +1,53 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2017, 3, 23, 17, 18, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(time.Millisecond * 999)
+	select {
+	case <-ticker.C():
+		t.Error("Ticker fired before interval")
+	default:
+	}
+
+	clock.Advance(time.Millisecond * 2500)
+	select {
+	case tick := <-ticker.C():
+		if expect := start.Add(time.Second * 3); !tick.Equal(expect) {
+			t.Errorf("Expect tick %s, got: %s", expect, tick)
+		}
+	default:
+		t.Error("Ticker not fired")
+	}
+	if expect := start.Add(time.Millisecond * 3499); !clock.Now().Equal(expect) {
+		t.Errorf("Expect now %s, got: %s", expect, clock.Now())
+	}
+
+	ticker.Stop()
+	clock.Advance(time.Hour)
+	select {
+	case <-ticker.C():
+		t.Error("Stopped ticker fired")
+	default:
+	}
+}
+
+func TestTimePeriodNextTimeFromNow(t *testing.T) {
+	start := time.Date(2017, 3, 23, 17, 18, 0, 0, time.UTC)
+	tp := TimePeriod{Start: start, Interval: time.Hour}
+	clock := NewFakeClock(start.Add(time.Minute))
+	if next := tp.NextTimeFromNow(clock); !next.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expect %s, got: %s", start.Add(time.Hour), next)
+	}
+	clock.Advance(time.Hour)
+	if next := tp.NextTimeFromNow(clock); !next.Equal(start.Add(time.Hour * 2)) {
+		t.Errorf("Expect %s, got: %s", start.Add(time.Hour*2), next)
+	}
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"time"
+
+	"github.com/jacobwpeng/goutil"
+)
+
+// 排行榜序列化格式
+// magic(4) version(2) kind fingerprint(8) lastClearTime lastSnapshotTime
+// nextSeq(8) count(4) [id(8) key(8) seq(8) value]... crc32(4)
+// 其中kind, time以及value都以4字节长度作为前缀, 所有整数都是小端序
+const (
+	ENGINE_STATE_MAGIC   = 0x53524b45
+	ENGINE_STATE_VERSION = 1
+	MAX_VALUE_SIZE       = 60000
+)
+
+// 排行榜序列化后的状态
+type rankEngineState struct {
+	kind             string
+	fingerprint      uint64
+	lastClearTime    time.Time
+	lastSnapshotTime time.Time
+	nextSeq          uint64
+	units            []RankUnit
+}
+
+// 配置指纹, 只包含影响排序结果的字段
+// MaxSize以及各个周期可以在重启之间修改, 不影响恢复数据
+func (c RankEngineConfig) Fingerprint() uint64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "order=%d;tiebreak=%d", c.SortOrder, c.TieBreak)
+	return h.Sum64()
+}
+
+func writeBytes(w *goutil.StrickyWriter, data []byte) {
+	binary.Write(w, binary.LittleEndian, uint32(len(data)))
+	w.Write(data)
+}
+
+func readBytes(r *goutil.StrickyReader, max uint32) []byte {
+	var size uint32
+	binary.Read(r, binary.LittleEndian, &size)
+	if r.Err != nil {
+		return nil
+	}
+	if size > max {
+		r.Err = fmt.Errorf("Max size %d, got: %d", max, size)
+		return nil
+	}
+	data := make([]byte, size)
+	if size != 0 {
+		r.Read(data)
+	}
+	return data
+}
+
+func writeTime(w *goutil.StrickyWriter, t time.Time) {
+	data, err := t.MarshalBinary()
+	if err != nil {
+		w.Err = err
+		return
+	}
+	writeBytes(w, data)
+}
+
+func readTime(r *goutil.StrickyReader) time.Time {
+	var t time.Time
+	data := readBytes(r, 64)
+	if r.Err != nil {
+		return t
+	}
+	if err := t.UnmarshalBinary(data); err != nil {
+		r.Err = err
+	}
+	return t
+}
+
+func marshalRankEngineState(state rankEngineState) ([]byte, error) {
+	var buf bytes.Buffer
+	w := goutil.NewStrickyWriter(&buf)
+	binary.Write(w, binary.LittleEndian, uint32(ENGINE_STATE_MAGIC))
+	binary.Write(w, binary.LittleEndian, uint16(ENGINE_STATE_VERSION))
+	writeBytes(w, []byte(state.kind))
+	binary.Write(w, binary.LittleEndian, state.fingerprint)
+	writeTime(w, state.lastClearTime)
+	writeTime(w, state.lastSnapshotTime)
+	binary.Write(w, binary.LittleEndian, state.nextSeq)
+	binary.Write(w, binary.LittleEndian, uint32(len(state.units)))
+	for _, u := range state.units {
+		binary.Write(w, binary.LittleEndian, u.ID)
+		binary.Write(w, binary.LittleEndian, u.Key)
+		binary.Write(w, binary.LittleEndian, u.Seq)
+		writeBytes(w, u.Value)
+	}
+	if w.Err != nil {
+		return nil, w.Err
+	}
+	binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+func unmarshalRankEngineState(data []byte,
+	config RankEngineConfig) (state rankEngineState, err error) {
+	if len(data) < 4 {
+		return state, fmt.Errorf("Expect at least 4 bytes, got: %d", len(data))
+	}
+	payload := data[:len(data)-4]
+	checksum := binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return state, fmt.Errorf("Checksum mismatch")
+	}
+
+	r := goutil.NewStrickyReader(bytes.NewReader(payload))
+	var magic uint32
+	var version uint16
+	binary.Read(r, binary.LittleEndian, &magic)
+	binary.Read(r, binary.LittleEndian, &version)
+	if r.Err != nil {
+		return state, r.Err
+	}
+	if magic != ENGINE_STATE_MAGIC {
+		return state, fmt.Errorf("Expect magic 0x%X, got: 0x%X",
+			ENGINE_STATE_MAGIC, magic)
+	}
+	if version != ENGINE_STATE_VERSION {
+		return state, fmt.Errorf("Unsupported version %d", version)
+	}
+	// 不同引擎的数据不能互相加载, 冗余排行榜的类型与底层排行榜不同
+	state.kind = string(readBytes(r, 256))
+	if r.Err == nil && state.kind != config.EngineKind() {
+		return state, fmt.Errorf("Engine kind mismatch, %q vs %q", state.kind,
+			config.EngineKind())
+	}
+	binary.Read(r, binary.LittleEndian, &state.fingerprint)
+	if r.Err == nil && state.fingerprint != config.Fingerprint() {
+		return state, fmt.Errorf("Config fingerprint mismatch, %x vs %x",
+			state.fingerprint, config.Fingerprint())
+	}
+	state.lastClearTime = readTime(r)
+	state.lastSnapshotTime = readTime(r)
+	binary.Read(r, binary.LittleEndian, &state.nextSeq)
+	var count uint32
+	binary.Read(r, binary.LittleEndian, &count)
+	if r.Err != nil {
+		return state, r.Err
+	}
+	// 每条数据至少占用28字节, 防止count损坏时分配过多内存
+	if uint64(count)*28 > uint64(len(payload)) {
+		return state, fmt.Errorf("Invalid unit count %d", count)
+	}
+	state.units = make([]RankUnit, count)
+	ids := make(map[uint64]bool, count)
+	for i := range state.units {
+		u := &state.units[i]
+		binary.Read(r, binary.LittleEndian, &u.ID)
+		binary.Read(r, binary.LittleEndian, &u.Key)
+		binary.Read(r, binary.LittleEndian, &u.Seq)
+		u.Value = readBytes(r, MAX_VALUE_SIZE)
+		if len(u.Value) == 0 {
+			u.Value = nil
+		}
+		if r.Err != nil {
+			return state, r.Err
+		}
+		// 所有检查都在修改排行榜之前完成, 出错时排行榜保持不变
+		if ids[u.ID] {
+			return state, fmt.Errorf("Duplicate ID %d", u.ID)
+		}
+		ids[u.ID] = true
+	}
+	return state, nil
+}
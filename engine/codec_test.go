@@ -0,0 +1,133 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRankEngineMarshalBinary(t *testing.T) {
+	units := []RankUnit{
+		{ID: 1024, Key: 10, Value: []byte("Soldier76")},
+		{ID: 1025, Key: 12, Value: []byte("McCree")},
+		{ID: 1026, Key: 10, Value: []byte("Sombra")},
+		{ID: 1027, Key: 12},
+	}
+	clearTime := time.Date(2017, 3, 23, 17, 18, 0, 0, time.UTC)
+	snapshotTime := clearTime.Add(time.Hour)
+	// 只能加载相同类型引擎的数据
+	for _, from := range RankEngineKinds() {
+		for _, to := range RankEngineKinds() {
+			config := RankEngineConfig{MaxSize: 10, TieBreak: TieBreakLatest}
+			config.Kind = from
+			e, _ := NewRankEngine(config)
+			for _, u := range units {
+				e.Update(u, UpdateModeReplace)
+			}
+			e.SetLastClearTime(clearTime)
+			e.SetLastSnapshotTime(snapshotTime)
+			data, err := e.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			config.Kind = to
+			restored, _ := NewRankEngine(config)
+			restored.Update(RankUnit{ID: 2048, Key: 20}, UpdateModeReplace)
+			err = restored.UnmarshalBinary(data)
+			if from != to {
+				if err == nil {
+					t.Errorf("%s to %s: Expect engine kind mismatch", from, to)
+				}
+				out := restored.GetRange(0, restored.Size())
+				if len(out) != 1 || out[0].ID != 2048 {
+					t.Errorf("%s to %s: Expect rank unchanged, got: %v",
+						from, to, out)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%s to %s: %v", from, to, err)
+			}
+			expect := e.GetRange(0, e.Size())
+			out := restored.GetRange(0, restored.Size())
+			if len(out) != len(expect) {
+				t.Fatalf("%s to %s: Expect %d units, got: %d",
+					from, to, len(expect), len(out))
+			}
+			for i := range expect {
+				if err := checkUnitEqual(expect[i], out[i]); err != nil {
+					t.Errorf("%s to %s: %v", from, to, err)
+				}
+			}
+			if !restored.LastClearTime().Equal(clearTime) ||
+				!restored.LastSnapshotTime().Equal(snapshotTime) {
+				t.Errorf("%s to %s: Period time not restored", from, to)
+			}
+
+			// 更新序号也需要恢复, 之后的更新按照原有的顺序继续
+			u := RankUnit{ID: 1028, Key: 12}
+			e.Update(u, UpdateModeReplace)
+			restored.Update(u, UpdateModeReplace)
+			_, pos, _ := e.Get(u.ID)
+			_, restoredPos, _ := restored.Get(u.ID)
+			if pos != restoredPos {
+				t.Errorf("%s to %s: Expect pos %d, got: %d",
+					from, to, pos, restoredPos)
+			}
+		}
+	}
+}
+
+func TestRankEngineUnmarshalBinaryError(t *testing.T) {
+	e := NewArrayRankEngine(RankEngineConfig{MaxSize: 10})
+	e.Update(RankUnit{ID: 1024, Key: 10}, UpdateModeReplace)
+	data, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ascending := NewArrayRankEngine(RankEngineConfig{SortOrder: SortOrderAscending})
+	if err := ascending.UnmarshalBinary(data); err == nil {
+		t.Error("Expect fingerprint mismatch")
+	}
+
+	corrupted := append([]byte(nil), data...)
+	corrupted[len(corrupted)/2] ^= 0xFF
+	if err := NewArrayRankEngine(e.Config()).UnmarshalBinary(corrupted); err == nil {
+		t.Error("Expect checksum mismatch")
+	}
+
+	if err := NewArrayRankEngine(e.Config()).UnmarshalBinary(data[:3]); err == nil {
+		t.Error("Expect short data error")
+	}
+}
+
+// 重复的ID在修改排行榜之前检查, 出错时原有的数据保持不变
+func TestRankEngineUnmarshalDuplicateID(t *testing.T) {
+	config := RankEngineConfig{MaxSize: 10}
+	for _, kind := range RankEngineKinds() {
+		config.Kind = kind
+		data, err := marshalRankEngineState(rankEngineState{
+			kind:        kind,
+			fingerprint: config.Fingerprint(),
+			nextSeq:     3,
+			units: []RankUnit{
+				{ID: 1024, Key: 12, Seq: 0},
+				{ID: 1025, Key: 11, Seq: 1},
+				{ID: 1024, Key: 10, Seq: 2},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		e, _ := NewRankEngine(config)
+		e.Update(RankUnit{ID: 2048, Key: 20}, UpdateModeReplace)
+		if err := e.UnmarshalBinary(data); err == nil {
+			t.Errorf("%s: Expect duplicate ID error", kind)
+		}
+		units := e.GetRange(0, e.Size())
+		if len(units) != 1 || units[0].ID != 2048 {
+			t.Errorf("%s: Expect rank unchanged, got: %v", kind, units)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// 超过这么多年仍然没有匹配的时间点时认为表达式永远不会触发, 例如2月30日
+	MAX_CRON_SEARCH_YEARS = 5
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cron表达式描述的时间点, 在Location的当地时间计算
+// 格式: 分 时 日 月 星期, 支持*, a-b, */n, a-b/n以及逗号分隔的列表
+// 星期中0和7都表示周日, 日和星期都不是*时满足其中一个即可
+type CronSchedule struct {
+	Expr string
+	// 为nil时使用UTC
+	Location *time.Location
+	// 不早于Start的时间点才有效, 为零值时不限制
+	Start time.Time
+	// 每个时间点之后的持续时间, 仅用于NoUpdatePeriod
+	Duration time.Duration
+
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+func ParseCronSchedule(expr string, loc *time.Location) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		spec = descriptor
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("Expect %d fields in cron expression %q, got: %d",
+			len(cronFields), expr, len(fields))
+	}
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		if bits[i], err = parseCronField(field, cronFields[i]); err != nil {
+			return nil, fmt.Errorf("Invalid cron expression %q: %v", expr, err)
+		}
+	}
+	// 7也表示周日
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &CronSchedule{
+		Expr:     expr,
+		Location: loc,
+		minute:   bits[0],
+		hour:     bits[1],
+		dom:      bits[2],
+		month:    bits[3],
+		dow:      bits[4],
+		domStar:  fields[2] == "*" || fields[2] == "?",
+		dowStar:  fields[4] == "*" || fields[4] == "?",
+	}, nil
+}
+
+func parseCronField(s string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(s, ",") {
+		rangePart, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("Invalid step %q in %s", part[i+1:], f.name)
+			}
+			rangePart, step = part[:i], n
+		}
+		low, high := f.min, f.max
+		if rangePart != "*" && rangePart != "?" {
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("Invalid value %q in %s", bounds[0], f.name)
+			}
+			high = low
+			if len(bounds) == 2 {
+				if high, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("Invalid value %q in %s", bounds[1], f.name)
+				}
+			} else if step != 1 {
+				// a/n表示从a开始到最大值
+				high = f.max
+			}
+			if low < f.min || high > f.max || low > high {
+				return 0, fmt.Errorf("Range %q out of [%d, %d] in %s", rangePart,
+					f.min, f.max, f.name)
+			}
+		}
+		for v := low; v <= high; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (cs *CronSchedule) Empty() bool {
+	return cs == nil || cs.Expr == ""
+}
+
+// 解析过的表达式每个字段至少匹配一个值
+func (cs *CronSchedule) parsed() bool {
+	return cs.minute != 0 && cs.hour != 0 && cs.dom != 0 && cs.month != 0 &&
+		cs.dow != 0
+}
+
+func (cs *CronSchedule) location() *time.Location {
+	if cs.Location == nil {
+		return time.UTC
+	}
+	return cs.Location
+}
+
+func (cs *CronSchedule) matchDay(t time.Time) bool {
+	domMatch := cs.dom&(1<<uint(t.Day())) != 0
+	dowMatch := cs.dow&(1<<uint(t.Weekday())) != 0
+	if cs.domStar || cs.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (cs *CronSchedule) NextTime(from time.Time) time.Time {
+	if cs.Empty() {
+		return time.Time{}
+	}
+	// 直接构造的CronSchedule永远不会触发, 与其他Schedule一样返回零值
+	// 需要触发时必须使用ParseCronSchedule
+	if !cs.parsed() {
+		return time.Time{}
+	}
+	if !cs.Start.IsZero() && cs.Start.After(from) {
+		from = cs.Start.Add(-time.Nanosecond)
+	}
+	loc := cs.location()
+	t := from.In(loc).Truncate(time.Minute).Add(time.Minute)
+	maxYear := t.Year() + MAX_CRON_SEARCH_YEARS
+	// 不匹配时跳到下一个月, 日或者小时的开始
+	// 夏令时切换时当地时间可能重复, 保证每次至少前进一分钟
+	advance := func(next time.Time) {
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next.In(loc)
+	}
+	for t.Year() <= maxYear {
+		year, month, day := t.Date()
+		switch {
+		case cs.month&(1<<uint(month)) == 0:
+			advance(time.Date(year, month+1, 1, 0, 0, 0, 0, loc))
+		case !cs.matchDay(t):
+			advance(time.Date(year, month, day+1, 0, 0, 0, 0, loc))
+		case cs.hour&(1<<uint(t.Hour())) == 0:
+			advance(time.Date(year, month, day, t.Hour()+1, 0, 0, 0, loc))
+		case cs.minute&(1<<uint(t.Minute())) == 0 || repeatedWallClock(t):
+			advance(t.Add(time.Minute))
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// 夏令时结束时当地时间会重复一段, 只在第一次出现时触发
+func repeatedWallClock(t time.Time) bool {
+	_, offset := t.Zone()
+	_, before := t.Add(-time.Hour * 2).Zone()
+	if before <= offset {
+		return false
+	}
+	earlier := t.Add(-time.Duration(before-offset) * time.Second).In(t.Location())
+	y1, m1, d1 := t.Date()
+	y2, m2, d2 := earlier.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2 && t.Hour() == earlier.Hour() &&
+		t.Minute() == earlier.Minute()
+}
+
+func (cs *CronSchedule) Between(from, to time.Time) (int64, time.Time,
+	time.Time) {
+	return scheduleBetween(cs.NextTime, from, to)
+}
+
+func (cs *CronSchedule) Contains(t time.Time) bool {
+	return !cs.WindowEnd(t).IsZero()
+}
+
+func (cs *CronSchedule) WindowEnd(t time.Time) time.Time {
+	return scheduleWindowEnd(cs.NextTime, cs.Duration, t)
+}
+
+func (cs *CronSchedule) String() string {
+	return cs.Expr
+}
@@ -0,0 +1,101 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	invalid := []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"* * * 13 *",
+		"* * * * 8",
+	}
+	for _, expr := range invalid {
+		if _, err := ParseCronSchedule(expr, nil); err == nil {
+			t.Errorf("Expect error for %q", expr)
+		}
+	}
+}
+
+func TestCronScheduleNextTime(t *testing.T) {
+	loc := loadTestLocation(t, "Asia/Shanghai")
+	from := time.Date(2017, 3, 23, 17, 18, 0, 0, loc)
+	cases := []struct {
+		expr   string
+		expect time.Time
+	}{
+		{"* * * * *", from.Add(time.Minute)},
+		{"*/15 * * * *", time.Date(2017, 3, 23, 17, 30, 0, 0, loc)},
+		{"0 4 * * 1", time.Date(2017, 3, 27, 4, 0, 0, 0, loc)},
+		{"@monthly", time.Date(2017, 4, 1, 0, 0, 0, 0, loc)},
+		{"0 0 1 1,7 *", time.Date(2017, 7, 1, 0, 0, 0, 0, loc)},
+		{"0 8-10/2 * * *", time.Date(2017, 3, 24, 8, 0, 0, 0, loc)},
+		// 日和星期都不是*时满足其中一个即可
+		{"0 0 1 * 5", time.Date(2017, 3, 24, 0, 0, 0, 0, loc)},
+		{"0 0 * * 7", time.Date(2017, 3, 26, 0, 0, 0, 0, loc)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, loc)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		cs, err := ParseCronSchedule(c.expr, loc)
+		if err != nil {
+			t.Fatalf("Parse %q: %v", c.expr, err)
+		}
+		if next := cs.NextTime(from); !next.Equal(c.expect) {
+			t.Errorf("%q: expect %s, got: %s", c.expr, c.expect, next)
+		}
+	}
+}
+
+func TestCronScheduleDST(t *testing.T) {
+	loc := loadTestLocation(t, "America/New_York")
+	cs, err := ParseCronSchedule("30 1 * * *", loc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2017-11-05 01:30在当地时间出现两次, 只触发一次
+	from := time.Date(2017, 11, 4, 12, 0, 0, 0, loc)
+	to := time.Date(2017, 11, 6, 12, 0, 0, 0, loc)
+	if n, _, _ := cs.Between(from, to); n != 2 {
+		t.Errorf("Expect 2 time points, got: %d", n)
+	}
+
+	// 2017-03-12 02:30在当地时间不存在
+	cs, _ = ParseCronSchedule("30 2 * * *", loc)
+	next := cs.NextTime(time.Date(2017, 3, 11, 12, 0, 0, 0, loc))
+	if !next.Equal(time.Date(2017, 3, 13, 2, 30, 0, 0, loc)) {
+		t.Errorf("Expect skip nonexistent time, got: %s", next)
+	}
+}
+
+func TestCronScheduleBetween(t *testing.T) {
+	cs, _ := ParseCronSchedule("*/15 * * * *", nil)
+	from := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	n, first, last := cs.Between(from, from.Add(time.Hour))
+	if n != 4 || !first.Equal(from.Add(time.Minute*15)) ||
+		!last.Equal(from.Add(time.Hour)) {
+		t.Errorf("Expect 4 time points, got: %d [%s, %s]", n, first, last)
+	}
+	cs.Duration = time.Minute
+	if !cs.Contains(from.Add(time.Minute*15+time.Second*30)) ||
+		cs.Contains(from.Add(time.Minute*16)) {
+		t.Error("Unexpected Contains result")
+	}
+}
+
+func TestCronScheduleNotParsed(t *testing.T) {
+	cs := &CronSchedule{Expr: "* * * * *"}
+	next := cs.NextTime(time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC))
+	if !next.IsZero() {
+		t.Errorf("Expect never for CronSchedule not created by parser, get %s",
+			next)
+	}
+}
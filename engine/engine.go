@@ -1,40 +1,226 @@
 package engine
 
-import "time"
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
 
 type RankUnit struct {
 	ID    uint64
 	Key   uint64
 	Value []byte
+	// 首次达到当前Key时的更新序号, 由引擎维护, 用于Key相同时决定先后
+	Seq uint64
+}
+
+const (
+	EngineKindArray     = "array"
+	EngineKindRedundant = "redundant"
+	EngineKindSkipList  = "skiplist"
+)
+
+// 排行榜的排序方向
+type SortOrder uint8
+
+const (
+	// Key大的排在前面
+	SortOrderDescending SortOrder = iota
+	// Key小的排在前面, 用于耗时等越低越好的排行榜
+	SortOrderAscending
+)
+
+// 判断Key a是否严格排在Key b之前
+func (o SortOrder) Before(a, b uint64) bool {
+	if o == SortOrderAscending {
+		return a < b
+	}
+	return a > b
+}
+
+func (o SortOrder) String() string {
+	switch o {
+	case SortOrderDescending:
+		return "desc"
+	case SortOrderAscending:
+		return "asc"
+	}
+	return fmt.Sprintf("SortOrder(%d)", uint8(o))
+}
+
+// Key相同时决定先后的规则
+type TieBreak uint8
+
+const (
+	// 先达到该Key的排在前面
+	TieBreakEarliest TieBreak = iota
+	// 后达到该Key的排在前面
+	TieBreakLatest
+	// ID小的排在前面
+	TieBreakLowestID
+)
+
+func (tb TieBreak) String() string {
+	switch tb {
+	case TieBreakEarliest:
+		return "earliest"
+	case TieBreakLatest:
+		return "latest"
+	case TieBreakLowestID:
+		return "lowest_id"
+	}
+	return fmt.Sprintf("TieBreak(%d)", uint8(tb))
 }
 
 type RankEngineConfig struct {
+	// 排行榜引擎类型, 为空时根据RedundantNodeNum选择redundant或者array
+	Kind string
 	// 排行榜需要保存排名的数量
 	MaxSize uint32
 	// 需要缓存的暂时不在排行榜上的冗余节点数量
 	RedundantNodeNum uint32
+	// 排序方向, 默认Key大的排在前面
+	SortOrder SortOrder
+	// Key相同时的排序规则, 默认先达到的排在前面
+	TieBreak TieBreak
+	// 展示名次的计算方式, 默认每个位置的名次都不相同
+	RankingMode RankingMode
 	// 主榜ID, 仅用于快照榜配置
 	PrimaryRankID uint32
-	// 清空周期
-	ClearPeriod TimePeriod
+	// 清空周期, 可以是TimePeriod, CalendarSchedule或者CronSchedule
+	ClearPeriod Schedule
 	// 生成快照周期
-	SnapshotPeriod TimePeriod
-	// 禁止更新数据周期
+	SnapshotPeriod Schedule
+	// 禁止更新数据周期, 每个时间点之后持续Duration
 	// 客户端可以通过ByPassNoUpdate来强制更新数据
-	NoUpdatePeriod TimePeriod
+	NoUpdatePeriod Schedule
+	// 在NoUpdatePeriod之外额外禁止更新的时间段, 例如维护或者决赛期间
+	NoUpdateRanges []TimeRange
+	// NoUpdatePeriod在这些时间段内不生效, 例如节假日, 不影响NoUpdateRanges
+	NoUpdateExclusions []TimeRange
+	// 清空或者被快照覆盖之前归档的历史排行榜数量, 0表示不归档
+	HistorySize uint32
+	// 清空或者被快照覆盖之前结算的名次数量, 0表示不结算
+	SettlementSize uint32
+}
+
+// 判断a是否严格排在b之前
+func (c RankEngineConfig) Before(a, b RankUnit) bool {
+	if a.Key != b.Key {
+		return c.SortOrder.Before(a.Key, b.Key)
+	}
+	switch c.TieBreak {
+	case TieBreakLatest:
+		if a.Seq != b.Seq {
+			return a.Seq > b.Seq
+		}
+	case TieBreakLowestID:
+		return a.ID < b.ID
+	default:
+		if a.Seq != b.Seq {
+			return a.Seq < b.Seq
+		}
+	}
+	return a.ID < b.ID
+}
+
+// 更新数据的方式
+type UpdateMode uint8
+
+const (
+	// 直接覆盖原有数据
+	UpdateModeReplace UpdateMode = iota
+	// 仅当新的Key更大时更新
+	UpdateModeKeepHigher
+	// 仅当新的Key更小时更新
+	UpdateModeKeepLower
+	// 在原有Key上累加, 新数据的Key按int64解释为增量, 结果限制在uint64范围内
+	UpdateModeAddDelta
+)
+
+func (m UpdateMode) String() string {
+	switch m {
+	case UpdateModeReplace:
+		return "replace"
+	case UpdateModeKeepHigher:
+		return "keep_higher"
+	case UpdateModeKeepLower:
+		return "keep_lower"
+	case UpdateModeAddDelta:
+		return "add_delta"
+	}
+	return fmt.Sprintf("UpdateMode(%d)", uint8(m))
+}
+
+// 根据更新方式计算更新后的数据, 返回false表示原有数据不需要更新
+func (m UpdateMode) Apply(exist bool, old RankUnit, u RankUnit) (RankUnit, bool) {
+	switch m {
+	case UpdateModeKeepHigher:
+		if exist && u.Key <= old.Key {
+			return old, false
+		}
+	case UpdateModeKeepLower:
+		if exist && u.Key >= old.Key {
+			return old, false
+		}
+	case UpdateModeAddDelta:
+		var base uint64
+		if exist {
+			base = old.Key
+		}
+		delta := int64(u.Key)
+		if delta >= 0 {
+			if base+uint64(delta) < base {
+				u.Key = math.MaxUint64
+			} else {
+				u.Key = base + uint64(delta)
+			}
+		} else {
+			if uint64(-delta) > base {
+				u.Key = 0
+			} else {
+				u.Key = base - uint64(-delta)
+			}
+		}
+	}
+	return u, true
 }
 
 type RankEngine interface {
 	Config() RankEngineConfig
+	// 运行时修改配置, 缩小MaxSize时淘汰超出的数据
+	// 影响排序以及引擎类型的配置不能修改, 见ConfigChangeError
+	SetConfig(config RankEngineConfig) error
 	Size() uint32
 	Get(id uint64) (bool, uint32, RankUnit)
 	GetByRank(pos uint32) (bool, RankUnit)
 	GetRange(pos, num uint32) []RankUnit
-	Update(u RankUnit) (bool, uint32, RankUnit)
+	// 返回id对应数据的排名以及前before个和后after个数据, 窗口在排行榜边界处截断
+	// 返回值依次为数据是否存在, 数据的排名, 窗口第一条数据的排名, 窗口内的数据
+	GetAround(id uint64, before, after uint32) (bool, uint32, uint32, []RankUnit)
+	// 返回排在key之前的数据数量, 即key上榜后能达到的最好排名
+	RankOfKey(key uint64) uint32
+	// 按排名顺序返回key在[min, max]之间的数据, 最多返回limit个, 0表示不限制
+	// 返回值依次为第一条数据的排名, 查询的数据
+	GetByKeyRange(min, max uint64, limit uint32) (uint32, []RankUnit)
+	// 返回排在pos处数据之前的不同Key的数量, pos必须在排行榜上
+	// 结果在两次修改之间缓存, 用于RankingModeDense计算展示名次
+	DistinctKeysBefore(pos uint32) uint32
+	// 按照mode更新数据, 返回更新前的数据是否存在, 排名, 数据
+	// 以及引擎中保存的key是否发生了变化, 与UpdateMany相同, 不考虑之后被淘汰的情况
+	Update(u RankUnit, mode UpdateMode) (bool, uint32, RankUnit, bool)
+	// 按顺序批量更新数据, 只在最后排序以及淘汰一次
+	// 返回每条数据更新后key是否发生了变化, 不考虑之后被淘汰的情况
+	UpdateMany(units []RankUnit, mode UpdateMode) []bool
 	Delete(id uint64) (bool, uint32, RankUnit)
 	CreateSnapshot() RankEngine
 	Clear()
 	CopyFrom(rank RankEngine)
+	// 序列化排行榜的全部状态, 包括数据, 更新序号以及清空和快照时间
+	MarshalBinary() ([]byte, error)
+	// 从MarshalBinary的结果恢复排行榜, 影响排序的配置必须一致
+	UnmarshalBinary(data []byte) error
 
 	LastClearTime() time.Time
 	SetLastClearTime(t time.Time)
@@ -43,6 +229,117 @@ type RankEngine interface {
 	SetLastSnapshotTime(t time.Time)
 }
 
-func NewRankEngine(config RankEngineConfig) RankEngine {
-	return NewArrayRankEngine(config)
+type RankEngineCreator func(config RankEngineConfig) RankEngine
+
+var rankEngineCreators = make(map[string]RankEngineCreator)
+
+// 注册排行榜引擎实现, 重复注册同一类型会panic
+func RegisterRankEngine(kind string, creator RankEngineCreator) {
+	if _, exist := rankEngineCreators[kind]; exist {
+		panic(fmt.Sprintf("Rank engine kind %q already registered", kind))
+	}
+	rankEngineCreators[kind] = creator
+}
+
+// 返回所有已注册的排行榜引擎类型
+func RankEngineKinds() []string {
+	kinds := make([]string, 0, len(rankEngineCreators))
+	for kind := range rankEngineCreators {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// 检查运行时能否从old修改为config, 返回无法修改的字段
+// 引擎类型以及排序规则决定了已有数据的结构, 主榜ID决定了排行榜所属的RankHandler
+func ConfigChangeError(old, config RankEngineConfig) error {
+	var fields []string
+	if old.EngineKind() != config.EngineKind() {
+		fields = append(fields, "Kind")
+	}
+	if old.SortOrder != config.SortOrder {
+		fields = append(fields, "SortOrder")
+	}
+	if old.TieBreak != config.TieBreak {
+		fields = append(fields, "TieBreak")
+	}
+	if old.PrimaryRankID != config.PrimaryRankID {
+		fields = append(fields, "PrimaryRankID")
+	}
+	if len(fields) != 0 {
+		return fmt.Errorf("Can not change %v at runtime", fields)
+	}
+	return nil
+}
+
+// 返回配置实际使用的引擎类型
+func (c RankEngineConfig) EngineKind() string {
+	if c.Kind != "" {
+		return c.Kind
+	}
+	if c.RedundantNodeNum != 0 {
+		return EngineKindRedundant
+	}
+	return EngineKindArray
+}
+
+func NewRankEngine(config RankEngineConfig) (RankEngine, error) {
+	kind := config.EngineKind()
+	creator, exist := rankEngineCreators[kind]
+	if !exist {
+		return nil, fmt.Errorf("Unknown rank engine kind %q, expect one of %v",
+			kind, RankEngineKinds())
+	}
+	return creator(config), nil
+}
+
+func getAround(rank RankEngine, id uint64,
+	before, after uint32) (bool, uint32, uint32, []RankUnit) {
+	exist, pos, _ := rank.Get(id)
+	if !exist {
+		return false, 0, 0, nil
+	}
+	start := uint32(0)
+	if pos > before {
+		start = pos - before
+	}
+	num := pos - start + 1
+	if after > rank.Size()-pos-1 {
+		num += rank.Size() - pos - 1
+	} else {
+		num += after
+	}
+	return true, pos, start, rank.GetRange(start, num)
+}
+
+func getByKeyRange(rank RankEngine, min, max uint64,
+	limit uint32) (uint32, []RankUnit) {
+	if min > max {
+		return 0, nil
+	}
+	// start: 第一条不排在区间最好的key之前的数据
+	// end: 第一条排在区间最差的key之后的数据
+	var start, end uint32
+	if rank.Config().SortOrder == SortOrderAscending {
+		start = rank.RankOfKey(min)
+		end = rank.Size()
+		if max != math.MaxUint64 {
+			end = rank.RankOfKey(max + 1)
+		}
+	} else {
+		start = rank.RankOfKey(max)
+		end = rank.Size()
+		if min != 0 {
+			end = rank.RankOfKey(min - 1)
+		}
+	}
+	if start >= end {
+		return start, nil
+	}
+	num := end - start
+	if limit != 0 && num > limit {
+		num = limit
+	}
+	return start, rank.GetRange(start, num)
 }
@@ -0,0 +1,316 @@
+package engine
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewRankEngineKind(t *testing.T) {
+	cases := []struct {
+		config RankEngineConfig
+		kind   string
+	}{
+		{RankEngineConfig{MaxSize: 10}, EngineKindArray},
+		{RankEngineConfig{MaxSize: 10, RedundantNodeNum: 5}, EngineKindRedundant},
+		{RankEngineConfig{Kind: EngineKindSkipList, MaxSize: 10}, EngineKindSkipList},
+	}
+	for _, c := range cases {
+		e, err := NewRankEngine(c.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ok bool
+		switch c.kind {
+		case EngineKindArray:
+			_, ok = e.(*ArrayRankEngine)
+		case EngineKindRedundant:
+			_, ok = e.(*RedundantRankEngine)
+		case EngineKindSkipList:
+			_, ok = e.(*SkipListRankEngine)
+		}
+		if !ok {
+			t.Errorf("Expect %s engine, got: %T", c.kind, e)
+		}
+	}
+}
+
+func TestNewRankEngineUnknownKind(t *testing.T) {
+	_, err := NewRankEngine(RankEngineConfig{Kind: "btree"})
+	if err == nil {
+		t.Error("Expect error for unknown kind")
+	}
+}
+
+func TestRankEngineTieBreak(t *testing.T) {
+	u1 := RankUnit{ID: 1026, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1024, Key: 10, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1025, Key: 10, Value: []byte("Sombra")}
+	cases := []struct {
+		tieBreak TieBreak
+		expect   []uint64
+	}{
+		{TieBreakEarliest, []uint64{u1.ID, u2.ID, u3.ID}},
+		{TieBreakLatest, []uint64{u3.ID, u2.ID, u1.ID}},
+		{TieBreakLowestID, []uint64{u2.ID, u3.ID, u1.ID}},
+	}
+	for _, kind := range RankEngineKinds() {
+		for _, c := range cases {
+			e, err := NewRankEngine(RankEngineConfig{Kind: kind, MaxSize: 10,
+				TieBreak: c.tieBreak})
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Update(u1, UpdateModeReplace)
+			e.Update(u2, UpdateModeReplace)
+			e.Update(u3, UpdateModeReplace)
+			// Key没有变化的更新不会改变并列时的先后顺序
+			e.Update(u1, UpdateModeReplace)
+
+			snapshot := e.CreateSnapshot()
+			copied, _ := NewRankEngine(e.Config())
+			copied.CopyFrom(e)
+			for _, r := range []RankEngine{e, snapshot, copied} {
+				units := r.GetRange(0, r.Size())
+				if len(units) != len(c.expect) {
+					t.Fatalf("%s/%s: Expect %d units, got: %d",
+						kind, c.tieBreak, len(c.expect), len(units))
+				}
+				for i, id := range c.expect {
+					if units[i].ID != id {
+						t.Errorf("%s/%s: Expect ID %d at %d, got: %d",
+							kind, c.tieBreak, id, i, units[i].ID)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestRankEngineUpdateMode(t *testing.T) {
+	const id = 1024
+	delta := func(d int64) uint64 { return uint64(d) }
+	steps := []struct {
+		mode   UpdateMode
+		key    uint64
+		expect uint64
+	}{
+		{UpdateModeKeepHigher, 10, 10},
+		{UpdateModeKeepHigher, 8, 10},
+		{UpdateModeKeepHigher, 12, 12},
+		{UpdateModeKeepLower, 15, 12},
+		{UpdateModeKeepLower, 5, 5},
+		{UpdateModeAddDelta, delta(7), 12},
+		{UpdateModeAddDelta, delta(-2), 10},
+		{UpdateModeAddDelta, delta(-20), 0},
+		{UpdateModeReplace, 3, 3},
+	}
+	for _, kind := range RankEngineKinds() {
+		e, _ := NewRankEngine(RankEngineConfig{Kind: kind, MaxSize: 10})
+		for i, step := range steps {
+			e.Update(RankUnit{ID: id, Key: step.key}, step.mode)
+			_, _, u := e.Get(id)
+			if u.Key != step.expect {
+				t.Errorf("%s: step %d %s expect key %d, got: %d",
+					kind, i, step.mode, step.expect, u.Key)
+			}
+		}
+	}
+}
+
+func TestRankEngineGetAround(t *testing.T) {
+	for _, kind := range RankEngineKinds() {
+		e, _ := NewRankEngine(RankEngineConfig{Kind: kind, MaxSize: 10})
+		for i := uint64(0); i < 10; i++ {
+			e.Update(RankUnit{ID: i, Key: 100 - i}, UpdateModeReplace)
+		}
+		cases := []struct {
+			id            uint64
+			before, after uint32
+			start, num    uint32
+		}{
+			{5, 2, 2, 3, 5},
+			{1, 3, 2, 0, 4},
+			{8, 2, 3, 6, 4},
+			{0, 0, 0, 0, 1},
+			{9, 20, 20, 0, 10},
+		}
+		for _, c := range cases {
+			exist, pos, start, units := e.GetAround(c.id, c.before, c.after)
+			if !exist || pos != uint32(c.id) {
+				t.Errorf("%s: Expect ID %d at %d, got: %v %d",
+					kind, c.id, c.id, exist, pos)
+			}
+			if start != c.start || len(units) != int(c.num) {
+				t.Errorf("%s: ID %d expect window [%d, +%d), got: [%d, +%d)",
+					kind, c.id, c.start, c.num, start, len(units))
+				continue
+			}
+			if units[pos-start].ID != c.id {
+				t.Errorf("%s: Expect ID %d in window, got: %d",
+					kind, c.id, units[pos-start].ID)
+			}
+		}
+		exist, _, _, units := e.GetAround(1000, 1, 1)
+		if exist || units != nil {
+			t.Errorf("%s: Expect ID 1000 not exist", kind)
+		}
+	}
+}
+
+// pos+num超过uint32时不能溢出
+func TestRankEngineGetRangeOverflow(t *testing.T) {
+	for _, kind := range RankEngineKinds() {
+		e, _ := NewRankEngine(RankEngineConfig{Kind: kind, MaxSize: 10})
+		for i := uint64(0); i < 10; i++ {
+			e.Update(RankUnit{ID: i, Key: 100 - i}, UpdateModeReplace)
+		}
+		for _, pos := range []uint32{1, 5, 9} {
+			units := e.GetRange(pos, math.MaxUint32)
+			if len(units) != int(e.Size()-pos) {
+				t.Errorf("%s: Expect %d units from %d, got: %d",
+					kind, e.Size()-pos, pos, len(units))
+				continue
+			}
+			if units[0].ID != uint64(pos) {
+				t.Errorf("%s: Expect ID %d at %d, got: %d",
+					kind, pos, pos, units[0].ID)
+			}
+		}
+	}
+}
+
+func TestRankEngineKeyQuery(t *testing.T) {
+	keys := []uint64{50, 40, 40, 30, 20, 10}
+	for _, kind := range RankEngineKinds() {
+		for _, order := range []SortOrder{SortOrderDescending, SortOrderAscending} {
+			e, _ := NewRankEngine(RankEngineConfig{Kind: kind, SortOrder: order})
+			for i, key := range keys {
+				e.Update(RankUnit{ID: uint64(i), Key: key}, UpdateModeReplace)
+			}
+			// 暴力计算期望的结果
+			units := e.GetRange(0, e.Size())
+			for _, key := range []uint64{0, 10, 35, 40, 60} {
+				var expect uint32
+				for _, u := range units {
+					if order.Before(u.Key, key) {
+						expect++
+					}
+				}
+				if pos := e.RankOfKey(key); pos != expect {
+					t.Errorf("%s/%s: Expect key %d rank %d, got: %d",
+						kind, order, key, expect, pos)
+				}
+			}
+
+			ranges := [][2]uint64{{20, 40}, {0, 100}, {41, 49}, {40, 40}, {30, 10}}
+			for _, r := range ranges {
+				var expect []RankUnit
+				for _, u := range units {
+					if u.Key >= r[0] && u.Key <= r[1] {
+						expect = append(expect, u)
+					}
+				}
+				start, out := e.GetByKeyRange(r[0], r[1], 0)
+				if len(out) != len(expect) {
+					t.Errorf("%s/%s: Expect %d units in %v, got: %d",
+						kind, order, len(expect), r, len(out))
+					continue
+				}
+				for i := range expect {
+					if err := checkUnitEqual(expect[i], out[i]); err != nil {
+						t.Error(err)
+					}
+					if len(expect) != 0 && units[int(start)+i].ID != out[i].ID {
+						t.Errorf("%s/%s: Unexpected start %d", kind, order, start)
+					}
+				}
+				_, out = e.GetByKeyRange(r[0], r[1], 1)
+				if len(expect) != 0 && len(out) != 1 {
+					t.Errorf("%s/%s: Expect limit 1, got: %d", kind, order, len(out))
+				}
+			}
+		}
+	}
+}
+
+func TestRankEngineUpdateMany(t *testing.T) {
+	units := []RankUnit{
+		{ID: 1, Key: 10},
+		{ID: 2, Key: 30},
+		{ID: 3, Key: 20},
+		{ID: 1, Key: 5},
+		{ID: 4, Key: 40},
+		{ID: 2, Key: 50},
+	}
+	for _, kind := range RankEngineKinds() {
+		for _, mode := range []UpdateMode{UpdateModeReplace, UpdateModeKeepHigher} {
+			config := RankEngineConfig{Kind: kind, MaxSize: 3}
+			batch, _ := NewRankEngine(config)
+			single, _ := NewRankEngine(config)
+			batch.Update(RankUnit{ID: 3, Key: 20}, UpdateModeReplace)
+			single.Update(RankUnit{ID: 3, Key: 20}, UpdateModeReplace)
+
+			changed := batch.UpdateMany(units, mode)
+			expectChanged := []bool{true, true, false, mode == UpdateModeReplace,
+				true, true}
+			for i := range units {
+				single.Update(units[i], mode)
+				if changed[i] != expectChanged[i] {
+					t.Errorf("%s/%s: Expect item %d changed %v, got: %v",
+						kind, mode, i, expectChanged[i], changed[i])
+				}
+			}
+			expect := single.GetRange(0, single.Size())
+			out := batch.GetRange(0, batch.Size())
+			if len(expect) != len(out) {
+				t.Fatalf("%s/%s: Expect %d units, got: %d",
+					kind, mode, len(expect), len(out))
+			}
+			for i := range expect {
+				if err := checkUnitEqual(expect[i], out[i]); err != nil {
+					t.Errorf("%s/%s: %v", kind, mode, err)
+				}
+			}
+		}
+	}
+}
+
+func TestRankEngineSetConfig(t *testing.T) {
+	for _, kind := range RankEngineKinds() {
+		config := RankEngineConfig{Kind: kind, MaxSize: 10, RedundantNodeNum: 2}
+		e, _ := NewRankEngine(config)
+		for i := uint64(0); i < 5; i++ {
+			e.Update(RankUnit{ID: 1024 + i, Key: 10 + i}, UpdateModeReplace)
+		}
+		config.MaxSize = 3
+		if err := e.SetConfig(config); err != nil {
+			t.Fatal(err)
+		}
+		units := e.GetRange(0, 10)
+		if len(units) != 3 || units[0].ID != 1028 || units[2].ID != 1026 {
+			t.Errorf("%s: Expect top 3 [1028 1027 1026], got: %v", kind, units)
+		}
+
+		config.MaxSize = 10
+		e.SetConfig(config)
+		e.Update(RankUnit{ID: 1030, Key: 1}, UpdateModeReplace)
+		// 冗余节点在扩大之后重新上榜
+		expectSize := uint32(4)
+		if kind == EngineKindRedundant {
+			expectSize = 6
+		}
+		if e.Size() != expectSize {
+			t.Errorf("%s: Expect size %d after grow, got: %d", kind, expectSize,
+				e.Size())
+		}
+
+		changed := config
+		changed.SortOrder = SortOrderAscending
+		if err := e.SetConfig(changed); err == nil {
+			t.Errorf("%s: Expect error for changing sort order", kind)
+		}
+		if e.Config().SortOrder != SortOrderDescending {
+			t.Errorf("%s: Expect config unchanged", kind)
+		}
+	}
+}
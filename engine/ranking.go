@@ -0,0 +1,161 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// 展示名次的计算方式
+type RankingMode uint8
+
+const (
+	// 每个位置的名次都不相同: 1, 2, 3, 4
+	RankingModeOrdinal RankingMode = iota
+	// Key相同的名次相同, 后续名次跳过并列的数量: 1, 2, 2, 4
+	RankingModeCompetition
+	// Key相同的名次相同, 后续名次连续: 1, 2, 2, 3
+	RankingModeDense
+)
+
+func (m RankingMode) String() string {
+	switch m {
+	case RankingModeOrdinal:
+		return "ordinal"
+	case RankingModeCompetition:
+		return "competition"
+	case RankingModeDense:
+		return "dense"
+	}
+	return fmt.Sprintf("RankingMode(%d)", uint8(m))
+}
+
+// 返回和pos处Key相同的第一个位置
+func firstPosWithSameKey(rank RankEngine, pos uint32, key uint64) uint32 {
+	lo, hi := uint32(0), pos
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		_, u := rank.GetByRank(mid)
+		if u.Key == key {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo
+}
+
+// 排行榜中每个Key第一次出现的位置, 用于RankingModeDense统计排在前面的不同Key的数量
+// 引擎修改数据之后调用reset使其失效, 下次查询时遍历一次排行榜重新建立
+type keyGroups struct {
+	valid   bool
+	lastKey uint64
+	starts  []uint32
+}
+
+func (g *keyGroups) reset() {
+	g.valid = false
+	g.starts = g.starts[:0]
+}
+
+// 按排名顺序添加数据
+func (g *keyGroups) add(pos uint32, key uint64) {
+	if len(g.starts) == 0 || key != g.lastKey {
+		g.starts = append(g.starts, pos)
+		g.lastKey = key
+	}
+}
+
+// 返回排在pos处数据之前的不同Key的数量, pos必须在排行榜上
+func (g *keyGroups) before(pos uint32) uint32 {
+	n := sort.Search(len(g.starts), func(i int) bool {
+		return g.starts[i] > pos
+	})
+	return uint32(n) - 1
+}
+
+// 返回pos处数据的展示名次, 从1开始, 0表示pos不在排行榜上
+func DisplayRank(rank RankEngine, pos uint32) uint32 {
+	exist, u := rank.GetByRank(pos)
+	if !exist {
+		return 0
+	}
+	switch rank.Config().RankingMode {
+	case RankingModeCompetition:
+		return firstPosWithSameKey(rank, pos, u.Key) + 1
+	case RankingModeDense:
+		return rank.DistinctKeysBefore(pos) + 1
+	default:
+		return pos + 1
+	}
+}
+
+// 返回从pos开始的连续数据units的展示名次
+func DisplayRanks(rank RankEngine, pos uint32, units []RankUnit) []uint32 {
+	if len(units) == 0 {
+		return nil
+	}
+	mode := rank.Config().RankingMode
+	displayRanks := make([]uint32, len(units))
+	displayRanks[0] = DisplayRank(rank, pos)
+	for i := 1; i < len(units); i++ {
+		switch {
+		case mode == RankingModeOrdinal:
+			displayRanks[i] = pos + uint32(i) + 1
+		case units[i].Key == units[i-1].Key:
+			displayRanks[i] = displayRanks[i-1]
+		case mode == RankingModeDense:
+			displayRanks[i] = displayRanks[i-1] + 1
+		default:
+			displayRanks[i] = pos + uint32(i) + 1
+		}
+	}
+	return displayRanks
+}
+
+// 子集中的一条数据
+type SubsetUnit struct {
+	RankUnit
+	// 在整个排行榜中的排名
+	Pos uint32
+	// 在子集中按排行榜名次规则计算的名次, 从1开始
+	RelativeRank uint32
+}
+
+// 将ids对应的数据按排行榜中的顺序排列, 并计算在子集中的名次
+// 返回值依次为排好序的数据, 不在排行榜上的ID
+func RankSubset(rank RankEngine, ids []uint64) ([]SubsetUnit, []uint64) {
+	var units []SubsetUnit
+	var missing []uint64
+	seen := make(map[uint64]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		exist, pos, u := rank.Get(id)
+		if !exist {
+			missing = append(missing, id)
+			continue
+		}
+		units = append(units, SubsetUnit{RankUnit: u, Pos: pos})
+	}
+	// 排行榜中的排名已经体现了排序方向以及并列规则
+	sort.Slice(units, func(i, j int) bool {
+		return units[i].Pos < units[j].Pos
+	})
+
+	mode := rank.Config().RankingMode
+	for i := range units {
+		switch {
+		case i == 0 || mode == RankingModeOrdinal:
+			units[i].RelativeRank = uint32(i) + 1
+		case units[i].Key == units[i-1].Key:
+			units[i].RelativeRank = units[i-1].RelativeRank
+		case mode == RankingModeDense:
+			units[i].RelativeRank = units[i-1].RelativeRank + 1
+		default:
+			units[i].RelativeRank = uint32(i) + 1
+		}
+	}
+	return units, missing
+}
@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestDisplayRank(t *testing.T) {
+	keys := []uint64{40, 30, 30, 20, 20, 20, 10}
+	cases := []struct {
+		mode   RankingMode
+		expect []uint32
+	}{
+		{RankingModeOrdinal, []uint32{1, 2, 3, 4, 5, 6, 7}},
+		{RankingModeCompetition, []uint32{1, 2, 2, 4, 4, 4, 7}},
+		{RankingModeDense, []uint32{1, 2, 2, 3, 3, 3, 4}},
+	}
+	for _, c := range cases {
+		e := NewSkipListRankEngine(RankEngineConfig{RankingMode: c.mode})
+		for i, key := range keys {
+			e.Update(RankUnit{ID: uint64(i + 1), Key: key}, UpdateModeReplace)
+		}
+		for pos, expect := range c.expect {
+			if r := DisplayRank(e, uint32(pos)); r != expect {
+				t.Errorf("%s: Expect pos %d display rank %d, got: %d",
+					c.mode, pos, expect, r)
+			}
+		}
+		if r := DisplayRank(e, e.Size()); r != 0 {
+			t.Errorf("%s: Expect display rank 0, got: %d", c.mode, r)
+		}
+
+		for start := uint32(0); start < e.Size(); start++ {
+			units := e.GetRange(start, 3)
+			displayRanks := DisplayRanks(e, start, units)
+			for i, r := range displayRanks {
+				if r != c.expect[int(start)+i] {
+					t.Errorf("%s: Expect pos %d display rank %d, got: %d",
+						c.mode, int(start)+i, c.expect[int(start)+i], r)
+				}
+			}
+		}
+	}
+}
+
+// 修改之后缓存的分组失效, 与逐个统计不同Key的结果对比
+func TestDisplayRankDenseAfterUpdate(t *testing.T) {
+	for _, kind := range RankEngineKinds() {
+		e, err := NewRankEngine(RankEngineConfig{
+			Kind:             kind,
+			MaxSize:          32,
+			RedundantNodeNum: 8,
+			RankingMode:      RankingModeDense,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		rnd := rand.New(rand.NewSource(1))
+		for i := 0; i < 2000; i++ {
+			u := RankUnit{ID: uint64(rnd.Intn(64)), Key: uint64(rnd.Intn(8))}
+			if rnd.Intn(4) == 0 {
+				e.Delete(u.ID)
+			} else {
+				e.Update(u, UpdateModeReplace)
+			}
+			units := e.GetRange(0, e.Size())
+			var expect uint32
+			for pos := range units {
+				if pos == 0 || units[pos].Key != units[pos-1].Key {
+					expect++
+				}
+				if r := DisplayRank(e, uint32(pos)); r != expect {
+					t.Fatalf("%s: Expect pos %d display rank %d, got: %d",
+						kind, pos, expect, r)
+				}
+			}
+		}
+	}
+}
+
+func TestRankSubset(t *testing.T) {
+	keys := []uint64{40, 30, 30, 20, 20, 20, 10}
+	e := NewSkipListRankEngine(RankEngineConfig{
+		RankingMode: RankingModeCompetition,
+		TieBreak:    TieBreakLowestID,
+	})
+	for i, key := range keys {
+		e.Update(RankUnit{ID: uint64(i + 1), Key: key}, UpdateModeReplace)
+	}
+
+	units, missing := RankSubset(e, []uint64{7, 5, 100, 3, 4, 5})
+	if len(missing) != 1 || missing[0] != 100 {
+		t.Errorf("Expect missing [100], got: %v", missing)
+	}
+	expect := []struct {
+		id           uint64
+		pos          uint32
+		relativeRank uint32
+	}{
+		{3, 2, 1},
+		{4, 3, 2},
+		{5, 4, 2},
+		{7, 6, 4},
+	}
+	if len(units) != len(expect) {
+		t.Fatalf("Expect %d units, got: %d", len(expect), len(units))
+	}
+	for i, u := range units {
+		if u.ID != expect[i].id || u.Pos != expect[i].pos ||
+			u.RelativeRank != expect[i].relativeRank {
+			t.Errorf("Expect %v, got: {%d %d %d}", expect[i], u.ID, u.Pos,
+				u.RelativeRank)
+		}
+	}
+}
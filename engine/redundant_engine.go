@@ -7,12 +7,23 @@ type RedundantRankEngine struct {
 	underlying RankEngine
 }
 
-func NewRedundantRankEngine(config RankEngineConfig) *RedundantRankEngine {
+func init() {
+	RegisterRankEngine(EngineKindRedundant, func(config RankEngineConfig) RankEngine {
+		return NewRedundantRankEngine(config)
+	})
+}
+
+// 底层排行榜同时保存冗余节点
+func redundantUnderlyingConfig(config RankEngineConfig) RankEngineConfig {
 	underlyingConfig := config
 	underlyingConfig.MaxSize = config.MaxSize + config.RedundantNodeNum
+	return underlyingConfig
+}
+
+func NewRedundantRankEngine(config RankEngineConfig) *RedundantRankEngine {
 	return &RedundantRankEngine{
 		config:     config,
-		underlying: NewArrayRankEngine(underlyingConfig),
+		underlying: NewArrayRankEngine(redundantUnderlyingConfig(config)),
 	}
 }
 
@@ -20,6 +31,17 @@ func (e *RedundantRankEngine) Config() RankEngineConfig {
 	return e.config
 }
 
+func (e *RedundantRankEngine) SetConfig(config RankEngineConfig) error {
+	if err := ConfigChangeError(e.config, config); err != nil {
+		return err
+	}
+	if err := e.underlying.SetConfig(redundantUnderlyingConfig(config)); err != nil {
+		return err
+	}
+	e.config = config
+	return nil
+}
+
 func (e *RedundantRankEngine) Size() uint32 {
 	if e.underlying.Size() > e.config.MaxSize {
 		return e.config.MaxSize
@@ -47,18 +69,49 @@ func (e *RedundantRankEngine) GetRange(pos, num uint32) []RankUnit {
 		return nil
 	}
 	n := num
-	if pos+num >= e.Size() {
+	if num >= e.Size()-pos {
 		n = e.Size() - pos
 	}
 	return e.underlying.GetRange(pos, n)
 }
 
-func (e *RedundantRankEngine) Update(u RankUnit) (bool, uint32, RankUnit) {
-	exist, pos, u := e.underlying.Update(u)
+func (e *RedundantRankEngine) GetAround(id uint64,
+	before, after uint32) (bool, uint32, uint32, []RankUnit) {
+	return getAround(e, id, before, after)
+}
+
+func (e *RedundantRankEngine) RankOfKey(key uint64) uint32 {
+	pos := e.underlying.RankOfKey(key)
+	if pos > e.Size() {
+		return e.Size()
+	}
+	return pos
+}
+
+func (e *RedundantRankEngine) GetByKeyRange(min, max uint64,
+	limit uint32) (uint32, []RankUnit) {
+	return getByKeyRange(e, min, max, limit)
+}
+
+// 冗余节点都排在可见数据之后, 不影响可见数据之前的不同Key数量
+func (e *RedundantRankEngine) DistinctKeysBefore(pos uint32) uint32 {
+	return e.underlying.DistinctKeysBefore(pos)
+}
+
+// 更新前是冗余节点时不返回更新前的数据, 但是key是否变化按照底层保存的数据计算
+// 数据在冗余节点和排行榜之间移动时也能得到正确的结果
+func (e *RedundantRankEngine) Update(u RankUnit,
+	mode UpdateMode) (bool, uint32, RankUnit, bool) {
+	exist, pos, old, changed := e.underlying.Update(u, mode)
 	if pos >= e.config.MaxSize {
-		return false, 0, RankUnit{}
+		return false, 0, RankUnit{}, changed
 	}
-	return exist, pos, u
+	return exist, pos, old, changed
+}
+
+func (e *RedundantRankEngine) UpdateMany(units []RankUnit,
+	mode UpdateMode) []bool {
+	return e.underlying.UpdateMany(units, mode)
 }
 
 func (e *RedundantRankEngine) Delete(id uint64) (bool, uint32, RankUnit) {
@@ -85,6 +138,15 @@ func (e *RedundantRankEngine) CopyFrom(rank RankEngine) {
 	e.underlying.CopyFrom(rank)
 }
 
+// 冗余节点也一起序列化, 恢复之后可以继续补位
+func (e *RedundantRankEngine) MarshalBinary() ([]byte, error) {
+	return e.underlying.MarshalBinary()
+}
+
+func (e *RedundantRankEngine) UnmarshalBinary(data []byte) error {
+	return e.underlying.UnmarshalBinary(data)
+}
+
 func (e *RedundantRankEngine) LastClearTime() time.Time {
 	return e.underlying.LastClearTime()
 }
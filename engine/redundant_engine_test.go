@@ -10,9 +10,9 @@ func TestRedundantEngineSize(t *testing.T) {
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
 	//u4 := RankUnit{ID: 1025, Key: 12, Value: []byte("D.Va")}
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
-	e.Update(u3)
-	e.Update(u2)
-	e.Update(u1)
+	e.Update(u3, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u1, UpdateModeReplace)
 
 	if e.Size() != 2 {
 		t.Errorf("Expect size 2, got: %d", e.Size())
@@ -26,9 +26,9 @@ func TestRedundantEngineGet(t *testing.T) {
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
 	//u4 := RankUnit{ID: 1025, Key: 12, Value: []byte("D.Va")}
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
-	e.Update(u2)
-	e.Update(u1)
-	e.Update(u3)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	exist, _, _ := e.Get(u1.ID)
 	if exist {
@@ -64,10 +64,10 @@ func TestRedundantEngineGetByRank(t *testing.T) {
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
 	u4 := RankUnit{ID: 1025, Key: 20, Value: []byte("D.Va")}
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
-	e.Update(u2)
-	e.Update(u1)
-	e.Update(u4)
-	e.Update(u3)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u4, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	exist, u := e.GetByRank(0)
 	if !exist {
@@ -97,10 +97,10 @@ func TestRedundantEngineGetRange(t *testing.T) {
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
 	u4 := RankUnit{ID: 1025, Key: 20, Value: []byte("D.Va")}
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
-	e.Update(u2)
-	e.Update(u1)
-	e.Update(u4)
-	e.Update(u3)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u4, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	units := e.GetRange(0, 10)
 	if units == nil {
@@ -130,33 +130,45 @@ func TestRedundantEngineUpdate(t *testing.T) {
 	u5 := RankUnit{ID: 1025, Key: 1, Value: []byte("McCree")}
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
 
-	exist, _, _ := e.Update(u3)
+	exist, _, _, _ := e.Update(u3, UpdateModeReplace)
 	if exist {
 		t.Errorf("Expect ID %d not exist", u3.ID)
 	}
-	exist, _, _ = e.Update(u1)
+	exist, _, _, _ = e.Update(u1, UpdateModeReplace)
 	if exist {
 		t.Errorf("Expect ID %d not exist", u1.ID)
 	}
-	exist, _, _ = e.Update(u2)
+	exist, _, _, _ = e.Update(u2, UpdateModeReplace)
 	if exist {
 		t.Errorf("Expect ID %d not exist", u2.ID)
 	}
-	// 更新之前u3是redundant节点
-	exist, _, _ = e.Update(u4)
+	// key没有变化的redundant节点仍然是redundant节点
+	exist, _, _, changed := e.Update(u3, UpdateModeReplace)
+	if exist || changed {
+		t.Errorf("Expect ID %d not exist and not changed", u3.ID)
+	}
+	// 更新之前u3是redundant节点, key的变化按照保存的数据计算
+	exist, _, _, changed = e.Update(u4, UpdateModeReplace)
 	if exist {
 		t.Errorf("Expect ID %d not exist", u3.ID)
 	}
+	if !changed {
+		t.Errorf("Expect ID %d changed", u4.ID)
+	}
 
 	exist, _, u := e.Get(u4.ID)
 	if !exist {
 		t.Errorf("Expect ID %d exist", u4.ID)
 	}
 
-	exist, _, u = e.Update(u5)
+	// 更新之后u2成为redundant节点
+	exist, _, u, changed = e.Update(u5, UpdateModeReplace)
 	if !exist {
 		t.Errorf("Expect ID %d exist", u5.ID)
 	}
+	if !changed {
+		t.Errorf("Expect ID %d changed", u5.ID)
+	}
 	if err := checkUnitEqual(u, u2); err != nil {
 		t.Error(err)
 	}
@@ -167,9 +179,9 @@ func TestRedundantEngineDelete(t *testing.T) {
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
 	u3 := RankUnit{ID: 1026, Key: 4, Value: []byte("Sombra")}
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
-	e.Update(u1)
-	e.Update(u2)
-	e.Update(u3)
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	exist, _, _ := e.Delete(u3.ID)
 	if exist {
@@ -200,13 +212,13 @@ func TestRedundantEngineCreateSnapshot(t *testing.T) {
 	u5 := RankUnit{ID: 1027, Key: 30, Value: []byte("Reaper")}
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
 
-	e.Update(u1)
-	e.Update(u2)
-	e.Update(u4)
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u4, UpdateModeReplace)
 
 	snapshot := e.CreateSnapshot()
-	e.Update(u3)
-	e.Update(u5)
+	e.Update(u3, UpdateModeReplace)
+	e.Update(u5, UpdateModeReplace)
 
 	units := snapshot.GetRange(0, snapshot.Size())
 	if len(units) != int(snapshot.Config().MaxSize) {
@@ -229,9 +241,9 @@ func TestRedundantEngineKeyDecrease(t *testing.T) {
 	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1})
 
 	// 通过上报一个较大的Key后再上报一个较小的Key来挤出比自己更高排名的值
-	e.Update(u1)
-	e.Update(u2)
-	e.Update(u4)
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u4, UpdateModeReplace)
 
 	if e.Size() != uint32(2) {
 		t.Fatalf("Expect size 2, got: %d", e.Size())
@@ -250,13 +262,13 @@ func TestRedundantEngineKeyDecrease(t *testing.T) {
 		t.Errorf("Expect ID %d not exist", u1.ID)
 	}
 
-	e.Update(u3)
+	e.Update(u3, UpdateModeReplace)
 	exist, _, _ = e.Get(u1.ID)
 	if !exist {
 		t.Errorf("Expect ID %d exist", u1.ID)
 	}
 
-	e.Update(u5)
+	e.Update(u5, UpdateModeReplace)
 	// 这时候u1被隐藏，u3的key小于u1，RedundantNodeNum == 1，u3被淘汰
 	exist, _, _ = e.Get(u1.ID)
 	if exist {
@@ -274,12 +286,32 @@ func TestRedundantEngineClear(t *testing.T) {
 	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
 	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
 
-	e.Update(u1)
-	e.Update(u2)
-	e.Update(u3)
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
 
 	e.Clear()
 	if e.Size() != 0 {
 		t.Errorf("Expect empty rank, got: %d", e.Size())
 	}
 }
+
+func TestRedundantEngineAscending(t *testing.T) {
+	u1 := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1026, Key: 4, Value: []byte("Sombra")}
+	e := NewRedundantRankEngine(RankEngineConfig{MaxSize: 2, RedundantNodeNum: 1,
+		SortOrder: SortOrderAscending})
+	e.Update(u1, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
+
+	exist, pos, _ := e.Get(u3.ID)
+	if !exist || pos != 0 {
+		t.Errorf("Expect ID %d rank 0, got: %v %d", u3.ID, exist, pos)
+	}
+	exist, _, _ = e.Get(u2.ID)
+	if exist {
+		t.Errorf("Expect ID %d not exist", u2.ID)
+	}
+}
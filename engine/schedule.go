@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"fmt"
+	"time"
+)
+
+// 周期性的时间点, 每个时间点之后可以有一段持续时间
+// 实现: TimePeriod, CalendarSchedule, CronSchedule
+type Schedule interface {
+	Empty() bool
+	// 返回from之后的第一个时间点, 没有时返回零值
+	NextTime(from time.Time) time.Time
+	// 返回(from, to]之间的时间点数量, 以及第一个和最后一个时间点
+	Between(from, to time.Time) (int64, time.Time, time.Time)
+	// t是否在某个时间点开始的持续时间之内
+	Contains(t time.Time) bool
+	// t在某个时间点开始的持续时间之内时返回其结束时间, 否则返回零值
+	// 多个时间段重叠时返回最后开始的时间段的结束时间
+	WindowEnd(t time.Time) time.Time
+}
+
+// 一段明确的时间[Begin, End), 用于一次性的禁止更新时间段等
+type TimeRange struct {
+	Name  string
+	Begin time.Time
+	End   time.Time
+}
+
+func (r TimeRange) Contains(t time.Time) bool {
+	return !t.Before(r.Begin) && t.Before(r.End)
+}
+
+// 未配置或者配置为空的周期
+func ScheduleEmpty(s Schedule) bool {
+	return s == nil || s.Empty()
+}
+
+// 依次调用next统计(from, to]之间的时间点, next返回的时间点必须递增
+func scheduleBetween(next func(time.Time) time.Time,
+	from, to time.Time) (int64, time.Time, time.Time) {
+	var n int64
+	var first, last time.Time
+	for t := next(from); !t.IsZero() && !t.After(to); t = next(t) {
+		if n == 0 {
+			first = t
+		}
+		n++
+		last = t
+	}
+	return n, first, last
+}
+
+// t在[p, p + duration)之内等价于(t - duration, t]之间存在时间点p
+// 返回其中最后一个时间点开始的时间段的结束时间
+func scheduleWindowEnd(next func(time.Time) time.Time,
+	duration time.Duration, t time.Time) time.Time {
+	if duration <= 0 {
+		return time.Time{}
+	}
+	p := next(t.Add(-duration))
+	if p.IsZero() || p.After(t) {
+		return time.Time{}
+	}
+	for q := next(p); !q.IsZero() && !q.After(t); q = next(q) {
+		p = q
+	}
+	return p.Add(duration)
+}
+
+type CalendarUnit uint8
+
+const (
+	CalendarNone CalendarUnit = iota
+	CalendarDay
+	CalendarWeek
+	CalendarMonth
+)
+
+func (u CalendarUnit) String() string {
+	switch u {
+	case CalendarNone:
+		return "none"
+	case CalendarDay:
+		return "day"
+	case CalendarWeek:
+		return "week"
+	case CalendarMonth:
+		return "month"
+	}
+	return fmt.Sprintf("CalendarUnit(%d)", uint8(u))
+}
+
+func ParseCalendarUnit(s string) (CalendarUnit, error) {
+	switch s {
+	case "day":
+		return CalendarDay, nil
+	case "week":
+		return CalendarWeek, nil
+	case "month":
+		return CalendarMonth, nil
+	}
+	return CalendarNone, fmt.Errorf("Unknown calendar unit %q", s)
+}
+
+// 按日历重复的时间点, 在Location的当地时间计算, 不受夏令时影响
+// 每周一04:00: {Unit: CalendarWeek, Weekday: time.Monday, Hour: 4}
+// 每月1日00:00: {Unit: CalendarMonth, Day: 1}
+type CalendarSchedule struct {
+	Unit CalendarUnit
+	// 仅用于CalendarWeek
+	Weekday time.Weekday
+	// 仅用于CalendarMonth, 小于1时取1, 超过当月天数时取当月最后一天
+	Day    int
+	Hour   int
+	Minute int
+	Second int
+	// 为nil时使用UTC
+	Location *time.Location
+	// 不早于Start的时间点才有效, 为零值时不限制
+	Start time.Time
+	// 每个时间点之后的持续时间, 仅用于NoUpdatePeriod
+	Duration time.Duration
+}
+
+func (cs CalendarSchedule) Empty() bool {
+	return cs.Unit == CalendarNone
+}
+
+func (cs CalendarSchedule) location() *time.Location {
+	if cs.Location == nil {
+		return time.UTC
+	}
+	return cs.Location
+}
+
+// 返回year年month月所在周期的时间点, day为当天或者当月的日期
+func (cs CalendarSchedule) at(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, cs.Hour, cs.Minute, cs.Second, 0,
+		cs.location())
+}
+
+func (cs CalendarSchedule) NextTime(from time.Time) time.Time {
+	if cs.Empty() {
+		return time.Time{}
+	}
+	if !cs.Start.IsZero() && cs.Start.After(from) {
+		from = cs.Start.Add(-time.Nanosecond)
+	}
+	local := from.In(cs.location())
+	year, month, day := local.Date()
+	switch cs.Unit {
+	case CalendarDay:
+		for i := 0; ; i++ {
+			if next := cs.at(year, month, day+i); next.After(from) {
+				return next
+			}
+		}
+	case CalendarWeek:
+		day += (int(cs.Weekday) - int(local.Weekday()) + 7) % 7
+		for i := 0; ; i += 7 {
+			if next := cs.at(year, month, day+i); next.After(from) {
+				return next
+			}
+		}
+	case CalendarMonth:
+		for i := 0; ; i++ {
+			// 下个月的第0天即当月最后一天
+			lastDay := time.Date(year, month+time.Month(i)+1, 0, 0, 0, 0, 0,
+				cs.location()).Day()
+			d := cs.Day
+			if d < 1 {
+				d = 1
+			} else if d > lastDay {
+				d = lastDay
+			}
+			if next := cs.at(year, month+time.Month(i), d); next.After(from) {
+				return next
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (cs CalendarSchedule) Between(from, to time.Time) (int64, time.Time,
+	time.Time) {
+	return scheduleBetween(cs.NextTime, from, to)
+}
+
+func (cs CalendarSchedule) Contains(t time.Time) bool {
+	return !cs.WindowEnd(t).IsZero()
+}
+
+func (cs CalendarSchedule) WindowEnd(t time.Time) time.Time {
+	return scheduleWindowEnd(cs.NextTime, cs.Duration, t)
+}
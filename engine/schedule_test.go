@@ -0,0 +1,115 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func loadTestLocation(t *testing.T, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("Load location %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestCalendarScheduleWeekAcrossDST(t *testing.T) {
+	loc := loadTestLocation(t, "America/New_York")
+	cs := CalendarSchedule{
+		Unit:     CalendarWeek,
+		Weekday:  time.Monday,
+		Hour:     4,
+		Location: loc,
+	}
+	// 2017-03-12开始夏令时, 每周一仍然是当地时间04:00
+	from := time.Date(2017, 3, 6, 5, 0, 0, 0, loc)
+	to := time.Date(2017, 3, 27, 4, 0, 0, 0, loc)
+	n, first, last := cs.Between(from, to)
+	if n != 3 {
+		t.Fatalf("Expect 3 time points, got: %d", n)
+	}
+	if !first.Equal(time.Date(2017, 3, 13, 4, 0, 0, 0, loc)) || !last.Equal(to) {
+		t.Errorf("Expect [%s, %s], got: [%s, %s]", time.Date(2017, 3, 13, 4, 0,
+			0, 0, loc), to, first, last)
+	}
+	if first.Sub(time.Date(2017, 3, 6, 4, 0, 0, 0, loc)) != time.Hour*167 {
+		t.Errorf("Expect 167 hours across DST, got: %s",
+			first.Sub(time.Date(2017, 3, 6, 4, 0, 0, 0, loc)))
+	}
+}
+
+func TestCalendarScheduleMonth(t *testing.T) {
+	cs := CalendarSchedule{Unit: CalendarMonth, Day: 31}
+	from := time.Date(2017, 1, 31, 0, 0, 0, 0, time.UTC)
+	expect := []time.Time{
+		time.Date(2017, 2, 28, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 3, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2017, 4, 30, 0, 0, 0, 0, time.UTC),
+	}
+	for _, e := range expect {
+		next := cs.NextTime(from)
+		if !next.Equal(e) {
+			t.Errorf("NextTime(%s): expect %s, got: %s", from, e, next)
+		}
+		from = next
+	}
+
+	first := CalendarSchedule{Unit: CalendarMonth, Day: 1}
+	next := first.NextTime(time.Date(2017, 12, 15, 0, 0, 0, 0, time.UTC))
+	if !next.Equal(time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expect first day of 2018, got: %s", next)
+	}
+}
+
+func TestCalendarScheduleStart(t *testing.T) {
+	start := time.Date(2017, 3, 23, 12, 0, 0, 0, time.UTC)
+	cs := CalendarSchedule{Unit: CalendarDay, Start: start}
+	next := cs.NextTime(time.Time{})
+	if !next.Equal(time.Date(2017, 3, 24, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expect first time point after start, got: %s", next)
+	}
+	cs.Hour = 12
+	if next = cs.NextTime(time.Time{}); !next.Equal(start) {
+		t.Errorf("Expect start %s, got: %s", start, next)
+	}
+}
+
+func TestScheduleContains(t *testing.T) {
+	loc := loadTestLocation(t, "Asia/Shanghai")
+	day := time.Date(2017, 3, 23, 0, 0, 0, 0, loc)
+	cs := CalendarSchedule{
+		Unit:     CalendarDay,
+		Hour:     4,
+		Location: loc,
+		Duration: time.Minute * 10,
+	}
+	tp := TimePeriod{
+		Start:    day.Add(time.Hour*4 - time.Hour*24*10),
+		Interval: time.Hour * 24,
+		Duration: time.Minute * 10,
+	}
+	cases := []struct {
+		t      time.Time
+		expect bool
+	}{
+		{day.Add(time.Hour*4 - time.Second), false},
+		{day.Add(time.Hour * 4), true},
+		{day.Add(time.Hour*4 + time.Minute*5), true},
+		{day.Add(time.Hour*4 + time.Minute*10), false},
+	}
+	for _, c := range cases {
+		if cs.Contains(c.t) != c.expect {
+			t.Errorf("CalendarSchedule.Contains(%s): expect %v", c.t, c.expect)
+		}
+		// 不只是第一个时间段
+		if tp.Contains(c.t) != c.expect {
+			t.Errorf("TimePeriod.Contains(%s): expect %v", c.t, c.expect)
+		}
+	}
+
+	var s Schedule
+	if !ScheduleEmpty(s) || !ScheduleEmpty(TimePeriod{}) ||
+		!ScheduleEmpty(CalendarSchedule{}) || ScheduleEmpty(cs) {
+		t.Error("Unexpected ScheduleEmpty result")
+	}
+}
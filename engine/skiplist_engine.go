@@ -0,0 +1,410 @@
+package engine
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	skipListMaxLevel    = 32
+	skipListProbability = 0.25
+)
+
+type skipListLevel struct {
+	forward *skipListNode
+	// 当前节点到forward之间跨越的节点数量
+	span uint32
+}
+
+type skipListNode struct {
+	unit   RankUnit
+	levels []skipListLevel
+}
+
+// 基于带跨度索引的跳表实现的排行榜
+// Get, Update, Delete, GetByRank的复杂度都是O(log n)
+type SkipListRankEngine struct {
+	config           RankEngineConfig
+	header           *skipListNode
+	level            int
+	length           uint32
+	nodes            map[uint64]*skipListNode
+	nextSeq          uint64
+	rand             *rand.Rand
+	lastClearTime    time.Time
+	lastSnapshotTime time.Time
+	groups           keyGroups
+}
+
+func init() {
+	RegisterRankEngine(EngineKindSkipList, func(config RankEngineConfig) RankEngine {
+		return NewSkipListRankEngine(config)
+	})
+}
+
+func NewSkipListRankEngine(config RankEngineConfig) *SkipListRankEngine {
+	e := &SkipListRankEngine{
+		config: config,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	e.Clear()
+	return e
+}
+
+func newSkipListNode(level int, unit RankUnit) *skipListNode {
+	return &skipListNode{
+		unit:   unit,
+		levels: make([]skipListLevel, level),
+	}
+}
+
+// a是否排在b前面
+func (e *SkipListRankEngine) less(a, b *skipListNode) bool {
+	return e.config.Before(a.unit, b.unit)
+}
+
+func (e *SkipListRankEngine) randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && e.rand.Float64() < skipListProbability {
+		level++
+	}
+	return level
+}
+
+func (e *SkipListRankEngine) insert(unit RankUnit) *skipListNode {
+	var update [skipListMaxLevel]*skipListNode
+	var rank [skipListMaxLevel]uint32
+	node := newSkipListNode(e.randomLevel(), unit)
+	if unit.Seq >= e.nextSeq {
+		e.nextSeq = unit.Seq + 1
+	}
+
+	x := e.header
+	for i := e.level - 1; i >= 0; i-- {
+		if i != e.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && e.less(x.levels[i].forward, node) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	level := len(node.levels)
+	if level > e.level {
+		for i := e.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = e.header
+			update[i].levels[i].span = e.length
+		}
+		e.level = level
+	}
+
+	for i := 0; i < level; i++ {
+		node.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = node
+		node.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < e.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	e.length++
+	e.nodes[unit.ID] = node
+	e.groups.reset()
+	return node
+}
+
+func (e *SkipListRankEngine) remove(node *skipListNode) {
+	var update [skipListMaxLevel]*skipListNode
+	x := e.header
+	for i := e.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && e.less(x.levels[i].forward, node) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	for i := 0; i < e.level; i++ {
+		if update[i].levels[i].forward == node {
+			update[i].levels[i].span += node.levels[i].span - 1
+			update[i].levels[i].forward = node.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	for e.level > 1 && e.header.levels[e.level-1].forward == nil {
+		e.level--
+	}
+
+	e.length--
+	delete(e.nodes, node.unit.ID)
+	e.groups.reset()
+}
+
+// 返回节点的排名, 从0开始
+func (e *SkipListRankEngine) rankOf(node *skipListNode) uint32 {
+	var rank uint32
+	x := e.header
+	for i := e.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && !e.less(node, x.levels[i].forward) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if x == node {
+			break
+		}
+	}
+	return rank - 1
+}
+
+func (e *SkipListRankEngine) nodeAt(pos uint32) *skipListNode {
+	if pos >= e.length {
+		return nil
+	}
+	var traversed uint32
+	x := e.header
+	for i := e.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= pos+1 {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == pos+1 {
+			return x
+		}
+	}
+	return nil
+}
+
+func (e *SkipListRankEngine) Config() RankEngineConfig {
+	return e.config
+}
+
+func (e *SkipListRankEngine) SetConfig(config RankEngineConfig) error {
+	if err := ConfigChangeError(e.config, config); err != nil {
+		return err
+	}
+	e.config = config
+	e.evict()
+	return nil
+}
+
+func (e *SkipListRankEngine) Size() uint32 {
+	return e.length
+}
+
+func (e *SkipListRankEngine) Get(id uint64) (bool, uint32, RankUnit) {
+	node, exist := e.nodes[id]
+	if !exist {
+		return false, 0, RankUnit{}
+	}
+	return true, e.rankOf(node), node.unit
+}
+
+func (e *SkipListRankEngine) GetByRank(pos uint32) (bool, RankUnit) {
+	node := e.nodeAt(pos)
+	if node == nil {
+		return false, RankUnit{}
+	}
+	return true, node.unit
+}
+
+func (e *SkipListRankEngine) GetRange(pos, num uint32) []RankUnit {
+	if pos >= e.Size() {
+		return nil
+	}
+	n := num
+	if num >= e.Size()-pos {
+		n = e.Size() - pos
+	}
+	result := make([]RankUnit, n)
+	node := e.nodeAt(pos)
+	for i := uint32(0); i < n; i++ {
+		result[i] = node.unit
+		node = node.levels[0].forward
+	}
+	return result
+}
+
+func (e *SkipListRankEngine) GetAround(id uint64,
+	before, after uint32) (bool, uint32, uint32, []RankUnit) {
+	return getAround(e, id, before, after)
+}
+
+func (e *SkipListRankEngine) RankOfKey(key uint64) uint32 {
+	var rank uint32
+	x := e.header
+	for i := e.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil &&
+			e.config.SortOrder.Before(x.levels[i].forward.unit.Key, key) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+		}
+	}
+	return rank
+}
+
+func (e *SkipListRankEngine) GetByKeyRange(min, max uint64,
+	limit uint32) (uint32, []RankUnit) {
+	return getByKeyRange(e, min, max, limit)
+}
+
+func (e *SkipListRankEngine) DistinctKeysBefore(pos uint32) uint32 {
+	if !e.groups.valid {
+		var i uint32
+		for x := e.header.levels[0].forward; x != nil; x = x.levels[0].forward {
+			e.groups.add(i, x.unit.Key)
+			i++
+		}
+		e.groups.valid = true
+	}
+	return e.groups.before(pos)
+}
+
+func (e *SkipListRankEngine) Update(u RankUnit,
+	mode UpdateMode) (bool, uint32, RankUnit, bool) {
+	var pos uint32
+	var old RankUnit
+	node, exist := e.nodes[u.ID]
+	if exist {
+		pos = e.rankOf(node)
+		old = node.unit
+	}
+	changed := e.update(node, u, mode)
+	e.evict()
+	return exist, pos, old, changed
+}
+
+func (e *SkipListRankEngine) UpdateMany(units []RankUnit,
+	mode UpdateMode) []bool {
+	changed := make([]bool, len(units))
+	for i, u := range units {
+		changed[i] = e.update(e.nodes[u.ID], u, mode)
+	}
+	e.evict()
+	return changed
+}
+
+// 更新node对应的数据, node为nil表示数据不存在, 返回Key是否发生了变化
+func (e *SkipListRankEngine) update(node *skipListNode, u RankUnit,
+	mode UpdateMode) bool {
+	exist := node != nil
+	var old RankUnit
+	if exist {
+		old = node.unit
+	}
+	u, ok := mode.Apply(exist, old, u)
+	if !ok {
+		return false
+	}
+	if exist {
+		if old.Key == u.Key {
+			// Key没有变化时排名不变, 直接替换数据
+			u.Seq = old.Seq
+			node.unit = u
+			return false
+		}
+		e.remove(node)
+	}
+	u.Seq = e.nextSeq
+	e.insert(u)
+	return true
+}
+
+// 超过最大上限时淘汰排在最后的数据
+func (e *SkipListRankEngine) evict() {
+	for e.config.MaxSize != 0 && e.Size() > e.config.MaxSize {
+		e.remove(e.nodeAt(e.Size() - 1))
+	}
+}
+
+func (e *SkipListRankEngine) Delete(id uint64) (bool, uint32, RankUnit) {
+	node, exist := e.nodes[id]
+	if !exist {
+		return false, 0, RankUnit{}
+	}
+	pos := e.rankOf(node)
+	e.remove(node)
+	return true, pos, node.unit
+}
+
+func (e *SkipListRankEngine) CreateSnapshot() RankEngine {
+	snapshot := NewSkipListRankEngine(e.config)
+	snapshot.nextSeq = e.nextSeq
+	snapshot.lastClearTime = e.lastClearTime
+	snapshot.lastSnapshotTime = e.lastSnapshotTime
+	for x := e.header.levels[0].forward; x != nil; x = x.levels[0].forward {
+		u := x.unit
+		u.Value = append([]byte(nil), x.unit.Value...)
+		snapshot.insert(u)
+	}
+	return snapshot
+}
+
+func (e *SkipListRankEngine) Clear() {
+	e.header = newSkipListNode(skipListMaxLevel, RankUnit{})
+	e.level = 1
+	e.length = 0
+	e.nodes = make(map[uint64]*skipListNode)
+	e.groups.reset()
+}
+
+func (e *SkipListRankEngine) CopyFrom(rank RankEngine) {
+	units := rank.GetRange(0, rank.Size())
+	e.Clear()
+	e.SetLastClearTime(rank.LastClearTime())
+	e.SetLastSnapshotTime(rank.LastSnapshotTime())
+	// 保留原有的更新序号, 使并列数据的先后顺序与原排行榜一致
+	for i := 0; i < len(units); i++ {
+		e.insert(units[i])
+		e.evict()
+	}
+}
+
+func (e *SkipListRankEngine) MarshalBinary() ([]byte, error) {
+	state := rankEngineState{
+		kind:             e.config.EngineKind(),
+		fingerprint:      e.config.Fingerprint(),
+		lastClearTime:    e.lastClearTime,
+		lastSnapshotTime: e.lastSnapshotTime,
+		nextSeq:          e.nextSeq,
+		units:            e.GetRange(0, e.Size()),
+	}
+	return marshalRankEngineState(state)
+}
+
+func (e *SkipListRankEngine) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalRankEngineState(data, e.config)
+	if err != nil {
+		return err
+	}
+	e.Clear()
+	for _, u := range state.units {
+		e.insert(u)
+	}
+	e.evict()
+	if state.nextSeq > e.nextSeq {
+		e.nextSeq = state.nextSeq
+	}
+	e.lastClearTime = state.lastClearTime
+	e.lastSnapshotTime = state.lastSnapshotTime
+	return nil
+}
+
+func (e *SkipListRankEngine) LastClearTime() time.Time {
+	return e.lastClearTime
+}
+
+func (e *SkipListRankEngine) SetLastClearTime(t time.Time) {
+	e.lastClearTime = t
+}
+
+func (e *SkipListRankEngine) LastSnapshotTime() time.Time {
+	return e.lastSnapshotTime
+}
+
+func (e *SkipListRankEngine) SetLastSnapshotTime(t time.Time) {
+	e.lastSnapshotTime = t
+}
@@ -0,0 +1,276 @@
+package engine
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSkipListEngineGet(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 10})
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+
+	exist, pos, out := e.Get(u.ID)
+	if !exist {
+		t.Errorf("ID %d not exist", u.ID)
+	}
+	if pos != 1 {
+		t.Errorf("Expect rank 1, got: %d", pos)
+	}
+	if err := checkUnitEqual(u, out); err != nil {
+		t.Error(err)
+	}
+
+	exist, pos, out = e.Get(u2.ID)
+	if !exist {
+		t.Errorf("ID %d not exist", u2.ID)
+	}
+	if pos != 0 {
+		t.Errorf("Expect rank 0, got: %d", pos)
+	}
+	if err := checkUnitEqual(u2, out); err != nil {
+		t.Error(err)
+	}
+
+	exist, _, _ = e.Get(1000)
+	if exist {
+		t.Error("Expect ID 1000 not exist")
+	}
+}
+
+func TestSkipListEngineGetByRank(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 10})
+	exist, out := e.GetByRank(0)
+	if exist {
+		t.Error("Found unit in engine")
+	}
+
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	exist, out = e.GetByRank(0)
+	if !exist {
+		t.Error("Rank 0 not found")
+	}
+	if err := checkUnitEqual(u2, out); err != nil {
+		t.Error(err)
+	}
+
+	exist, out = e.GetByRank(1)
+	if !exist {
+		t.Error("Rank 1 not found")
+	}
+	if err := checkUnitEqual(u, out); err != nil {
+		t.Error(err)
+	}
+
+	exist, out = e.GetByRank(e.Size() + 10)
+	if exist {
+		t.Errorf("Rank %d found", e.Size()+10)
+	}
+}
+
+func TestSkipListEngineUpdate(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 10})
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1024, Key: 14, Value: []byte("Sombra")}
+	exist, _, _, _ := e.Update(u, UpdateModeReplace)
+	if exist {
+		t.Errorf("Found same ID: %d", u.ID)
+	}
+	e.Update(u2, UpdateModeReplace)
+	exist, pos, out, _ := e.Update(u3, UpdateModeReplace)
+	if !exist {
+		t.Errorf("ID %d not found", u3.ID)
+	}
+	if pos != 1 {
+		t.Errorf("Expect pos 1, got: %d", pos)
+	}
+	if err := checkUnitEqual(u, out); err != nil {
+		t.Error(err)
+	}
+	exist, pos, out = e.Get(u3.ID)
+	if pos != 0 {
+		t.Errorf("Expect rank 0, got: %d", pos)
+	}
+	if err := checkUnitEqual(u3, out); err != nil {
+		t.Error(err)
+	}
+	if e.Size() != 2 {
+		t.Errorf("Expect size 2, got: %d", e.Size())
+	}
+}
+
+func TestSkipListEngineDelete(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 10})
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
+
+	exist, _, _ := e.Delete(1000)
+	if exist {
+		t.Error("Expect ID 1000 not found")
+	}
+
+	exist, pos, out := e.Delete(u3.ID)
+	if !exist {
+		t.Errorf("Expect %d exist", u3.ID)
+	}
+	if pos != 0 {
+		t.Errorf("Expect rank 0, got: %d", pos)
+	}
+	if err := checkUnitEqual(u3, out); err != nil {
+		t.Error(err)
+	}
+	if e.Size() != 2 {
+		t.Errorf("Expect size 2, got: %d", e.Size())
+	}
+
+	_, out = e.GetByRank(0)
+	if err := checkUnitEqual(out, u2); err != nil {
+		t.Error(err)
+	}
+
+	_, out = e.GetByRank(1)
+	if err := checkUnitEqual(out, u); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSkipListEngineGetRange(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 10})
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
+
+	units := e.GetRange(1, 1)
+	if len(units) != 1 {
+		t.Fatalf("Expect 1 units, got: %d", len(units))
+	}
+	if err := checkUnitEqual(units[0], u2); err != nil {
+		t.Error(err)
+	}
+
+	units = e.GetRange(e.Size()+1, 1)
+	if units != nil {
+		t.Error("Expect nil result")
+	}
+
+	units = e.GetRange(0, e.Size()+100)
+	if len(units) != int(e.Size()) {
+		t.Errorf("Expect %d units, got: %d", e.Size(), len(units))
+	}
+}
+
+func TestSkipListEngineMaxSize(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 2})
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	e.Update(u3, UpdateModeReplace)
+
+	if e.Size() != 2 {
+		t.Errorf("Expect size 2, got: %d", e.Size())
+	}
+	exist, _, _ := e.Get(u.ID)
+	if exist {
+		t.Errorf("Expect ID %d not exist", u.ID)
+	}
+	_, out := e.GetByRank(0)
+	if err := checkUnitEqual(u3, out); err != nil {
+		t.Error(err)
+	}
+	_, out = e.GetByRank(1)
+	if err := checkUnitEqual(u2, out); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSkipListEngineSnapshot(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 2})
+	u := RankUnit{ID: 1024, Key: 10, Value: []byte("Soldier76")}
+	u2 := RankUnit{ID: 1025, Key: 12, Value: []byte("McCree")}
+	u3 := RankUnit{ID: 1026, Key: 14, Value: []byte("Sombra")}
+	u4 := RankUnit{ID: 1025, Key: 12, Value: []byte("D.Va")}
+
+	e.Update(u, UpdateModeReplace)
+	e.Update(u2, UpdateModeReplace)
+	snapshot := e.CreateSnapshot()
+	if snapshot.Size() != 2 {
+		t.Errorf("Expect size 2, got %d", snapshot.Size())
+	}
+	e.Update(u3, UpdateModeReplace)
+	e.Update(u4, UpdateModeReplace)
+
+	_, _, v := snapshot.Get(u2.ID)
+	if err := checkUnitEqual(v, u2); err != nil {
+		t.Error(err)
+	}
+	_, _, v = e.Get(u2.ID)
+	if err := checkUnitEqual(v, u4); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSkipListEngineClear(t *testing.T) {
+	e := NewSkipListRankEngine(RankEngineConfig{MaxSize: 2})
+	e.Update(RankUnit{ID: 1024, Key: 10}, UpdateModeReplace)
+	e.Update(RankUnit{ID: 1025, Key: 12}, UpdateModeReplace)
+
+	e.Clear()
+	if e.Size() != 0 {
+		t.Errorf("Expect empty rank, got: %d", e.Size())
+	}
+	exist, _, _ := e.Get(1024)
+	if exist {
+		t.Error("Expect ID 1024 not exist")
+	}
+}
+
+// 随机操作后与ArrayRankEngine的结果对比
+func TestSkipListEngineMatchArrayEngine(t *testing.T) {
+	for _, order := range []SortOrder{SortOrderDescending, SortOrderAscending} {
+		config := RankEngineConfig{MaxSize: 64, SortOrder: order}
+		a := NewArrayRankEngine(config)
+		s := NewSkipListRankEngine(config)
+		r := rand.New(rand.NewSource(1))
+		for i := 0; i < 10000; i++ {
+			// Key各不相同, 避免两种实现并列时的顺序差异
+			u := RankUnit{ID: uint64(r.Intn(100)), Key: uint64(r.Int63())}
+			if r.Intn(4) == 0 {
+				a.Delete(u.ID)
+				s.Delete(u.ID)
+			} else {
+				a.Update(u, UpdateModeReplace)
+				s.Update(u, UpdateModeReplace)
+			}
+			if a.Size() != s.Size() {
+				t.Fatalf("%s: Expect size %d, got: %d", order, a.Size(), s.Size())
+			}
+		}
+		expect := a.GetRange(0, a.Size())
+		units := s.GetRange(0, s.Size())
+		for i := range expect {
+			if err := checkUnitEqual(expect[i], units[i]); err != nil {
+				t.Fatalf("%s: %v", order, err)
+			}
+			_, pos, _ := s.Get(expect[i].ID)
+			if pos != uint32(i) {
+				t.Fatalf("%s: Expect ID %d rank %d, got: %d",
+					order, expect[i].ID, i, pos)
+			}
+		}
+	}
+}
@@ -17,8 +17,8 @@ func (tp TimePeriod) Empty() bool {
 	return tp.Start.IsZero()
 }
 
-func (tp TimePeriod) NextTimeFromNow() time.Time {
-	return tp.NextTime(time.Now())
+func (tp TimePeriod) NextTimeFromNow(clock Clock) time.Time {
+	return tp.NextTime(clock.Now())
 }
 
 func (tp TimePeriod) NextTime(from time.Time) time.Time {
@@ -39,22 +39,37 @@ func (tp TimePeriod) NextTime(from time.Time) time.Time {
 	return next
 }
 
+// 返回(from, to]之间的时间点数量, 以及第一个和最后一个时间点
+func (tp TimePeriod) Between(from, to time.Time) (int64, time.Time, time.Time) {
+	if tp.Interval == 0 {
+		if tp.Start.After(from) && !tp.Start.After(to) {
+			return 1, tp.Start, tp.Start
+		}
+		return 0, time.Time{}, time.Time{}
+	}
+	first := tp.NextTime(from)
+	if first.After(to) {
+		return 0, time.Time{}, time.Time{}
+	}
+	n := to.Sub(first) / tp.Interval
+	return int64(n) + 1, first, first.Add(n * tp.Interval)
+}
+
 func (tp TimePeriod) Contains(t time.Time) bool {
+	return !tp.WindowEnd(t).IsZero()
+}
+
+func (tp TimePeriod) WindowEnd(t time.Time) time.Time {
 	if tp.Interval == 0 {
-		return false
+		return time.Time{}
 	}
 	if tp.Start.After(t) {
-		return false
+		return time.Time{}
 	}
-	// Pre-condition: tp.Start <= t
-	next := tp.Start.Add(tp.Interval)
-
-	// Post-condition: start <= t && t < next
-	start := tp.Start
-	for next.Before(t) || next.Equal(t) {
-		start.Add(tp.Interval)
-		next = next.Add(tp.Interval)
+	// 最近一个不晚于t的时间点
+	start := tp.Start.Add(t.Sub(tp.Start) / tp.Interval * tp.Interval)
+	if end := start.Add(tp.Duration); t.Before(end) {
+		return end
 	}
-
-	return t.Before(start.Add(tp.Duration))
+	return time.Time{}
 }
@@ -0,0 +1,39 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimePeriodBetween(t *testing.T) {
+	start := time.Date(2017, 3, 23, 17, 18, 0, 0, time.UTC)
+	tp := TimePeriod{Start: start, Interval: time.Hour}
+	cases := []struct {
+		from, to    time.Time
+		n           int64
+		first, last time.Time
+	}{
+		{start.Add(-time.Hour), start.Add(-time.Minute), 0, time.Time{}, time.Time{}},
+		{start.Add(-time.Hour), start, 1, start, start},
+		{start, start.Add(time.Hour), 1, start.Add(time.Hour), start.Add(time.Hour)},
+		{start.Add(time.Minute), start.Add(time.Hour * 5),
+			5, start.Add(time.Hour), start.Add(time.Hour * 5)},
+		{start.Add(time.Minute), start.Add(time.Hour*5 - time.Second),
+			4, start.Add(time.Hour), start.Add(time.Hour * 4)},
+	}
+	for _, c := range cases {
+		n, first, last := tp.Between(c.from, c.to)
+		if n != c.n || !first.Equal(c.first) || !last.Equal(c.last) {
+			t.Errorf("Between(%s, %s): expect (%d, %s, %s), got: (%d, %s, %s)",
+				c.from, c.to, c.n, c.first, c.last, n, first, last)
+		}
+	}
+
+	once := TimePeriod{Start: start}
+	if n, _, _ := once.Between(start, start.Add(time.Hour)); n != 0 {
+		t.Errorf("Expect 0 time point, got: %d", n)
+	}
+	if n, first, _ := once.Between(time.Time{}, start); n != 1 || !first.Equal(start) {
+		t.Errorf("Expect time point %s, got: %d %s", start, n, first)
+	}
+}
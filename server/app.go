@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"net"
 	"os"
 	"os/signal"
@@ -9,53 +10,82 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/serverproto"
 )
 
 type App struct {
+	clock             engine.Clock
 	wg                sync.WaitGroup
 	doneChan          chan struct{}
 	config            AppConfig
 	dispatcher        *Dispatcher
+	tcpClientsMutex   sync.Mutex
 	tcpClients        []*TCPClient
-	nextDynamicRankID uint32
 	ranks             map[uint32]engine.RankEngine
 	tcpClientListener *net.TCPListener
+	tcpAdminListener  *net.TCPListener
+	tcpServerListener *net.TCPListener
 }
 
-func NewApp(config AppConfig) *App {
+func NewApp(config AppConfig, clock engine.Clock) *App {
 	return &App{
-		doneChan:          make(chan struct{}),
-		config:            config,
-		nextDynamicRankID: 1,
-		ranks:             make(map[uint32]engine.RankEngine),
+		clock:    clock,
+		doneChan: make(chan struct{}),
+		config:   config,
+		ranks:    make(map[uint32]engine.RankEngine),
 	}
 }
 
 func (app *App) AddRank(rankID uint32,
 	rankConfig engine.RankEngineConfig) error {
-	app.ranks[rankID] = engine.NewRankEngine(rankConfig)
+	if rankID >= DYNAMIC_RANK_ID_BASE {
+		return fmt.Errorf("Rank %d not less than dynamic rank id base %d", rankID,
+			DYNAMIC_RANK_ID_BASE)
+	}
+	if _, exist := app.ranks[rankID]; exist {
+		return fmt.Errorf("Rank %d already exist", rankID)
+	}
+	rank, err := engine.NewRankEngine(rankConfig)
+	if err != nil {
+		return fmt.Errorf("Create rank %d: %v", rankID, err)
+	}
+	app.ranks[rankID] = rank
 	return nil
 }
 
 func (app *App) Run() {
 	var err error
-	if app.dispatcher, err = NewDispatcher(app.ranks); err != nil {
+	app.dispatcher, err = NewDispatcher(app.ranks, app.config, app.clock)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	if err = app.dispatcher.Recover(); err != nil {
 		glog.Fatal(err)
 	}
 	app.dispatcher.Start()
 	app.wg.Add(1)
 	go app.AcceptClientConnections()
 	app.wg.Add(1)
+	go app.AcceptAdminConnections()
+	app.wg.Add(1)
 	go app.AcceptServerConnections()
 	app.WaitForExit()
 	close(app.doneChan)
 	if app.tcpClientListener != nil {
 		app.tcpClientListener.Close()
 	}
+	if app.tcpAdminListener != nil {
+		app.tcpAdminListener.Close()
+	}
+	if app.tcpServerListener != nil {
+		app.tcpServerListener.Close()
+	}
 	app.dispatcher.Stop()
+	app.tcpClientsMutex.Lock()
 	for _, tcpClient := range app.tcpClients {
 		tcpClient.StopAndWait()
 	}
+	app.tcpClientsMutex.Unlock()
 	app.wg.Wait()
 }
 
@@ -67,6 +97,25 @@ func (app *App) AcceptClientConnections() {
 	}
 	listener, _ := l.(*net.TCPListener)
 	app.tcpClientListener = listener
+	app.acceptTCPClients(listener, false)
+}
+
+// 管理端口的连接可以发送管理请求, 见AppConfig.AcceptAdminAddress
+func (app *App) AcceptAdminConnections() {
+	defer app.wg.Done()
+	if app.config.AcceptAdminAddress == "" {
+		return
+	}
+	l, err := net.Listen("tcp", app.config.AcceptAdminAddress)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	listener, _ := l.(*net.TCPListener)
+	app.tcpAdminListener = listener
+	app.acceptTCPClients(listener, true)
+}
+
+func (app *App) acceptTCPClients(listener *net.TCPListener, privileged bool) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
@@ -79,18 +128,63 @@ func (app *App) AcceptClientConnections() {
 		}
 		glog.V(2).Infof("New connection %s", conn.RemoteAddr())
 		tcpConn, _ := conn.(*net.TCPConn)
-		client := NewTCPClient(app.dispatcher, tcpConn)
+		client := NewTCPClient(app.dispatcher, tcpConn, privileged)
+		app.tcpClientsMutex.Lock()
 		app.tcpClients = append(app.tcpClients, client)
+		app.tcpClientsMutex.Unlock()
 		go client.Run()
 	}
 }
 
+// 接受副本的连接, 作为副本运行时改为连接主节点
 func (app *App) AcceptServerConnections() {
 	defer app.wg.Done()
+	if app.config.ReplicaOf != "" {
+		ReplicateFrom(app.dispatcher, app.config.ReplicaOf, app.doneChan)
+		return
+	}
+	if app.config.AcceptServerAddress == "" {
+		return
+	}
+	l, err := net.Listen("tcp", app.config.AcceptServerAddress)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	listener, _ := l.(*net.TCPListener)
+	app.tcpServerListener = listener
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-app.doneChan:
+				return
+			default:
+			}
+			glog.Fatal(err)
+		}
+		glog.Infof("New replica connection %s", conn.RemoteAddr())
+		app.wg.Add(1)
+		go func() {
+			defer app.wg.Done()
+			err := ServeReplica(app.dispatcher, conn, app.doneChan)
+			glog.Infof("Replica %s done: %v", conn.RemoteAddr(), err)
+		}()
+	}
 }
 
+// 收到SIGHUP时重新加载配置文件, 其他信号退出
 func (app *App) WaitForExit() {
-	ch := make(chan os.Signal)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-	glog.Infof("Signal %s", <-ch)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range ch {
+		glog.Infof("Signal %s", sig)
+		if sig != syscall.SIGHUP {
+			return
+		}
+		jobResult := app.dispatcher.RequestReload()
+		resp, _ := jobResult.Msg.(*serverproto.ReloadConfigResponse)
+		for _, reason := range resp.GetRejected() {
+			glog.Warningf("Reload config rejected: %s", reason)
+		}
+	}
 }
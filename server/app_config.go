@@ -1,6 +1,26 @@
 package server
 
+import "time"
+
 type AppConfig struct {
 	AcceptClientAddress string
 	AcceptServerAddress string
+	// 管理端口, 只有这里可以创建, 删除排行榜, 修改禁止更新时间段以及重新加载配置
+	// 为空时不接受来自网络的管理请求
+	AcceptAdminAddress string
+	// 排行榜配置文件, 收到SIGHUP或者ReloadConfigRequest时重新加载
+	ConfigFile string
+	// WAL以及转储所在的目录, 为空时不记录WAL
+	DataDir         string
+	WALSyncPolicy   WALSyncPolicy
+	WALSyncInterval time.Duration
+	// 定期转储的间隔, 为0时只在退出时转储
+	CheckpointInterval time.Duration
+	// 保留的转储数量, 至少保留一个
+	CheckpointRetain int
+	// 排行榜清空或者被快照覆盖之前结算的接收方
+	SettlementSinks []SettlementSink
+	// 主节点的server_address, 不为空时作为副本运行
+	// 副本的排行榜全部来自主节点, 不能配置DataDir以及SettlementSinks
+	ReplicaOf string
 }
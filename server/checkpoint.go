@@ -0,0 +1,355 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jacobwpeng/goutil"
+	"github.com/jacobwpeng/sirius/engine"
+)
+
+const (
+	CHECKPOINT_MAGIC   = 0x53524b43
+	CHECKPOINT_VERSION = 3
+	CHECKPOINT_PREFIX  = "checkpoint-"
+	CHECKPOINT_SUFFIX  = ".dat"
+	MAX_CHECKPOINT_NUM = 1024
+	// 每个排行榜最多的历史排行榜数量
+	MAX_CHECKPOINT_HISTORY_NUM = 1024
+)
+
+// 一个RankHandler所有排行榜的转储
+// LSN之前的WAL记录都已经包含在转储中
+type Checkpoint struct {
+	LSN             uint64
+	Ranks           map[uint32][]byte
+	History         []CheckpointHistory
+	NoUpdateWindows map[uint32]*NoUpdateWindows
+}
+
+// 转储中的历史排行榜, 同一个排行榜的历史排行榜从旧到新排列
+type CheckpointHistory struct {
+	RankID uint32
+	Time   time.Time
+	Reason WALRecordType
+	Data   []byte
+}
+
+// 等待写入的转储, ranks不能再被RankHandler修改
+type pendingCheckpoint struct {
+	lsn             uint64
+	ranks           map[uint32]engine.RankEngine
+	history         map[uint32][]*HistoryBoard
+	noUpdateWindows map[uint32]*NoUpdateWindows
+	// 不为nil时写入完成之后发送结果
+	done chan error
+}
+
+func (p *pendingCheckpoint) Checkpoint() (*Checkpoint, error) {
+	c := &Checkpoint{
+		LSN:             p.lsn,
+		Ranks:           make(map[uint32][]byte, len(p.ranks)),
+		NoUpdateWindows: p.noUpdateWindows,
+	}
+	for rankID, rank := range p.ranks {
+		data, err := rank.MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("Marshal rank %d: %v", rankID, err)
+		}
+		c.Ranks[rankID] = data
+	}
+	for rankID, boards := range p.history {
+		for _, board := range boards {
+			data, err := board.Rank.MarshalBinary()
+			if err != nil {
+				return nil, fmt.Errorf("Marshal rank %d history: %v", rankID, err)
+			}
+			c.History = append(c.History, CheckpointHistory{
+				RankID: rankID,
+				Time:   board.Time,
+				Reason: board.Reason,
+				Data:   data,
+			})
+		}
+	}
+	return c, nil
+}
+
+func checkpointName(lsn uint64) string {
+	return fmt.Sprintf("%s%020d%s", CHECKPOINT_PREFIX, lsn, CHECKPOINT_SUFFIX)
+}
+
+// 返回dir下所有转储的LSN, 从小到大排列
+func listCheckpoints(dir string) ([]uint64, error) {
+	names, err := filepath.Glob(filepath.Join(dir,
+		CHECKPOINT_PREFIX+"*"+CHECKPOINT_SUFFIX))
+	if err != nil {
+		return nil, err
+	}
+	var checkpoints []uint64
+	for _, name := range names {
+		var lsn uint64
+		base := filepath.Base(name)
+		if _, err := fmt.Sscanf(base, CHECKPOINT_PREFIX+"%d"+CHECKPOINT_SUFFIX,
+			&lsn); err != nil {
+			glog.Warningf("Ignore unexpected checkpoint file %s", name)
+			continue
+		}
+		checkpoints = append(checkpoints, lsn)
+	}
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i] < checkpoints[j]
+	})
+	return checkpoints, nil
+}
+
+// 格式: magic(4) version(2) lsn(8) count(4) [rank(4) data]...
+// historyCount(4) [rank(4) time(8) reason(1) data]...
+// windowsCount(4) [rank(4) windows]... crc32(4)
+// 其中data以4字节长度作为前缀, 版本1没有历史排行榜, 版本2没有禁止更新时间段
+func (c *Checkpoint) MarshalBinary() ([]byte, error) {
+	rankIDs := make([]uint32, 0, len(c.Ranks))
+	for rankID := range c.Ranks {
+		rankIDs = append(rankIDs, rankID)
+	}
+	sort.Slice(rankIDs, func(i, j int) bool {
+		return rankIDs[i] < rankIDs[j]
+	})
+
+	var buf bytes.Buffer
+	w := goutil.NewStrickyWriter(&buf)
+	binary.Write(w, binary.LittleEndian, uint32(CHECKPOINT_MAGIC))
+	binary.Write(w, binary.LittleEndian, uint16(CHECKPOINT_VERSION))
+	binary.Write(w, binary.LittleEndian, c.LSN)
+	binary.Write(w, binary.LittleEndian, uint32(len(rankIDs)))
+	for _, rankID := range rankIDs {
+		binary.Write(w, binary.LittleEndian, rankID)
+		binary.Write(w, binary.LittleEndian, uint32(len(c.Ranks[rankID])))
+		w.Write(c.Ranks[rankID])
+	}
+	binary.Write(w, binary.LittleEndian, uint32(len(c.History)))
+	for _, history := range c.History {
+		binary.Write(w, binary.LittleEndian, history.RankID)
+		binary.Write(w, binary.LittleEndian, history.Time.UnixNano())
+		binary.Write(w, binary.LittleEndian, uint8(history.Reason))
+		binary.Write(w, binary.LittleEndian, uint32(len(history.Data)))
+		w.Write(history.Data)
+	}
+	windowRankIDs := make([]uint32, 0, len(c.NoUpdateWindows))
+	for rankID := range c.NoUpdateWindows {
+		windowRankIDs = append(windowRankIDs, rankID)
+	}
+	sort.Slice(windowRankIDs, func(i, j int) bool {
+		return windowRankIDs[i] < windowRankIDs[j]
+	})
+	binary.Write(w, binary.LittleEndian, uint32(len(windowRankIDs)))
+	for _, rankID := range windowRankIDs {
+		binary.Write(w, binary.LittleEndian, rankID)
+		c.NoUpdateWindows[rankID].marshal(w)
+	}
+	if w.Err != nil {
+		return nil, w.Err
+	}
+	binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(buf.Bytes()))
+	return buf.Bytes(), nil
+}
+
+func (c *Checkpoint) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return fmt.Errorf("Expect at least 4 bytes, got: %d", len(data))
+	}
+	payload := data[:len(data)-4]
+	checksum := binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return fmt.Errorf("Checksum mismatch")
+	}
+
+	r := goutil.NewStrickyReader(bytes.NewReader(payload))
+	var magic, count uint32
+	var version uint16
+	binary.Read(r, binary.LittleEndian, &magic)
+	binary.Read(r, binary.LittleEndian, &version)
+	binary.Read(r, binary.LittleEndian, &c.LSN)
+	binary.Read(r, binary.LittleEndian, &count)
+	if r.Err != nil {
+		return r.Err
+	}
+	if magic != CHECKPOINT_MAGIC {
+		return fmt.Errorf("Expect magic 0x%X, got: 0x%X", CHECKPOINT_MAGIC, magic)
+	}
+	if version == 0 || version > CHECKPOINT_VERSION {
+		return fmt.Errorf("Unsupported version %d", version)
+	}
+	if count > MAX_CHECKPOINT_NUM {
+		return fmt.Errorf("Invalid rank count %d", count)
+	}
+	c.Ranks = make(map[uint32][]byte, count)
+	for i := uint32(0); i < count; i++ {
+		var rankID, size uint32
+		binary.Read(r, binary.LittleEndian, &rankID)
+		binary.Read(r, binary.LittleEndian, &size)
+		if r.Err != nil {
+			return r.Err
+		}
+		if size > uint32(len(payload)) {
+			return fmt.Errorf("Invalid rank %d size %d", rankID, size)
+		}
+		rank := make([]byte, size)
+		if size != 0 {
+			r.Read(rank)
+		}
+		c.Ranks[rankID] = rank
+	}
+	if version < 2 {
+		return r.Err
+	}
+
+	binary.Read(r, binary.LittleEndian, &count)
+	if r.Err != nil {
+		return r.Err
+	}
+	if count > MAX_CHECKPOINT_NUM*MAX_CHECKPOINT_HISTORY_NUM {
+		return fmt.Errorf("Invalid history count %d", count)
+	}
+	c.History = make([]CheckpointHistory, count)
+	for i := range c.History {
+		history := &c.History[i]
+		var ts int64
+		var reason uint8
+		var size uint32
+		binary.Read(r, binary.LittleEndian, &history.RankID)
+		binary.Read(r, binary.LittleEndian, &ts)
+		binary.Read(r, binary.LittleEndian, &reason)
+		binary.Read(r, binary.LittleEndian, &size)
+		if r.Err != nil {
+			return r.Err
+		}
+		if size > uint32(len(payload)) {
+			return fmt.Errorf("Invalid rank %d history size %d",
+				history.RankID, size)
+		}
+		history.Time = time.Unix(0, ts)
+		history.Reason = WALRecordType(reason)
+		history.Data = make([]byte, size)
+		if size != 0 {
+			r.Read(history.Data)
+		}
+	}
+	if version < 3 {
+		return r.Err
+	}
+
+	binary.Read(r, binary.LittleEndian, &count)
+	if r.Err != nil {
+		return r.Err
+	}
+	if count > MAX_CHECKPOINT_NUM {
+		return fmt.Errorf("Invalid no update windows count %d", count)
+	}
+	c.NoUpdateWindows = make(map[uint32]*NoUpdateWindows, count)
+	for i := uint32(0); i < count; i++ {
+		var rankID uint32
+		binary.Read(r, binary.LittleEndian, &rankID)
+		windows := &NoUpdateWindows{}
+		if err := windows.unmarshal(r); err != nil {
+			return err
+		}
+		c.NoUpdateWindows[rankID] = windows
+	}
+	return r.Err
+}
+
+// 先写入临时文件再重命名, 保证dir中的转储都是完整的
+func WriteCheckpoint(dir string, c *Checkpoint) error {
+	data, err := c.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	name := filepath.Join(dir, checkpointName(c.LSN))
+	tmpName := name + ".tmp"
+	file, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, name)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(dir)
+}
+
+// 读取dir中最新的完整转储, 没有转储时返回nil
+func LoadLatestCheckpoint(dir string) (*Checkpoint, error) {
+	checkpoints, err := listCheckpoints(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		name := filepath.Join(dir, checkpointName(checkpoints[i]))
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		c := &Checkpoint{}
+		if err := c.UnmarshalBinary(data); err != nil {
+			// 之前的转储加上WAL仍然可能恢复出完整的数据
+			glog.Errorf("Skip broken checkpoint %s: %v", name, err)
+			continue
+		}
+		return c, nil
+	}
+	return nil, nil
+}
+
+// 只保留最新的retain个转储, 并删除最旧的转储也不再需要的WAL段
+func PruneCheckpoints(dir string, retain int) error {
+	if retain < 1 {
+		retain = 1
+	}
+	checkpoints, err := listCheckpoints(dir)
+	if err != nil {
+		return err
+	}
+	if len(checkpoints) == 0 {
+		return nil
+	}
+	for len(checkpoints) > retain {
+		name := filepath.Join(dir, checkpointName(checkpoints[0]))
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+		glog.Infof("Remove checkpoint %s", name)
+		checkpoints = checkpoints[1:]
+	}
+
+	segments, err := listWALSegments(dir)
+	if err != nil {
+		return err
+	}
+	// 段i中的记录都小于下一个段的第一条记录的LSN
+	for i := 0; i+1 < len(segments) && segments[i+1] <= checkpoints[0]; i++ {
+		name := filepath.Join(dir, walSegmentName(segments[i]))
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+		glog.Infof("Remove WAL segment %s", name)
+	}
+	return nil
+}
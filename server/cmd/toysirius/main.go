@@ -12,12 +12,31 @@ import (
 )
 
 var config server.AppConfig
+var walSyncPolicy string
+var settlementJSONL, settlementCSV, settlementURL string
+var configFile string
 
 func init() {
-	flag.StringVar(&config.AcceptClientAddress, "clientaddr", ":9427",
-		"Client listening address")
-	flag.StringVar(&config.AcceptServerAddress, "serveraddr", ":9428",
-		"Server listening address")
+	flag.StringVar(&configFile, "config", "toysirius.json",
+		"Listening addresses and ranks in JSON format")
+	flag.StringVar(&config.ReplicaOf, "replicaof", "",
+		"Run as a replica of the primary at this server address")
+	flag.StringVar(&config.DataDir, "datadir", "",
+		"WAL and checkpoint directory, disable WAL if empty")
+	flag.StringVar(&walSyncPolicy, "walsync", "always",
+		"WAL sync policy: always, interval or never")
+	flag.DurationVar(&config.WALSyncInterval, "walsyncinterval",
+		time.Millisecond*100, "WAL sync interval for interval policy")
+	flag.DurationVar(&config.CheckpointInterval, "checkpointinterval",
+		time.Minute*5, "Checkpoint interval, only checkpoint on exit if 0")
+	flag.IntVar(&config.CheckpointRetain, "checkpointretain", 2,
+		"Number of checkpoints to retain")
+	flag.StringVar(&settlementJSONL, "settlejsonl", "",
+		"Append settlements to this JSONL file")
+	flag.StringVar(&settlementCSV, "settlecsv", "",
+		"Append settlements to this CSV file")
+	flag.StringVar(&settlementURL, "settleurl", "",
+		"Post settlements to this HTTP endpoint")
 	flag.Parse()
 }
 
@@ -31,35 +50,40 @@ func main() {
 	go func() {
 		glog.Info(http.ListenAndServe(":6060", nil))
 	}()
-	loc, err := time.LoadLocation("Asia/Shanghai")
+	file, err := server.LoadConfigFile(configFile)
 	ce(err)
-	clearStart, err := time.ParseInLocation("2006-01-02 15:04:05",
-		"2017-03-23 17:18:00", loc)
+	rankConfigs, err := file.RankEngineConfigs()
 	ce(err)
-	app := server.NewApp(config)
-	primaryRankConfig := engine.RankEngineConfig{
-		MaxSize: 10,
-		ClearPeriod: engine.TimePeriod{
-			Start:    clearStart,
-			Interval: time.Second * 5,
-		},
-		NoUpdatePeriod: engine.TimePeriod{
-			Start:    clearStart,
-			Interval: time.Hour * 24 * 1,
-			Duration: time.Hour * 24 * 20,
-		},
+	config.ConfigFile = configFile
+	config.AcceptClientAddress = file.ClientAddress
+	if config.AcceptClientAddress == "" {
+		config.AcceptClientAddress = ":9427"
 	}
-
-	snapshotRankConfig := engine.RankEngineConfig{
-		MaxSize:       5,
-		PrimaryRankID: 1,
-		SnapshotPeriod: engine.TimePeriod{
-			Start:    clearStart,
-			Interval: time.Second * 5,
-		},
+	config.AcceptServerAddress = file.ServerAddress
+	if config.AcceptServerAddress == "" {
+		config.AcceptServerAddress = ":9428"
+	}
+	config.AcceptAdminAddress = file.AdminAddress
+	config.WALSyncPolicy, err = server.ParseWALSyncPolicy(walSyncPolicy)
+	ce(err)
+	if settlementJSONL != "" {
+		config.SettlementSinks = append(config.SettlementSinks,
+			&server.JSONLSettlementSink{Path: settlementJSONL})
+	}
+	if settlementCSV != "" {
+		config.SettlementSinks = append(config.SettlementSinks,
+			&server.CSVSettlementSink{Path: settlementCSV})
+	}
+	if settlementURL != "" {
+		config.SettlementSinks = append(config.SettlementSinks,
+			&server.HTTPSettlementSink{URL: settlementURL})
+	}
+	app := server.NewApp(config, engine.RealClock)
+	// 副本的排行榜全部来自主节点
+	if config.ReplicaOf == "" {
+		for rankID, rankConfig := range rankConfigs {
+			ce(app.AddRank(rankID, rankConfig))
+		}
 	}
-
-	app.AddRank(1, primaryRankConfig)
-	app.AddRank(2, snapshotRankConfig)
 	app.Run()
 }
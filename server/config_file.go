@@ -0,0 +1,468 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jacobwpeng/sirius/engine"
+)
+
+const (
+	// 配置文件中时间的格式, 在对应的时区解析
+	CONFIG_TIME_LAYOUT = "2006-01-02 15:04:05"
+)
+
+// JSON格式的配置文件, 例如:
+//
+//	{
+//	  "client_address": ":9427",
+//	  "admin_address": "127.0.0.1:9429",
+//	  "ranks": [
+//	    {"id": 1, "max_size": 10,
+//	     "clear_period": {"cron": "0 0 * * 1", "location": "Asia/Shanghai"}},
+//	    {"id": 2, "max_size": 10, "primary_rank_id": 1,
+//	     "snapshot_period": {"start": "2017-03-23 00:00:00", "interval": "24h"}}
+//	  ]
+//	}
+type ConfigFile struct {
+	ClientAddress string `json:"client_address"`
+	ServerAddress string `json:"server_address"`
+	// 管理端口, 为空时不接受来自网络的管理请求
+	AdminAddress string       `json:"admin_address"`
+	Ranks        []ConfigRank `json:"ranks"`
+}
+
+type ConfigRank struct {
+	ID uint32 `json:"id"`
+	// 为空时根据redundant_node_num选择redundant或者array
+	Kind             string `json:"kind"`
+	MaxSize          uint32 `json:"max_size"`
+	RedundantNodeNum uint32 `json:"redundant_node_num"`
+	// desc或者asc, 默认desc
+	SortOrder string `json:"sort_order"`
+	// earliest, latest或者lowest_id, 默认earliest
+	TieBreak string `json:"tie_break"`
+	// ordinal, competition或者dense, 默认ordinal
+	RankingMode    string `json:"ranking_mode"`
+	PrimaryRankID  uint32 `json:"primary_rank_id"`
+	HistorySize    uint32 `json:"history_size"`
+	SettlementSize uint32 `json:"settlement_size"`
+	// 清空周期, 快照榜的内容由快照覆盖, 不能配置
+	ClearPeriod *ConfigPeriod `json:"clear_period"`
+	// 生成快照周期, 只能用于快照榜
+	SnapshotPeriod     *ConfigPeriod     `json:"snapshot_period"`
+	NoUpdatePeriod     *ConfigPeriod     `json:"no_update_period"`
+	NoUpdateRanges     []ConfigTimeRange `json:"no_update_ranges"`
+	NoUpdateExclusions []ConfigTimeRange `json:"no_update_exclusions"`
+}
+
+// 三种周期只能配置一种:
+// 固定间隔: start, interval
+// 日历: calendar(day, week或者month), weekday, day, hour, minute, second
+// cron: cron表达式
+// start对日历和cron表示第一个有效的时间点, duration只用于no_update_period
+type ConfigPeriod struct {
+	// 时区, 例如Asia/Shanghai, 为空时使用UTC
+	Location string `json:"location"`
+	Start    string `json:"start"`
+	Interval string `json:"interval"`
+	Duration string `json:"duration"`
+	Calendar string `json:"calendar"`
+	Weekday  string `json:"weekday"`
+	Day      int    `json:"day"`
+	Hour     int    `json:"hour"`
+	Minute   int    `json:"minute"`
+	Second   int    `json:"second"`
+	Cron     string `json:"cron"`
+}
+
+type ConfigTimeRange struct {
+	Name     string `json:"name"`
+	Location string `json:"location"`
+	Begin    string `json:"begin"`
+	End      string `json:"end"`
+}
+
+// 读取并检查配置文件, 错误中包含出错的位置
+func LoadConfigFile(path string) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfigFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return config, nil
+}
+
+func ParseConfigFile(data []byte) (*ConfigFile, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	config := &ConfigFile{}
+	if err := decoder.Decode(config); err != nil {
+		if e, ok := err.(*json.SyntaxError); ok {
+			line, column := offsetToLineColumn(data, e.Offset)
+			return nil, fmt.Errorf("line %d column %d: %v", line, column, err)
+		}
+		if e, ok := err.(*json.UnmarshalTypeError); ok {
+			line, column := offsetToLineColumn(data, e.Offset)
+			return nil, fmt.Errorf("line %d column %d: %v", line, column, err)
+		}
+		return nil, err
+	}
+	if _, err := config.RankEngineConfigs(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func offsetToLineColumn(data []byte, offset int64) (int, int) {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// 检查所有排行榜的配置并转换为RankEngineConfig
+func (c *ConfigFile) RankEngineConfigs() (map[uint32]engine.RankEngineConfig,
+	error) {
+	configs := make(map[uint32]engine.RankEngineConfig)
+	for i, rc := range c.Ranks {
+		config, err := rc.RankEngineConfig()
+		if err == nil {
+			if _, exist := configs[rc.ID]; exist {
+				err = fmt.Errorf("Duplicate rank id")
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ranks[%d] (id %d): %v", i, rc.ID, err)
+		}
+		configs[rc.ID] = config
+	}
+	// 所有排行榜都读取之后才能检查主榜
+	for i, rc := range c.Ranks {
+		if rc.PrimaryRankID == 0 {
+			continue
+		}
+		primary, exist := configs[rc.PrimaryRankID]
+		var err error
+		if !exist {
+			err = fmt.Errorf("Primary rank %d not found", rc.PrimaryRankID)
+		} else if primary.PrimaryRankID != 0 {
+			err = fmt.Errorf("Primary rank %d is a snapshot rank of %d",
+				rc.PrimaryRankID, primary.PrimaryRankID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("ranks[%d] (id %d): %v", i, rc.ID, err)
+		}
+	}
+	return configs, nil
+}
+
+func (rc *ConfigRank) RankEngineConfig() (engine.RankEngineConfig, error) {
+	config := engine.RankEngineConfig{
+		Kind:             rc.Kind,
+		MaxSize:          rc.MaxSize,
+		RedundantNodeNum: rc.RedundantNodeNum,
+		PrimaryRankID:    rc.PrimaryRankID,
+		HistorySize:      rc.HistorySize,
+		SettlementSize:   rc.SettlementSize,
+	}
+	if rc.ID == 0 {
+		return config, fmt.Errorf("id: expect > 0")
+	}
+	if rc.ID >= DYNAMIC_RANK_ID_BASE {
+		return config, fmt.Errorf("id: expect < %d", DYNAMIC_RANK_ID_BASE)
+	}
+	if rc.MaxSize == 0 {
+		return config, fmt.Errorf("max_size: expect > 0")
+	}
+	if kind := config.EngineKind(); !isRankEngineKind(kind) {
+		return config, fmt.Errorf("kind: unknown %q, expect one of %v", kind,
+			engine.RankEngineKinds())
+	}
+	if rc.PrimaryRankID == rc.ID {
+		return config, fmt.Errorf("primary_rank_id: rank is its own primary")
+	}
+	var err error
+	if config.SortOrder, err = parseSortOrder(rc.SortOrder); err != nil {
+		return config, fmt.Errorf("sort_order: %v", err)
+	}
+	if config.TieBreak, err = parseTieBreak(rc.TieBreak); err != nil {
+		return config, fmt.Errorf("tie_break: %v", err)
+	}
+	if config.RankingMode, err = parseRankingMode(rc.RankingMode); err != nil {
+		return config, fmt.Errorf("ranking_mode: %v", err)
+	}
+
+	if rc.ClearPeriod != nil {
+		if rc.PrimaryRankID != 0 {
+			return config, fmt.Errorf("clear_period: snapshot rank is overwritten " +
+				"by snapshot_period, remove clear_period")
+		}
+		if config.ClearPeriod, err = rc.ClearPeriod.Schedule(false); err != nil {
+			return config, fmt.Errorf("clear_period: %v", err)
+		}
+	}
+	if rc.SnapshotPeriod != nil {
+		if rc.PrimaryRankID == 0 {
+			return config, fmt.Errorf("snapshot_period: only snapshot rank with " +
+				"primary_rank_id can have snapshot_period")
+		}
+		config.SnapshotPeriod, err = rc.SnapshotPeriod.Schedule(false)
+		if err != nil {
+			return config, fmt.Errorf("snapshot_period: %v", err)
+		}
+	} else if rc.PrimaryRankID != 0 {
+		return config, fmt.Errorf("snapshot_period: required for snapshot rank")
+	}
+	if rc.NoUpdatePeriod != nil {
+		config.NoUpdatePeriod, err = rc.NoUpdatePeriod.Schedule(true)
+		if err != nil {
+			return config, fmt.Errorf("no_update_period: %v", err)
+		}
+	}
+	if config.NoUpdateRanges, err = timeRanges(rc.NoUpdateRanges); err != nil {
+		return config, fmt.Errorf("no_update_ranges%v", err)
+	}
+	config.NoUpdateExclusions, err = timeRanges(rc.NoUpdateExclusions)
+	if err != nil {
+		return config, fmt.Errorf("no_update_exclusions%v", err)
+	}
+	windows := &NoUpdateWindows{
+		Ranges:     config.NoUpdateRanges,
+		Exclusions: config.NoUpdateExclusions,
+	}
+	if err := windows.Validate(); err != nil {
+		return config, fmt.Errorf("no_update_ranges: %v", err)
+	}
+	return config, nil
+}
+
+func isRankEngineKind(kind string) bool {
+	for _, k := range engine.RankEngineKinds() {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func parseSortOrder(s string) (engine.SortOrder, error) {
+	for _, o := range []engine.SortOrder{engine.SortOrderDescending,
+		engine.SortOrderAscending} {
+		if s == o.String() {
+			return o, nil
+		}
+	}
+	if s == "" {
+		return engine.SortOrderDescending, nil
+	}
+	return 0, fmt.Errorf("unknown %q, expect desc or asc", s)
+}
+
+func parseTieBreak(s string) (engine.TieBreak, error) {
+	for _, tb := range []engine.TieBreak{engine.TieBreakEarliest,
+		engine.TieBreakLatest, engine.TieBreakLowestID} {
+		if s == tb.String() {
+			return tb, nil
+		}
+	}
+	if s == "" {
+		return engine.TieBreakEarliest, nil
+	}
+	return 0, fmt.Errorf("unknown %q, expect earliest, latest or lowest_id", s)
+}
+
+func parseRankingMode(s string) (engine.RankingMode, error) {
+	for _, m := range []engine.RankingMode{engine.RankingModeOrdinal,
+		engine.RankingModeCompetition, engine.RankingModeDense} {
+		if s == m.String() {
+			return m, nil
+		}
+	}
+	if s == "" {
+		return engine.RankingModeOrdinal, nil
+	}
+	return 0, fmt.Errorf("unknown %q, expect ordinal, competition or dense", s)
+}
+
+func loadConfigLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("location: %v", err)
+	}
+	return loc, nil
+}
+
+func parseConfigTime(field, s string, loc *time.Location) (time.Time, error) {
+	t, err := time.ParseInLocation(CONFIG_TIME_LAYOUT, s, loc)
+	if err != nil {
+		return t, fmt.Errorf("%s: expect %q, got: %q", field, CONFIG_TIME_LAYOUT,
+			s)
+	}
+	return t, nil
+}
+
+func parseConfigDuration(field, s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", field, err)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("%s: expect >= 0, got: %s", field, s)
+	}
+	return d, nil
+}
+
+// 转换为对应的Schedule, withDuration表示是否需要持续时间
+func (pc *ConfigPeriod) Schedule(withDuration bool) (engine.Schedule, error) {
+	loc, err := loadConfigLocation(pc.Location)
+	if err != nil {
+		return nil, err
+	}
+	var start time.Time
+	if pc.Start != "" {
+		if start, err = parseConfigTime("start", pc.Start, loc); err != nil {
+			return nil, err
+		}
+	}
+	duration, err := parseConfigDuration("duration", pc.Duration)
+	if err != nil {
+		return nil, err
+	}
+	if withDuration && duration == 0 {
+		return nil, fmt.Errorf("duration: expect > 0")
+	}
+	if !withDuration && duration != 0 {
+		return nil, fmt.Errorf("duration: only used by no_update_period")
+	}
+	calendarSet := pc.Calendar != "" || pc.Weekday != "" || pc.Day != 0 ||
+		pc.Hour != 0 || pc.Minute != 0 || pc.Second != 0
+	kinds := 0
+	for _, set := range []bool{pc.Interval != "", calendarSet, pc.Cron != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds != 1 {
+		return nil, fmt.Errorf("Expect exactly one of interval, calendar or cron")
+	}
+
+	switch {
+	case pc.Interval != "":
+		interval, err := parseConfigDuration("interval", pc.Interval)
+		if err != nil {
+			return nil, err
+		}
+		if interval == 0 {
+			return nil, fmt.Errorf("interval: expect > 0")
+		}
+		if start.IsZero() {
+			return nil, fmt.Errorf("start: required by interval")
+		}
+		if duration > interval {
+			return nil, fmt.Errorf("duration: %s longer than interval %s",
+				duration, interval)
+		}
+		return engine.TimePeriod{
+			Start:    start,
+			Interval: interval,
+			Duration: duration,
+		}, nil
+	case pc.Cron != "":
+		cron, err := engine.ParseCronSchedule(pc.Cron, loc)
+		if err != nil {
+			return nil, fmt.Errorf("cron: %v", err)
+		}
+		cron.Start = start
+		cron.Duration = duration
+		return cron, nil
+	}
+	unit, err := engine.ParseCalendarUnit(pc.Calendar)
+	if err != nil {
+		return nil, fmt.Errorf("calendar: %v", err)
+	}
+	schedule := engine.CalendarSchedule{
+		Unit:     unit,
+		Day:      pc.Day,
+		Hour:     pc.Hour,
+		Minute:   pc.Minute,
+		Second:   pc.Second,
+		Location: loc,
+		Start:    start,
+		Duration: duration,
+	}
+	if pc.Weekday != "" {
+		if unit != engine.CalendarWeek {
+			return nil, fmt.Errorf("weekday: only used by week calendar")
+		}
+		if schedule.Weekday, err = parseWeekday(pc.Weekday); err != nil {
+			return nil, fmt.Errorf("weekday: %v", err)
+		}
+	}
+	if pc.Day != 0 && unit != engine.CalendarMonth {
+		return nil, fmt.Errorf("day: only used by month calendar")
+	}
+	for _, f := range []struct {
+		name       string
+		value, max int
+	}{
+		{"day", pc.Day, 31},
+		{"hour", pc.Hour, 23},
+		{"minute", pc.Minute, 59},
+		{"second", pc.Second, 59},
+	} {
+		if f.value < 0 || f.value > f.max {
+			return nil, fmt.Errorf("%s: expect [0, %d], got: %d", f.name, f.max,
+				f.value)
+		}
+	}
+	return schedule, nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if strings.EqualFold(s, d.String()) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown %q, expect sunday to saturday", s)
+}
+
+// 返回的错误以下标开头, 调用方在前面加上字段名
+func timeRanges(configs []ConfigTimeRange) ([]engine.TimeRange, error) {
+	var ranges []engine.TimeRange
+	for i, c := range configs {
+		loc, err := loadConfigLocation(c.Location)
+		if err == nil {
+			var r engine.TimeRange
+			r.Name = c.Name
+			if r.Begin, err = parseConfigTime("begin", c.Begin, loc); err == nil {
+				r.End, err = parseConfigTime("end", c.End, loc)
+			}
+			if err == nil && !r.Begin.Before(r.End) {
+				err = fmt.Errorf("end: %s not after begin %s", c.End, c.Begin)
+			}
+			ranges = append(ranges, r)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %v", i, err)
+		}
+	}
+	return ranges, nil
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacobwpeng/sirius/engine"
+)
+
+func TestLoadConfigFileExample(t *testing.T) {
+	file, err := LoadConfigFile("cmd/toysirius/toysirius.json")
+	if err != nil {
+		if strings.Contains(err.Error(), "location") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	configs, err := file.RankEngineConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs[2].PrimaryRankID != 1 {
+		t.Errorf("Expect primary rank 1 and snapshot rank 2, got: %v", configs)
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	file, err := ParseConfigFile([]byte(`{
+		"ranks": [
+			{"id": 1, "max_size": 10, "sort_order": "asc",
+			 "clear_period": {"cron": "0 0 * * 1"},
+			 "no_update_period": {"calendar": "week", "weekday": "Sunday",
+			  "hour": 23, "duration": "1h"},
+			 "no_update_ranges": [{"name": "final",
+			  "begin": "2017-03-23 00:00:00", "end": "2017-03-24 00:00:00"}]},
+			{"id": 2, "max_size": 5, "primary_rank_id": 1,
+			 "snapshot_period": {"start": "2017-03-23 00:00:00", "interval": "24h"}}
+		]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, _ := file.RankEngineConfigs()
+	primary := configs[1]
+	if primary.SortOrder != engine.SortOrderAscending {
+		t.Errorf("Expect ascending, got: %s", primary.SortOrder)
+	}
+	monday := time.Date(2017, 3, 27, 0, 0, 0, 0, time.UTC)
+	if next := primary.ClearPeriod.NextTime(monday.Add(-time.Hour)); !next.Equal(monday) {
+		t.Errorf("Expect clear at %s, got: %s", monday, next)
+	}
+	if !primary.NoUpdatePeriod.Contains(monday.Add(-time.Minute)) {
+		t.Errorf("Expect no update before %s", monday)
+	}
+	if len(primary.NoUpdateRanges) != 1 || primary.NoUpdateRanges[0].Name != "final" {
+		t.Errorf("Expect no update range final, got: %v", primary.NoUpdateRanges)
+	}
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+	cases := []struct {
+		config string
+		err    string
+	}{
+		{`{"ranks": [{"id": 1, "max_size": 10},]}`, "line 1 column 39"},
+		{`{"ranks": [{"id": 1, "maxsize": 10}]}`, `unknown field "maxsize"`},
+		{`{"ranks": [{"id": 1}]}`, "ranks[0] (id 1): max_size: expect > 0"},
+		{`{"ranks": [{"id": 1, "max_size": 10}, {"id": 1, "max_size": 10}]}`,
+			"ranks[1] (id 1): Duplicate rank id"},
+		{`{"ranks": [{"id": 1, "max_size": 10, "kind": "heap"}]}`,
+			`ranks[0] (id 1): kind: unknown "heap"`},
+		{`{"ranks": [{"id": 2, "max_size": 10, "primary_rank_id": 1,
+			"snapshot_period": {"start": "2017-03-23 00:00:00", "interval": "1h"}}]}`,
+			"ranks[0] (id 2): Primary rank 1 not found"},
+		{`{"ranks": [{"id": 1, "max_size": 10},
+			{"id": 2, "max_size": 10, "primary_rank_id": 1,
+			 "clear_period": {"start": "2017-03-23 00:00:00", "interval": "1h"},
+			 "snapshot_period": {"start": "2017-03-23 00:00:00", "interval": "1h"}}]}`,
+			"ranks[1] (id 2): clear_period: snapshot rank is overwritten"},
+		{`{"ranks": [{"id": 1, "max_size": 10,
+			"clear_period": {"start": "2017-03-23 00:00:00", "interval": "0s"}}]}`,
+			"ranks[0] (id 1): clear_period: interval: expect > 0"},
+		{`{"ranks": [{"id": 1, "max_size": 10,
+			"clear_period": {"start": "2017-03-23", "interval": "1h"}}]}`,
+			`clear_period: start: expect "2006-01-02 15:04:05", got: "2017-03-23"`},
+		{`{"ranks": [{"id": 1, "max_size": 10,
+			"clear_period": {"cron": "0 0 * *"}}]}`,
+			"clear_period: cron: Expect 5 fields"},
+		{`{"ranks": [{"id": 1, "max_size": 10,
+			"clear_period": {"calendar": "day", "cron": "@daily"}}]}`,
+			"clear_period: Expect exactly one of interval, calendar or cron"},
+		{`{"ranks": [{"id": 1, "max_size": 10,
+			"no_update_period": {"calendar": "day", "hour": 4}}]}`,
+			"no_update_period: duration: expect > 0"},
+		{`{"ranks": [{"id": 1, "max_size": 10,
+			"no_update_period": {"calendar": "day", "hour": 24, "duration": "1h"}}]}`,
+			"no_update_period: hour: expect [0, 23], got: 24"},
+		{`{"ranks": [{"id": 1, "max_size": 10, "no_update_ranges": [
+			{"begin": "2017-03-23 00:00:00", "end": "2017-03-23 00:00:00"}]}]}`,
+			"no_update_ranges[0]: end: 2017-03-23 00:00:00 not after begin"},
+	}
+	for _, c := range cases {
+		_, err := ParseConfigFile([]byte(c.config))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("Expect error %q, got: %v", c.err, err)
+		}
+	}
+}
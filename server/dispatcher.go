@@ -2,83 +2,179 @@ package server
 
 import (
 	"fmt"
+	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
 	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/serverproto"
 )
 
 type Dispatcher struct {
-	wg             sync.WaitGroup
-	doneChan       chan struct{}
+	config   AppConfig
+	clock    engine.Clock
+	wg       sync.WaitGroup
+	doneChan chan struct{}
+	// 分发请求的goroutine退出时关闭
+	exitChan       chan struct{}
 	rankHandlers   []*RankHandler
 	mappedHandlers map[uint32]*RankHandler
-	jobQueue       chan Job
+	// 所有排行榜的配置, 用于ListRanks
+	rankConfigs map[uint32]engine.RankEngineConfig
+	// 运行时创建的排行榜, 保存在DataDir中
+	dynamicRanks      map[uint32]*serverproto.CreateRankRequest
+	nextDynamicRankID uint32
+	jobQueue          chan Job
+	started           bool
+	// 订阅修改记录的副本, 排行榜发生变化时全部断开
+	replicas []*replicaSubscriber
+	// 作为副本运行时主节点接受客户端连接的地址
+	primaryClientAddress string
 }
 
-func NewDispatcher(ranks map[uint32]engine.RankEngine) (*Dispatcher, error) {
-	rankHandlers := make([]*RankHandler, 0)
-	mappedHandlers := make(map[uint32]*RankHandler)
-	for rankID, rank := range ranks {
-		primaryRankID := rank.Config().PrimaryRankID
-		var rankHandler *RankHandler
-		if primaryRankID != 0 {
-			continue
-		}
-		rankHandler = NewRankHandler(rankID, rank)
-		rankHandlers = append(rankHandlers, rankHandler)
-		mappedHandlers[rankID] = rankHandler
-		glog.Infof("New rank handler for rank %d", rankID)
+func NewDispatcher(ranks map[uint32]engine.RankEngine,
+	config AppConfig, clock engine.Clock) (*Dispatcher, error) {
+	if config.ReplicaOf != "" &&
+		(config.DataDir != "" || len(config.SettlementSinks) != 0) {
+		return nil, fmt.Errorf("Replica can not have data dir or settlement sinks")
+	}
+	d := &Dispatcher{
+		config:            config,
+		clock:             clock,
+		doneChan:          make(chan struct{}),
+		exitChan:          make(chan struct{}),
+		mappedHandlers:    make(map[uint32]*RankHandler),
+		rankConfigs:       make(map[uint32]engine.RankEngineConfig),
+		dynamicRanks:      make(map[uint32]*serverproto.CreateRankRequest),
+		nextDynamicRankID: DYNAMIC_RANK_ID_BASE,
+		jobQueue:          make(chan Job, MAX_BUFFERED_JOB),
+	}
+	if err := d.addRanks(ranks); err != nil {
+		return nil, err
 	}
+	return d, nil
+}
 
-	for rankID, rank := range ranks {
-		primaryRankID := rank.Config().PrimaryRankID
-		if primaryRankID == 0 {
-			continue
-		}
-		// 处理所有的非Primary Rank
-		rankHandler, _ := mappedHandlers[primaryRankID]
-		if rankHandler == nil {
-			return nil, fmt.Errorf("Primary rank %d not found", primaryRankID)
-		}
-		if err := rankHandler.AddSnapshotRank(rankID, rank); err != nil {
-			return nil, err
+// 先添加所有的主榜, 再把快照榜添加到对应的RankHandler, 只能在Start之前调用
+func (d *Dispatcher) addRanks(ranks map[uint32]engine.RankEngine) error {
+	for _, primary := range []bool{true, false} {
+		for rankID, rank := range ranks {
+			if (rank.Config().PrimaryRankID == 0) != primary {
+				continue
+			}
+			if err := d.addRank(rankID, rank); err != nil {
+				return err
+			}
 		}
-		mappedHandlers[rankID] = rankHandler
-		glog.Infof("Add snapshot rank %d to %d", rankID, primaryRankID)
 	}
+	return nil
+}
+
+func (d *Dispatcher) addRank(rankID uint32, rank engine.RankEngine) error {
+	if _, exist := d.mappedHandlers[rankID]; exist {
+		return fmt.Errorf("Rank %d already exist", rankID)
+	}
+	primaryRankID := rank.Config().PrimaryRankID
+	if primaryRankID == 0 {
+		rankHandler := NewRankHandler(rankID, rank, d.clock)
+		rankHandler.replica = d.config.ReplicaOf != ""
+		d.rankHandlers = append(d.rankHandlers, rankHandler)
+		d.mappedHandlers[rankID] = rankHandler
+		d.rankConfigs[rankID] = rank.Config()
+		glog.Infof("New rank handler for rank %d", rankID)
+		return nil
+	}
+	// 处理所有的非Primary Rank
+	rankHandler, _ := d.mappedHandlers[primaryRankID]
+	if rankHandler == nil {
+		return fmt.Errorf("Primary rank %d not found", primaryRankID)
+	}
+	if err := rankHandler.AddSnapshotRank(rankID, rank); err != nil {
+		return err
+	}
+	d.mappedHandlers[rankID] = rankHandler
+	d.rankConfigs[rankID] = rank.Config()
+	glog.Infof("Add snapshot rank %d to %d", rankID, primaryRankID)
+	return nil
+}
 
-	return &Dispatcher{
-		doneChan:       make(chan struct{}),
-		rankHandlers:   rankHandlers,
-		mappedHandlers: mappedHandlers,
-		jobQueue:       make(chan Job, MAX_BUFFERED_JOB),
-	}, nil
+func (d *Dispatcher) handlerDir(rankID uint32) string {
+	return filepath.Join(d.config.DataDir, fmt.Sprintf("rank_%d", rankID))
 }
 
-func (d *Dispatcher) AddRank(rankID uint32, rank engine.RankEngine) bool {
-	_, exist := d.mappedHandlers[rankID]
-	if exist {
-		return false
+// 从DataDir恢复所有RankHandler的数据, 每个RankHandler使用单独的子目录
+// 恢复之后补上停机期间错过的清空以及快照
+// DataDir为空时结算只保存在内存中
+func (d *Dispatcher) Recover() error {
+	if d.config.DataDir == "" {
+		for _, handler := range d.rankHandlers {
+			err := handler.OpenOutbox("", d.config.SettlementSinks)
+			if err != nil {
+				return fmt.Errorf("Open outbox of rank %d: %v",
+					handler.primaryRankID, err)
+			}
+		}
+		return nil
+	}
+	if err := d.loadDynamicRanks(); err != nil {
+		return err
+	}
+	now := d.clock.Now()
+	for _, handler := range d.rankHandlers {
+		dir := d.handlerDir(handler.primaryRankID)
+		if err := handler.Recover(dir, d.config); err != nil {
+			return fmt.Errorf("Recover rank %d: %v", handler.primaryRankID, err)
+		}
+		if err := handler.CatchUp(now); err != nil {
+			return fmt.Errorf("Catch up rank %d: %v", handler.primaryRankID, err)
+		}
 	}
-	d.mappedHandlers[rankID] = NewRankHandler(rankID, rank)
-	return true
+	return nil
 }
 
 func (d *Dispatcher) Start() {
+	const CRON_CHECK_INTERVAL time.Duration = time.Millisecond * 500
 	for _, handler := range d.rankHandlers {
 		handler.Start(&d.wg)
 	}
+	d.started = true
+	ticker := d.clock.NewTicker(CRON_CHECK_INTERVAL)
 	d.wg.Add(1)
 	go func() {
 		defer d.wg.Done()
+		defer close(d.exitChan)
+		defer ticker.Stop()
 		for {
 			select {
 			case <-d.doneChan:
 				glog.V(2).Info("Dispatcher exit")
 				return
+			case <-ticker.C():
+				d.DispatchCron(d.clock.Now())
 			case job := <-d.jobQueue:
 				glog.V(2).Info("New job in dispatcher")
+				// 所有请求都在这里记录到达时间, 同一个RankHandler收到的请求时间递增
+				job.Time = d.clock.Now()
+				if job.admin != nil {
+					job.admin(d)
+					continue
+				}
+				if jobResult, ok := d.RedirectWrite(job); ok {
+					if jobNeedReply(job.Msg) {
+						job.resultChan <- jobResult
+					}
+					continue
+				}
+				if jobResult, ok := d.HandleAdminJob(job); ok {
+					job.resultChan <- jobResult
+					continue
+				}
+				if msg, ok := job.Msg.(*serverproto.BatchUpdateRequest); ok {
+					d.DispatchBatchUpdate(job, msg)
+					continue
+				}
 				rankHandler, exist := d.mappedHandlers[job.RankID]
 				if !exist {
 					glog.Infof("Rank %d not exist", job.RankID)
@@ -94,8 +190,105 @@ func (d *Dispatcher) Start() {
 	}()
 }
 
+// 通过jobQueue发送定时检查, 保证之前到达的请求都已经处理完成
+// jobQueue已满时跳过, 排队的请求同样会执行到期的清空以及快照
+func (d *Dispatcher) DispatchCron(now time.Time) {
+	for _, handler := range d.rankHandlers {
+		select {
+		case handler.jobQueue <- Job{Time: now, cron: true}:
+		default:
+		}
+	}
+}
+
+// 将批量更新按RankHandler拆分, 再把各个RankHandler的结果按原有顺序合并
+func (d *Dispatcher) DispatchBatchUpdate(job Job,
+	msg *serverproto.BatchUpdateRequest) {
+	type subBatch struct {
+		handler    *RankHandler
+		indexes    []int
+		msg        *serverproto.BatchUpdateRequest
+		resultChan chan JobResult
+	}
+	var batches []*subBatch
+	mappedBatches := make(map[*RankHandler]*subBatch)
+	results := make([]*serverproto.BatchUpdateResult, len(msg.Items))
+	for i, item := range msg.Items {
+		rankHandler, exist := d.mappedHandlers[item.GetRank()]
+		if !exist {
+			glog.Infof("Rank %d not exist", item.GetRank())
+			results[i] = &serverproto.BatchUpdateResult{
+				Rank:    proto.Uint32(item.GetRank()),
+				Id:      proto.Uint64(item.Data.GetId()),
+				ErrCode: proto.Int32(ErrRankNotFound),
+			}
+			continue
+		}
+		batch, exist := mappedBatches[rankHandler]
+		if !exist {
+			batchMsg := *msg
+			batchMsg.Items = nil
+			// 合并结果时需要知道每条数据是否更新成功
+			batchMsg.ItemResult = proto.Bool(true)
+			batch = &subBatch{handler: rankHandler, msg: &batchMsg}
+			mappedBatches[rankHandler] = batch
+			batches = append(batches, batch)
+		}
+		batch.indexes = append(batch.indexes, i)
+		batch.msg.Items = append(batch.msg.Items, item)
+	}
+
+	for _, batch := range batches {
+		batch.resultChan = make(chan JobResult, 1)
+		batch.handler.jobQueue <- Job{
+			Frame:      job.Frame,
+			RankID:     batch.msg.Items[0].GetRank(),
+			Msg:        batch.msg,
+			Time:       job.Time,
+			resultChan: batch.resultChan,
+		}
+	}
+	if !msg.GetReply() {
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		var changedNum uint32
+		for _, batch := range batches {
+			var jobResult JobResult
+			select {
+			case jobResult = <-batch.resultChan:
+			case <-d.doneChan:
+				return
+			}
+			batchResp := jobResult.Msg.(*serverproto.BatchUpdateResponse)
+			changedNum += batchResp.GetChanged()
+			for i, result := range batchResp.Results {
+				results[batch.indexes[i]] = result
+			}
+		}
+		resp := &serverproto.BatchUpdateResponse{
+			Changed: proto.Uint32(changedNum),
+		}
+		if msg.GetItemResult() {
+			resp.Results = results
+		}
+		job.resultChan <- JobResult{
+			FrameCtx:         job.Frame.Ctx,
+			FramePayloadType: uint32(serverproto.MessageType_TypeBatchUpdateResponse),
+			Msg:              resp,
+		}
+	}()
+}
+
 func (d *Dispatcher) Stop() {
 	close(d.doneChan)
+	// 等待分发请求的goroutine退出, 之后rankHandlers不会再变化
+	if d.started {
+		<-d.exitChan
+	}
 	for _, handler := range d.rankHandlers {
 		handler.Stop()
 	}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/frame"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+// 通过Dispatcher执行一个请求并等待回包
+func dispatchTestJob(t *testing.T, d *Dispatcher, rankID uint32,
+	msg proto.Message) JobResult {
+	return dispatchJob(t, d, rankID, msg, false)
+}
+
+// 发送来自管理端口的请求
+func dispatchAdminTestJob(t *testing.T, d *Dispatcher, rankID uint32,
+	msg proto.Message) JobResult {
+	return dispatchJob(t, d, rankID, msg, true)
+}
+
+func dispatchJob(t *testing.T, d *Dispatcher, rankID uint32,
+	msg proto.Message, privileged bool) JobResult {
+	resultChan := make(chan JobResult, 1)
+	d.jobQueue <- Job{
+		Frame:      &frame.Frame{},
+		RankID:     rankID,
+		Msg:        msg,
+		resultChan: resultChan,
+		privileged: privileged,
+	}
+	select {
+	case jobResult := <-resultChan:
+		return jobResult
+	case <-time.After(time.Second * 5):
+		t.Fatalf("Wait job result of rank %d timeout", rankID)
+	}
+	return JobResult{}
+}
+
+func getRangeIDs(t *testing.T, d *Dispatcher, rankID uint32) []uint64 {
+	jobResult := dispatchTestJob(t, d, rankID, &serverproto.GetRangeRequest{
+		Start: proto.Uint32(0),
+		Num:   proto.Uint32(10),
+	})
+	var ids []uint64
+	for _, u := range jobResult.Msg.(*serverproto.GetRangeResponse).Data {
+		ids = append(ids, u.GetId())
+	}
+	return ids
+}
+
+func TestDispatcherFakeClock(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	clock := engine.NewFakeClock(start.Add(time.Hour))
+	ranks := make(map[uint32]engine.RankEngine)
+	ranks[1], _ = engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize: 10,
+		ClearPeriod: engine.TimePeriod{
+			Start:    start,
+			Interval: time.Hour * 24,
+		},
+		NoUpdatePeriod: engine.TimePeriod{
+			Start:    start.Add(time.Hour * 23),
+			Interval: time.Hour * 24,
+			Duration: time.Minute * 30,
+		},
+	})
+	ranks[2], _ = engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize:       10,
+		PrimaryRankID: 1,
+		SnapshotPeriod: engine.TimePeriod{
+			Start:    start,
+			Interval: time.Hour * 24,
+		},
+	})
+	d, err := NewDispatcher(ranks, AppConfig{}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Start()
+	defer d.Stop()
+
+	jobResult := dispatchTestJob(t, d, 1, updateRequest(1024, 10))
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Update failed: %d", jobResult.ErrCode)
+	}
+
+	clock.Set(start.Add(time.Hour*23 + time.Minute*10))
+	jobResult = dispatchTestJob(t, d, 1, updateRequest(1025, 12))
+	if jobResult.ErrCode != ErrNoUpdateTimePeriod {
+		t.Errorf("Expect ErrNoUpdateTimePeriod, got: %d", jobResult.ErrCode)
+	}
+
+	clock.Set(start.Add(time.Hour*24 + time.Millisecond))
+	jobResult = dispatchTestJob(t, d, 1, updateRequest(1026, 14))
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Update failed: %d", jobResult.ErrCode)
+	}
+	if ids := getRangeIDs(t, d, 2); len(ids) != 1 || ids[0] != 1024 {
+		t.Errorf("Expect snapshot [1024], got: %v", ids)
+	}
+	if ids := getRangeIDs(t, d, 1); len(ids) != 1 || ids[0] != 1026 {
+		t.Errorf("Expect primary rank [1026], got: %v", ids)
+	}
+
+	clock.Advance(time.Hour * 24)
+	if ids := getRangeIDs(t, d, 2); len(ids) != 1 || ids[0] != 1026 {
+		t.Errorf("Expect snapshot [1026], got: %v", ids)
+	}
+	if ids := getRangeIDs(t, d, 1); len(ids) != 0 {
+		t.Errorf("Expect primary rank cleared, got: %v", ids)
+	}
+}
+
+func listRankIDs(t *testing.T, d *Dispatcher) []uint32 {
+	jobResult := dispatchTestJob(t, d, 0, &serverproto.ListRanksRequest{})
+	var ids []uint32
+	for _, info := range jobResult.Msg.(*serverproto.ListRanksResponse).Ranks {
+		ids = append(ids, info.GetRank())
+	}
+	return ids
+}
+
+func TestDispatcherDynamicRanks(t *testing.T) {
+	start := time.Date(2017, 3, 23, 1, 0, 0, 0, time.UTC)
+	clock := engine.NewFakeClock(start)
+	config := AppConfig{DataDir: t.TempDir(), WALSyncPolicy: WALSyncAlways}
+	newDispatcher := func() *Dispatcher {
+		ranks := make(map[uint32]engine.RankEngine)
+		ranks[1], _ = engine.NewRankEngine(engine.RankEngineConfig{MaxSize: 10})
+		d, err := NewDispatcher(ranks, config, clock)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Recover(); err != nil {
+			t.Fatal(err)
+		}
+		d.Start()
+		return d
+	}
+
+	d := newDispatcher()
+	// 管理请求只能来自管理端口
+	for _, msg := range []proto.Message{
+		&serverproto.CreateRankRequest{
+			Config: &serverproto.RankConfig{MaxSize: proto.Uint32(10)},
+		},
+		&serverproto.DropRankRequest{Rank: proto.Uint32(1)},
+		&serverproto.ReloadConfigRequest{},
+		&serverproto.SetNoUpdateWindowsRequest{Rank: proto.Uint32(1)},
+	} {
+		jobResult := dispatchTestJob(t, d, 0, msg)
+		if jobResult.ErrCode != ErrPermissionDenied {
+			t.Errorf("%T: expect ErrPermissionDenied, got: %d", msg, jobResult.ErrCode)
+		}
+	}
+	jobResult := dispatchAdminTestJob(t, d, 0, &serverproto.CreateRankRequest{
+		Config: &serverproto.RankConfig{
+			MaxSize:   proto.Uint32(10),
+			ClearCron: proto.String("0 0 * * *"),
+		},
+	})
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Create rank failed: %d", jobResult.ErrCode)
+	}
+	primary := jobResult.Msg.(*serverproto.CreateRankResponse).GetRank()
+	if primary != DYNAMIC_RANK_ID_BASE {
+		t.Errorf("Expect rank %d, got: %d", DYNAMIC_RANK_ID_BASE, primary)
+	}
+	jobResult = dispatchAdminTestJob(t, d, 100, &serverproto.CreateRankRequest{
+		Rank: proto.Uint32(100),
+		Config: &serverproto.RankConfig{
+			MaxSize:      proto.Uint32(10),
+			PrimaryRank:  proto.Uint32(primary),
+			SnapshotCron: proto.String("0 0 * * *"),
+		},
+	})
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Create snapshot rank failed: %d", jobResult.ErrCode)
+	}
+	for _, c := range []struct {
+		msg     proto.Message
+		errCode int32
+	}{
+		{&serverproto.CreateRankRequest{
+			Rank:   proto.Uint32(1),
+			Config: &serverproto.RankConfig{MaxSize: proto.Uint32(10)},
+		}, ErrRankExist},
+		{&serverproto.CreateRankRequest{
+			Config: &serverproto.RankConfig{
+				MaxSize:   proto.Uint32(10),
+				ClearCron: proto.String("0 0 30 2"),
+			},
+		}, ErrInvalidArgument},
+		// 与配置文件相同, 快照榜不能配置清空周期, 必须配置快照周期
+		{&serverproto.CreateRankRequest{
+			Config: &serverproto.RankConfig{
+				MaxSize:      proto.Uint32(10),
+				PrimaryRank:  proto.Uint32(primary),
+				ClearCron:    proto.String("0 0 * * *"),
+				SnapshotCron: proto.String("0 0 * * *"),
+			},
+		}, ErrInvalidArgument},
+		{&serverproto.CreateRankRequest{
+			Config: &serverproto.RankConfig{
+				MaxSize:     proto.Uint32(10),
+				PrimaryRank: proto.Uint32(primary),
+			},
+		}, ErrInvalidArgument},
+		{&serverproto.DropRankRequest{Rank: proto.Uint32(1)}, ErrStaticRank},
+	} {
+		jobResult = dispatchAdminTestJob(t, d, 0, c.msg)
+		if jobResult.ErrCode != c.errCode {
+			t.Errorf("%v: expect %d, got: %d", c.msg, c.errCode, jobResult.ErrCode)
+		}
+	}
+	dispatchTestJob(t, d, primary, updateRequest(1024, 10))
+	d.Stop()
+
+	// 重启之后运行时创建的排行榜以及数据都仍然存在, 快照在停机期间到期
+	clock.Set(start.Add(time.Hour * 24))
+	d = newDispatcher()
+	if ids := listRankIDs(t, d); fmt.Sprint(ids) != fmt.Sprint([]uint32{1, 100,
+		primary}) {
+		t.Errorf("Expect ranks [1 100 %d], got: %v", primary, ids)
+	}
+	if ids := getRangeIDs(t, d, 100); len(ids) != 1 || ids[0] != 1024 {
+		t.Errorf("Expect snapshot [1024], got: %v", ids)
+	}
+	jobResult = dispatchAdminTestJob(t, d, primary, &serverproto.DropRankRequest{
+		Rank: proto.Uint32(primary),
+	})
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Drop rank failed: %d", jobResult.ErrCode)
+	}
+	dropped := jobResult.Msg.(*serverproto.DropRankResponse).Dropped
+	if fmt.Sprint(dropped) != fmt.Sprint([]uint32{primary, 100}) {
+		t.Errorf("Expect dropped [%d 100], got: %v", primary, dropped)
+	}
+	jobResult = dispatchTestJob(t, d, 100, updateRequest(1024, 10))
+	if jobResult.ErrCode != ErrRankNotFound {
+		t.Errorf("Expect ErrRankNotFound, got: %d", jobResult.ErrCode)
+	}
+	d.Stop()
+
+	d = newDispatcher()
+	defer d.Stop()
+	if ids := listRankIDs(t, d); len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("Expect ranks [1], got: %v", ids)
+	}
+	// 重新创建的同ID排行榜不包含之前的数据
+	jobResult = dispatchAdminTestJob(t, d, 0, &serverproto.CreateRankRequest{
+		Rank:   proto.Uint32(primary),
+		Config: &serverproto.RankConfig{MaxSize: proto.Uint32(10)},
+	})
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Create rank failed: %d", jobResult.ErrCode)
+	}
+	if ids := getRangeIDs(t, d, primary); len(ids) != 0 {
+		t.Errorf("Expect empty rank, got: %v", ids)
+	}
+}
@@ -7,6 +7,15 @@ const (
 	ErrRankNotFound       int32 = -10001
 	ErrServerTimeRange    int32 = -10002
 	ErrNoUpdateTimePeriod int32 = -10003
+	ErrHistoryNotFound    int32 = -10004
+	ErrInvalidArgument    int32 = -10005
+	ErrRankExist          int32 = -10006
+	// 配置文件中的排行榜不能在运行时删除
+	ErrStaticRank int32 = -10007
+	// 副本不接受修改请求, 回包中是主节点的地址
+	ErrRedirect int32 = -10008
+	// 创建, 删除排行榜, 修改禁止更新时间段以及重新加载配置只能通过管理端口请求
+	ErrPermissionDenied int32 = -10009
 )
 
 type Error struct {
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+// 清空或者被快照覆盖之前归档的排行榜
+type HistoryBoard struct {
+	// 归档的时间, 即清空或者快照的时间
+	Time time.Time
+	// 归档的原因, WALRecordClear或者WALRecordSnapshot
+	Reason WALRecordType
+	// 归档时排行榜的副本, 之后不会再修改
+	Rank engine.RankEngine
+}
+
+// 在清空或者覆盖rank之前归档, 只保留最近的HistorySize个
+// 空的排行榜不归档
+func (h *RankHandler) ArchiveRank(rankID uint32, rank engine.RankEngine,
+	reason WALRecordType, now time.Time) {
+	historySize := rank.Config().HistorySize
+	if historySize == 0 || rank.Size() == 0 {
+		return
+	}
+	h.AddHistory(rankID, &HistoryBoard{
+		Time:   now,
+		Reason: reason,
+		Rank:   rank.CreateSnapshot(),
+	})
+	glog.Infof("Archive rank %d before %s at %s", rankID, reason, now)
+}
+
+func (h *RankHandler) AddHistory(rankID uint32, board *HistoryBoard) {
+	historySize := int(board.Rank.Config().HistorySize)
+	boards := append(h.history[rankID], board)
+	if len(boards) > historySize {
+		boards = boards[len(boards)-historySize:]
+	}
+	h.history[rankID] = boards
+}
+
+// 从转储中恢复历史排行榜, 使用排行榜当前的配置
+func (h *RankHandler) LoadHistory(history CheckpointHistory) error {
+	rank := h.FindRank(history.RankID)
+	if rank == nil {
+		glog.Warningf("Ignore history of unknown rank %d", history.RankID)
+		return nil
+	}
+	if rank.Config().HistorySize == 0 {
+		return nil
+	}
+	board, err := engine.NewRankEngine(rank.Config())
+	if err != nil {
+		return err
+	}
+	if err := board.UnmarshalBinary(history.Data); err != nil {
+		return fmt.Errorf("Load rank %d history at %s: %v", history.RankID,
+			history.Time, err)
+	}
+	h.AddHistory(history.RankID, &HistoryBoard{
+		Time:   history.Time,
+		Reason: history.Reason,
+		Rank:   board,
+	})
+	return nil
+}
+
+// 查找归档时间为ts的历史排行榜, ts为0时返回第generation新的历史排行榜
+func (h *RankHandler) FindHistory(rankID uint32, ts int64,
+	generation uint32) (uint32, *HistoryBoard) {
+	boards := h.history[rankID]
+	for i := len(boards) - 1; i >= 0; i-- {
+		g := uint32(len(boards) - 1 - i)
+		if (ts != 0 && boards[i].Time.Unix() == ts) ||
+			(ts == 0 && g == generation) {
+			return g, boards[i]
+		}
+	}
+	return 0, nil
+}
+
+func (h *RankHandler) HandleGetHistoryRange(job Job,
+	msg *serverproto.GetHistoryRangeRequest) JobResult {
+	generation, board := h.FindHistory(job.RankID, msg.GetTime(),
+		msg.GetGeneration())
+	if board == nil {
+		return JobResult{
+			FrameCtx: job.Frame.Ctx,
+			ErrCode:  ErrHistoryNotFound,
+		}
+	}
+
+	num := msg.GetNum()
+	if num > MAX_QUERY_NUM {
+		num = MAX_QUERY_NUM
+	}
+	values := board.Rank.GetRange(msg.GetStart(), num)
+	data := make([]*serverproto.RankUnit, len(values))
+	for i, u := range values {
+		data[i] = RankUnitToProto(u)
+	}
+	boards := h.history[job.RankID]
+	historyTime := make([]int64, len(boards))
+	for i, b := range boards {
+		historyTime[len(boards)-1-i] = b.Time.Unix()
+	}
+
+	resp := &serverproto.GetHistoryRangeResponse{
+		Rank:        proto.Uint32(job.RankID),
+		Time:        proto.Int64(board.Time.Unix()),
+		Generation:  proto.Uint32(generation),
+		Start:       proto.Uint32(msg.GetStart()),
+		Total:       proto.Uint32(board.Rank.Size()),
+		Data:        data,
+		DisplayRank: engine.DisplayRanks(board.Rank, msg.GetStart(), values),
+		HistoryTime: historyTime,
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeGetHistoryRangeResponse),
+		Msg:              resp,
+	}
+}
@@ -1,13 +1,26 @@
 package server
 
 import (
+	"time"
+
 	"github.com/golang/protobuf/proto"
 	"github.com/jacobwpeng/sirius/frame"
 )
 
 type Job struct {
-	Frame      *frame.Frame
-	RankID     uint32
-	Msg        proto.Message
+	Frame  *frame.Frame
+	RankID uint32
+	Msg    proto.Message
+	// Dispatcher收到请求的时间
+	Time       time.Time
 	resultChan chan<- JobResult
+	// 定时检查清空以及快照, 没有请求内容
+	cron bool
+	// 在RankHandler的goroutine中执行, 用于运行时修改排行榜
+	run func(h *RankHandler)
+	// 在Dispatcher的goroutine中执行, 用于副本同步
+	admin func(d *Dispatcher)
+	// 来自管理端口或者进程内部, 可以执行CreateRank, DropRank, SetNoUpdateWindows
+	// 以及ReloadConfig
+	privileged bool
 }
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/goutil"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+const (
+	// 周期性的禁止更新时间段的名字
+	NO_UPDATE_PERIOD_NAME = "no_update_period"
+	// 每种时间段最多的数量
+	MAX_NO_UPDATE_WINDOW_NUM = 1024
+	MAX_NO_UPDATE_NAME_SIZE  = 256
+	// 计算重新开放更新时间时最多跨越的相连时间段数量
+	MAX_REOPEN_SEARCH = 1024
+)
+
+// 在NoUpdatePeriod之外额外禁止更新的时间段, 以及NoUpdatePeriod不生效的时间段
+// 修改时整体替换, 不会修改已有的对象
+type NoUpdateWindows struct {
+	Ranges     []engine.TimeRange
+	Exclusions []engine.TimeRange
+}
+
+func (w *NoUpdateWindows) Validate() error {
+	for _, ranges := range [][]engine.TimeRange{w.Ranges, w.Exclusions} {
+		if len(ranges) > MAX_NO_UPDATE_WINDOW_NUM {
+			return fmt.Errorf("Max %d windows, got: %d", MAX_NO_UPDATE_WINDOW_NUM,
+				len(ranges))
+		}
+		for _, r := range ranges {
+			if len(r.Name) > MAX_NO_UPDATE_NAME_SIZE {
+				return fmt.Errorf("Max window name size %d, got: %d",
+					MAX_NO_UPDATE_NAME_SIZE, len(r.Name))
+			}
+			if !r.Begin.Before(r.End) {
+				return fmt.Errorf("Window %q begin %s not before end %s", r.Name,
+					r.Begin, r.End)
+			}
+		}
+	}
+	return nil
+}
+
+// t时刻生效的禁止更新时间段, 返回其名字以及结束时间
+// 明确的时间段优先, 排除的时间段开始时周期性的时间段同样结束
+func (w *NoUpdateWindows) blockedAt(period engine.Schedule,
+	t time.Time) (bool, string, time.Time) {
+	for _, r := range w.Ranges {
+		if r.Contains(t) {
+			return true, r.Name, r.End
+		}
+	}
+	if engine.ScheduleEmpty(period) {
+		return false, "", time.Time{}
+	}
+	end := period.WindowEnd(t)
+	if end.IsZero() {
+		return false, "", time.Time{}
+	}
+	for _, e := range w.Exclusions {
+		if e.Contains(t) {
+			return false, "", time.Time{}
+		}
+		if e.Begin.After(t) && e.Begin.Before(end) {
+			end = e.Begin
+		}
+	}
+	return true, NO_UPDATE_PERIOD_NAME, end
+}
+
+// 返回t时刻是否禁止更新, 生效的时间段名字以及重新开放更新的时间
+// 多个时间段相连时重新开放的时间是最后一个的结束时间, 无法确定时为零值
+func (w *NoUpdateWindows) Check(period engine.Schedule,
+	t time.Time) (bool, string, time.Time) {
+	blocked, name, reopen := w.blockedAt(period, t)
+	if !blocked {
+		return false, "", time.Time{}
+	}
+	for i := 0; i < MAX_REOPEN_SEARCH; i++ {
+		blocked, _, end := w.blockedAt(period, reopen)
+		if !blocked {
+			return true, name, reopen
+		}
+		if !end.After(reopen) {
+			break
+		}
+		reopen = end
+	}
+	return true, name, time.Time{}
+}
+
+// 格式: count(4) [begin(8) end(8) name]... 两组, 先Ranges后Exclusions
+// 其中name以2字节长度作为前缀
+func (w *NoUpdateWindows) marshal(sw *goutil.StrickyWriter) {
+	for _, ranges := range [][]engine.TimeRange{w.Ranges, w.Exclusions} {
+		binary.Write(sw, binary.LittleEndian, uint32(len(ranges)))
+		for _, r := range ranges {
+			binary.Write(sw, binary.LittleEndian, r.Begin.UnixNano())
+			binary.Write(sw, binary.LittleEndian, r.End.UnixNano())
+			binary.Write(sw, binary.LittleEndian, uint16(len(r.Name)))
+			sw.Write([]byte(r.Name))
+		}
+	}
+}
+
+func (w *NoUpdateWindows) unmarshal(sr *goutil.StrickyReader) error {
+	var groups [2][]engine.TimeRange
+	for i := range groups {
+		var count uint32
+		binary.Read(sr, binary.LittleEndian, &count)
+		if sr.Err != nil {
+			return sr.Err
+		}
+		if count > MAX_NO_UPDATE_WINDOW_NUM {
+			return fmt.Errorf("Invalid window count %d", count)
+		}
+		for j := uint32(0); j < count; j++ {
+			var begin, end int64
+			var size uint16
+			binary.Read(sr, binary.LittleEndian, &begin)
+			binary.Read(sr, binary.LittleEndian, &end)
+			binary.Read(sr, binary.LittleEndian, &size)
+			if sr.Err != nil {
+				return sr.Err
+			}
+			if size > MAX_NO_UPDATE_NAME_SIZE {
+				return fmt.Errorf("Invalid window name size %d", size)
+			}
+			name := make([]byte, size)
+			if size != 0 {
+				sr.Read(name)
+			}
+			groups[i] = append(groups[i], engine.TimeRange{
+				Name:  string(name),
+				Begin: time.Unix(0, begin),
+				End:   time.Unix(0, end),
+			})
+		}
+	}
+	w.Ranges, w.Exclusions = groups[0], groups[1]
+	return sr.Err
+}
+
+func timeRangesToProto(ranges []engine.TimeRange) []*serverproto.NoUpdateWindow {
+	windows := make([]*serverproto.NoUpdateWindow, len(ranges))
+	for i, r := range ranges {
+		windows[i] = &serverproto.NoUpdateWindow{
+			Name:  proto.String(r.Name),
+			Begin: proto.Int64(r.Begin.Unix()),
+			End:   proto.Int64(r.End.Unix()),
+		}
+	}
+	return windows
+}
+
+func timeRangesFromProto(windows []*serverproto.NoUpdateWindow) []engine.TimeRange {
+	ranges := make([]engine.TimeRange, len(windows))
+	for i, w := range windows {
+		ranges[i] = engine.TimeRange{
+			Name:  w.GetName(),
+			Begin: time.Unix(w.GetBegin(), 0),
+			End:   time.Unix(w.GetEnd(), 0),
+		}
+	}
+	return ranges
+}
+
+// 返回排行榜当前的禁止更新时间段, 运行时没有修改过时使用配置
+func (h *RankHandler) NoUpdateWindows(rankID uint32,
+	rank engine.RankEngine) *NoUpdateWindows {
+	if windows, ok := h.noUpdateWindows[rankID]; ok {
+		return windows
+	}
+	return &NoUpdateWindows{
+		Ranges:     rank.Config().NoUpdateRanges,
+		Exclusions: rank.Config().NoUpdateExclusions,
+	}
+}
+
+func (h *RankHandler) SetNoUpdateWindows(rankID uint32,
+	windows *NoUpdateWindows) {
+	h.noUpdateWindows[rankID] = windows
+	glog.Infof("Set rank %d no update windows: %d ranges, %d exclusions", rankID,
+		len(windows.Ranges), len(windows.Exclusions))
+}
+
+func (h *RankHandler) HandleGetNoUpdateWindows(job Job,
+	rank engine.RankEngine) JobResult {
+	windows := h.NoUpdateWindows(job.RankID, rank)
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeGetNoUpdateWindowsResponse),
+		Msg: &serverproto.GetNoUpdateWindowsResponse{
+			Rank:       proto.Uint32(job.RankID),
+			Ranges:     timeRangesToProto(windows.Ranges),
+			Exclusions: timeRangesToProto(windows.Exclusions),
+		},
+	}
+}
+
+func (h *RankHandler) HandleSetNoUpdateWindows(job Job,
+	msg *serverproto.SetNoUpdateWindowsRequest) JobResult {
+	windows := &NoUpdateWindows{
+		Ranges:     timeRangesFromProto(msg.Ranges),
+		Exclusions: timeRangesFromProto(msg.Exclusions),
+	}
+	if err := windows.Validate(); err != nil {
+		glog.Infof("Drop set no update windows request: %v", err)
+		return JobResult{
+			FrameCtx: job.Frame.Ctx,
+			ErrCode:  ErrInvalidArgument,
+		}
+	}
+	err := h.AppendWAL(&WALRecord{
+		Type:    WALRecordNoUpdateWindows,
+		RankID:  job.RankID,
+		Windows: windows,
+	})
+	if err != nil {
+		return JobResult{
+			FrameCtx: job.Frame.Ctx,
+			ErrCode:  ErrServerFailure,
+		}
+	}
+	h.SetNoUpdateWindows(job.RankID, windows)
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeSetNoUpdateWindowsResponse),
+		Msg: &serverproto.SetNoUpdateWindowsResponse{
+			Rank:       proto.Uint32(job.RankID),
+			Ranges:     timeRangesToProto(windows.Ranges),
+			Exclusions: timeRangesToProto(windows.Exclusions),
+		},
+	}
+}
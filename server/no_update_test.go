@@ -0,0 +1,124 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+func testTimeRange(name string, begin, end time.Time) engine.TimeRange {
+	return engine.TimeRange{Name: name, Begin: begin, End: end}
+}
+
+func TestNoUpdateWindowsCheck(t *testing.T) {
+	day := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	period := engine.CalendarSchedule{
+		Unit:     engine.CalendarDay,
+		Hour:     4,
+		Duration: time.Hour,
+	}
+	windows := &NoUpdateWindows{
+		Ranges: []engine.TimeRange{
+			testTimeRange("maintenance", day.Add(time.Hour*3),
+				day.Add(time.Hour*4)),
+			testTimeRange("final", day.Add(time.Hour*10), day.Add(time.Hour*12)),
+		},
+		Exclusions: []engine.TimeRange{
+			testTimeRange("holiday", day.Add(time.Hour*24), day.Add(time.Hour*48)),
+			testTimeRange("event", day.Add(time.Hour*52+time.Minute*20),
+				day.Add(time.Hour*53)),
+		},
+	}
+	cases := []struct {
+		t       time.Time
+		blocked bool
+		name    string
+		reopen  time.Time
+	}{
+		{day.Add(time.Hour*2 + time.Minute*59), false, "", time.Time{}},
+		// 相连的时间段在最后一个结束时才重新开放
+		{day.Add(time.Hour*3 + time.Minute*30), true, "maintenance",
+			day.Add(time.Hour * 5)},
+		{day.Add(time.Hour*4 + time.Minute*30), true, NO_UPDATE_PERIOD_NAME,
+			day.Add(time.Hour * 5)},
+		{day.Add(time.Hour*10 + time.Minute*30), true, "final",
+			day.Add(time.Hour * 12)},
+		{day.Add(time.Hour*28 + time.Minute*30), false, "", time.Time{}},
+		// 排除的时间段开始时重新开放
+		{day.Add(time.Hour*52 + time.Minute*10), true, NO_UPDATE_PERIOD_NAME,
+			day.Add(time.Hour*52 + time.Minute*20)},
+		{day.Add(time.Hour*52 + time.Minute*30), false, "", time.Time{}},
+	}
+	for _, c := range cases {
+		blocked, name, reopen := windows.Check(period, c.t)
+		if blocked != c.blocked || name != c.name || !reopen.Equal(c.reopen) {
+			t.Errorf("Check(%s): expect (%v, %q, %s), got: (%v, %q, %s)", c.t,
+				c.blocked, c.name, c.reopen, blocked, name, reopen)
+		}
+	}
+
+	invalid := &NoUpdateWindows{
+		Ranges: []engine.TimeRange{testTimeRange("empty", day, day)},
+	}
+	if invalid.Validate() == nil {
+		t.Error("Expect error for empty window")
+	}
+}
+
+func TestRankHandlerSetNoUpdateWindows(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	newHandler := func() *RankHandler {
+		primary, _ := engine.NewRankEngine(engine.RankEngineConfig{MaxSize: 10})
+		h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+		if err := h.Recover(dir, AppConfig{WALSyncPolicy: WALSyncAlways}); err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	begin := start.Add(time.Hour)
+	end := start.Add(time.Hour * 2)
+
+	h := newHandler()
+	jobResult := runTestJob(h, 1, &serverproto.SetNoUpdateWindowsRequest{
+		Rank: proto.Uint32(1),
+		Ranges: []*serverproto.NoUpdateWindow{{
+			Name:  proto.String("maintenance"),
+			Begin: proto.Int64(begin.Unix()),
+			End:   proto.Int64(end.Unix()),
+		}},
+	}, start)
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Set no update windows failed: %d", jobResult.ErrCode)
+	}
+	jobResult = runTestJob(h, 1, updateRequest(1024, 10), begin)
+	if jobResult.ErrCode != ErrNoUpdateTimePeriod {
+		t.Fatalf("Expect ErrNoUpdateTimePeriod, got: %d", jobResult.ErrCode)
+	}
+	resp := jobResult.Msg.(*serverproto.UpdateResponse)
+	if resp.GetNoUpdateWindow() != "maintenance" ||
+		resp.GetReopenTime() != end.Unix() {
+		t.Errorf("Expect maintenance until %d, got: %q until %d", end.Unix(),
+			resp.GetNoUpdateWindow(), resp.GetReopenTime())
+	}
+	if jobResult = runTestJob(h, 1, updateRequest(1024, 10), end); jobResult.ErrCode != 0 {
+		t.Errorf("Expect update after window, got: %d", jobResult.ErrCode)
+	}
+
+	// 重放WAL以及从转储恢复之后时间段都仍然生效
+	for i := 0; i < 2; i++ {
+		h = newHandler()
+		jobResult = runTestJob(h, 1, &serverproto.GetNoUpdateWindowsRequest{
+			Rank: proto.Uint32(1),
+		}, start)
+		ranges := jobResult.Msg.(*serverproto.GetNoUpdateWindowsResponse).Ranges
+		if len(ranges) != 1 || ranges[0].GetName() != "maintenance" ||
+			ranges[0].GetBegin() != begin.Unix() {
+			t.Errorf("Expect maintenance window after restart, got: %v", ranges)
+		}
+		h.CloseWAL()
+	}
+}
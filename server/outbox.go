@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jacobwpeng/sirius/engine"
+)
+
+const (
+	OUTBOX_FILE          = "outbox.jsonl"
+	OUTBOX_CURSOR_PREFIX = "cursor-"
+	// 发送失败之后重试的间隔
+	SETTLEMENT_RETRY_INTERVAL = time.Second * 5
+)
+
+// 等待发送的结算, 按Seq从小到大排列
+// 每个接收方有单独的发送进度, 即已经送达的最大Seq, 送达之后才更新进度
+// 所有接收方都送达之后才会从outbox中删除
+// dir为空时只保存在内存中, 重启之后没有送达的结算会丢失
+type Outbox struct {
+	dir   string
+	sinks []SettlementSink
+	// 只在Run中访问
+	cursors []uint64
+	notify  chan struct{}
+
+	mutex   sync.Mutex
+	file    *os.File
+	pending []*Settlement
+	lastSeq uint64
+}
+
+func outboxCursorName(sink SettlementSink) string {
+	return OUTBOX_CURSOR_PREFIX + url.QueryEscape(sink.Name())
+}
+
+// 读取dir中的发送进度以及未送达的结算
+func OpenOutbox(dir string, sinks []SettlementSink) (*Outbox, error) {
+	o := &Outbox{
+		dir:     dir,
+		sinks:   sinks,
+		cursors: make([]uint64, len(sinks)),
+		notify:  make(chan struct{}, 1),
+	}
+	if dir == "" {
+		return o, nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	for i, sink := range sinks {
+		cursor, err := o.loadCursor(sink)
+		if err != nil {
+			return nil, err
+		}
+		o.cursors[i] = cursor
+		if cursor > o.lastSeq {
+			o.lastSeq = cursor
+		}
+	}
+	if err := o.load(); err != nil {
+		return nil, err
+	}
+	glog.Infof("Open outbox %s, %d pending settlements, last seq %d", dir,
+		len(o.pending), o.lastSeq)
+	return o, nil
+}
+
+func (o *Outbox) loadCursor(sink SettlementSink) (uint64, error) {
+	data, err := os.ReadFile(filepath.Join(o.dir, outboxCursorName(sink)))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// 先写入临时文件再重命名, 保证进度文件是完整的
+func (o *Outbox) saveCursor(sink SettlementSink, cursor uint64) error {
+	name := filepath.Join(o.dir, outboxCursorName(sink))
+	tmpName := name + ".tmp"
+	data := []byte(strconv.FormatUint(cursor, 10) + "\n")
+	err := os.WriteFile(tmpName, data, 0644)
+	if err == nil {
+		err = os.Rename(tmpName, name)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(o.dir)
+}
+
+// 读取outbox文件, 截断写了一半的最后一行
+func (o *Outbox) load() error {
+	name := filepath.Join(o.dir, OUTBOX_FILE)
+	file, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	var offset int64
+	r := bufio.NewReader(file)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) != 0 {
+				glog.Warningf("Truncate partial settlement at %s:%d", name, offset)
+			}
+			break
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+		s := &Settlement{}
+		if err := json.Unmarshal(bytes.TrimSpace(line), s); err != nil {
+			glog.Warningf("Truncate broken settlement at %s:%d: %v", name,
+				offset, err)
+			break
+		}
+		offset += int64(len(line))
+		if s.Seq > o.lastSeq {
+			o.lastSeq = s.Seq
+		}
+		if s.Seq > o.minCursor() {
+			o.pending = append(o.pending, s)
+		}
+	}
+	if err := file.Truncate(offset); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		file.Close()
+		return err
+	}
+	o.file = file
+	return nil
+}
+
+func (o *Outbox) minCursor() uint64 {
+	var min uint64
+	for i, cursor := range o.cursors {
+		if i == 0 || cursor < min {
+			min = cursor
+		}
+	}
+	return min
+}
+
+// 加入一个结算, Seq为0时自动分配
+// Seq不大于已有的结算时说明是重放WAL重新生成的结算, 直接忽略
+// 写入文件失败时结算仍然保留在内存中
+func (o *Outbox) Add(s *Settlement) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if s.Seq == 0 {
+		s.Seq = o.lastSeq + 1
+	} else if s.Seq <= o.lastSeq {
+		glog.V(1).Infof("Ignore settlement %s with seq %d", s.ID, s.Seq)
+		return nil
+	}
+	o.lastSeq = s.Seq
+	o.pending = append(o.pending, s)
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	if o.file == nil {
+		return nil
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if _, err := o.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return o.file.Sync()
+}
+
+// 返回Seq大于cursor的结算
+func (o *Outbox) after(cursor uint64) []*Settlement {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	for i, s := range o.pending {
+		if s.Seq > cursor {
+			return append([]*Settlement(nil), o.pending[i:]...)
+		}
+	}
+	return nil
+}
+
+// 删除所有接收方都已经送达的结算, 全部送达时清空文件
+func (o *Outbox) compact() {
+	cursor := o.minCursor()
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	n := 0
+	for n < len(o.pending) && o.pending[n].Seq <= cursor {
+		n++
+	}
+	o.pending = o.pending[n:]
+	if len(o.pending) != 0 || o.file == nil {
+		return
+	}
+	if err := o.file.Truncate(0); err != nil {
+		glog.Errorf("Truncate outbox %s: %v", o.dir, err)
+		return
+	}
+	// Truncate不会修改偏移, 否则之后的结算写在一段空字节之后, 重启时无法读取
+	if _, err := o.file.Seek(0, io.SeekStart); err != nil {
+		glog.Errorf("Seek outbox %s: %v", o.dir, err)
+	}
+}
+
+// 按顺序发送给每个接收方, 返回是否全部送达
+// 一个接收方失败时不影响其他接收方
+func (o *Outbox) Deliver() bool {
+	delivered := true
+	for i, sink := range o.sinks {
+		for _, s := range o.after(o.cursors[i]) {
+			if err := sink.Emit(s); err != nil {
+				glog.Errorf("Emit settlement %s to %s: %v", s.ID, sink.Name(), err)
+				delivered = false
+				break
+			}
+			o.cursors[i] = s.Seq
+			if o.dir == "" {
+				continue
+			}
+			if err := o.saveCursor(sink, s.Seq); err != nil {
+				// 重启之后会重新发送
+				glog.Errorf("Save cursor of %s: %v", sink.Name(), err)
+			}
+		}
+	}
+	o.compact()
+	return delivered
+}
+
+// 在后台发送结算直到done关闭, 失败时定期重试
+func (o *Outbox) Run(clock engine.Clock, done <-chan struct{}) {
+	ticker := clock.NewTicker(SETTLEMENT_RETRY_INTERVAL)
+	defer ticker.Stop()
+	o.Deliver()
+	for {
+		select {
+		case <-o.notify:
+			o.Deliver()
+		case <-ticker.C():
+			o.Deliver()
+		case <-done:
+			return
+		}
+	}
+}
+
+func (o *Outbox) Close() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.file == nil {
+		return nil
+	}
+	err := o.file.Close()
+	o.file = nil
+	return err
+}
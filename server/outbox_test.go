@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jacobwpeng/sirius/engine"
+)
+
+// 记录收到的结算, failSeq对应的结算发送失败
+type testSettlementSink struct {
+	received []uint64
+	failSeq  uint64
+}
+
+func (s *testSettlementSink) Name() string {
+	return "test"
+}
+
+func (s *testSettlementSink) Emit(settlement *Settlement) error {
+	if settlement.Seq == s.failSeq {
+		return fmt.Errorf("Emit %d failed", settlement.Seq)
+	}
+	s.received = append(s.received, settlement.Seq)
+	return nil
+}
+
+func TestOutboxRestart(t *testing.T) {
+	dir := t.TempDir()
+	sink := &testSettlementSink{failSeq: 2}
+	outbox, err := OpenOutbox(dir, []SettlementSink{sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for seq := uint64(1); seq <= 3; seq++ {
+		if err := outbox.Add(&Settlement{Seq: seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if outbox.Deliver() {
+		t.Error("Expect delivery failed")
+	}
+	outbox.Close()
+
+	// 重启之后从失败的结算继续, 已经加入的结算不会重复加入
+	sink.failSeq = 0
+	if outbox, err = OpenOutbox(dir, []SettlementSink{sink}); err != nil {
+		t.Fatal(err)
+	}
+	outbox.Add(&Settlement{Seq: 2})
+	if !outbox.Deliver() {
+		t.Error("Expect delivery succeeded")
+	}
+	outbox.Close()
+	if fmt.Sprint(sink.received) != "[1 2 3]" {
+		t.Errorf("Expect received [1 2 3], got: %v", sink.received)
+	}
+
+	// 全部送达之后重启不会重新发送
+	if outbox, err = OpenOutbox(dir, []SettlementSink{sink}); err != nil {
+		t.Fatal(err)
+	}
+	outbox.Add(&Settlement{Seq: 3})
+	outbox.Add(&Settlement{Seq: 4})
+	outbox.Deliver()
+	outbox.Close()
+	if fmt.Sprint(sink.received) != "[1 2 3 4]" {
+		t.Errorf("Expect received [1 2 3 4], got: %v", sink.received)
+	}
+}
+
+func TestOutboxAddAfterCompact(t *testing.T) {
+	dir := t.TempDir()
+	sink := &testSettlementSink{failSeq: 2}
+	outbox, err := OpenOutbox(dir, []SettlementSink{sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	outbox.Add(&Settlement{Seq: 1})
+	// 全部送达之后清空文件
+	if !outbox.Deliver() {
+		t.Error("Expect delivery succeeded")
+	}
+	outbox.Add(&Settlement{Seq: 2})
+	if outbox.Deliver() {
+		t.Error("Expect delivery failed")
+	}
+	outbox.Close()
+
+	// 清空之后加入的结算在重启之后仍然会发送
+	sink.failSeq = 0
+	if outbox, err = OpenOutbox(dir, []SettlementSink{sink}); err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox.pending) != 1 {
+		t.Errorf("Expect 1 pending settlement, got: %d", len(outbox.pending))
+	}
+	outbox.Deliver()
+	outbox.Close()
+	if fmt.Sprint(sink.received) != "[1 2]" {
+		t.Errorf("Expect received [1 2], got: %v", sink.received)
+	}
+}
+
+func readTestSettlements(t *testing.T, name string) []Settlement {
+	file, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var settlements []Settlement
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var s Settlement
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			t.Fatal(err)
+		}
+		settlements = append(settlements, s)
+	}
+	return settlements
+}
+
+func TestRankHandlerSettle(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	boundary := start.Add(time.Hour * 24)
+	dir := t.TempDir()
+	output := filepath.Join(t.TempDir(), "settlement.jsonl")
+	config := AppConfig{
+		WALSyncPolicy:   WALSyncAlways,
+		SettlementSinks: []SettlementSink{&JSONLSettlementSink{Path: output}},
+	}
+	newHandler := func() *RankHandler {
+		primary, _ := engine.NewRankEngine(engine.RankEngineConfig{
+			MaxSize: 10,
+			ClearPeriod: engine.TimePeriod{
+				Start:    start,
+				Interval: time.Hour * 24,
+			},
+			SettlementSize: 2,
+		})
+		primary.SetLastClearTime(start)
+		h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+		if err := h.Recover(dir, config); err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+
+	h := newHandler()
+	for i := uint64(0); i < 3; i++ {
+		runTestJob(h, 1, updateRequest(1024+i, 10+i), start.Add(time.Hour))
+	}
+	h.CronCheckAllRanks(boundary)
+	h.outbox.Deliver()
+
+	settlements := readTestSettlements(t, output)
+	if len(settlements) != 1 {
+		t.Fatalf("Expect 1 settlement, got: %d", len(settlements))
+	}
+	s := settlements[0]
+	if !s.Time.Equal(boundary) || s.Reason != WALRecordClear.String() {
+		t.Errorf("Expect clear at %s, got: %s at %s", boundary, s.Reason, s.Time)
+	}
+	if len(s.Units) != 2 || s.Units[0].ID != 1026 || s.Units[1].ID != 1025 {
+		t.Errorf("Expect top 2 [1026 1025], got: %v", s.Units)
+	}
+
+	// 不关闭WAL模拟崩溃, 重放WAL时重新生成的结算不会再次发送
+	h = newHandler()
+	h.outbox.Deliver()
+	if settlements = readTestSettlements(t, output); len(settlements) != 1 {
+		t.Errorf("Expect 1 settlement after restart, got: %d", len(settlements))
+	}
+}
@@ -0,0 +1,543 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+const (
+	// 运行时没有指定ID时从这里开始分配, 配置中的排行榜ID必须小于它
+	DYNAMIC_RANK_ID_BASE uint32 = 1 << 31
+	// 运行时创建的排行榜保存在DataDir下的这个文件中
+	RANK_REGISTRY_FILE = "ranks.dat"
+)
+
+func parseRankCron(name, expr string,
+	loc *time.Location) (*engine.CronSchedule, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	schedule, err := engine.ParseCronSchedule(expr, loc)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %v", name, err)
+	}
+	return schedule, nil
+}
+
+// 检查并转换运行时创建排行榜的配置, 周期的规则与配置文件一致
+// 快照榜的内容由快照覆盖, 不能配置清空周期, 并且必须配置快照周期
+func RankConfigFromProto(c *serverproto.RankConfig) (engine.RankEngineConfig,
+	error) {
+	if c.GetPrimaryRank() != 0 {
+		if c.GetClearCron() != "" {
+			return engine.RankEngineConfig{},
+				fmt.Errorf("Clear cron of snapshot rank")
+		}
+		if c.GetSnapshotCron() == "" {
+			return engine.RankEngineConfig{},
+				fmt.Errorf("Expect snapshot cron of snapshot rank")
+		}
+	}
+	return rankConfigFromProto(c)
+}
+
+// 只检查并转换配置本身, 副本的配置中没有清空以及快照周期
+func rankConfigFromProto(c *serverproto.RankConfig) (engine.RankEngineConfig,
+	error) {
+	var config engine.RankEngineConfig
+	if c.GetMaxSize() == 0 {
+		return config, fmt.Errorf("Expect max size > 0")
+	}
+	if c.GetSortOrder() > uint32(engine.SortOrderAscending) {
+		return config, fmt.Errorf("Unknown sort order %d", c.GetSortOrder())
+	}
+	if c.GetTieBreak() > uint32(engine.TieBreakLowestID) {
+		return config, fmt.Errorf("Unknown tie break %d", c.GetTieBreak())
+	}
+	if c.GetRankingMode() > uint32(engine.RankingModeDense) {
+		return config, fmt.Errorf("Unknown ranking mode %d", c.GetRankingMode())
+	}
+	loc := time.UTC
+	if c.GetLocation() != "" {
+		var err error
+		if loc, err = time.LoadLocation(c.GetLocation()); err != nil {
+			return config, fmt.Errorf("Invalid location %q: %v", c.GetLocation(),
+				err)
+		}
+	}
+	config = engine.RankEngineConfig{
+		Kind:             c.GetKind(),
+		MaxSize:          c.GetMaxSize(),
+		RedundantNodeNum: c.GetRedundantNodeNum(),
+		SortOrder:        engine.SortOrder(c.GetSortOrder()),
+		TieBreak:         engine.TieBreak(c.GetTieBreak()),
+		RankingMode:      engine.RankingMode(c.GetRankingMode()),
+		PrimaryRankID:    c.GetPrimaryRank(),
+		HistorySize:      c.GetHistorySize(),
+		SettlementSize:   c.GetSettlementSize(),
+	}
+	// 未配置的周期保持为nil, 与配置文件中的排行榜一致
+	clearPeriod, err := parseRankCron("clear cron", c.GetClearCron(), loc)
+	if err != nil {
+		return config, err
+	}
+	if clearPeriod != nil {
+		config.ClearPeriod = clearPeriod
+	}
+	snapshot, err := parseRankCron("snapshot cron", c.GetSnapshotCron(), loc)
+	if err != nil {
+		return config, err
+	}
+	if snapshot != nil {
+		if config.PrimaryRankID == 0 {
+			return config, fmt.Errorf("Snapshot cron of primary rank")
+		}
+		config.SnapshotPeriod = snapshot
+	}
+	noUpdate, err := parseRankCron("no update cron", c.GetNoUpdateCron(), loc)
+	if err != nil {
+		return config, err
+	}
+	if noUpdate != nil {
+		if c.GetNoUpdateDuration() == 0 {
+			return config, fmt.Errorf("Expect no update duration > 0")
+		}
+		noUpdate.Duration = time.Duration(c.GetNoUpdateDuration()) * time.Second
+		config.NoUpdatePeriod = noUpdate
+	}
+	return config, nil
+}
+
+// 读取DataDir中的运行时创建的排行榜, 文件不存在时返回nil
+func LoadRankRegistry(dir string) (*serverproto.RankRegistry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RANK_REGISTRY_FILE))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	registry := &serverproto.RankRegistry{}
+	if err := proto.Unmarshal(data, registry); err != nil {
+		return nil, fmt.Errorf("Load rank registry: %v", err)
+	}
+	return registry, nil
+}
+
+// 先写入临时文件再重命名, 保证文件是完整的
+func SaveRankRegistry(dir string, registry *serverproto.RankRegistry) error {
+	data, err := proto.Marshal(registry)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	name := filepath.Join(dir, RANK_REGISTRY_FILE)
+	tmpName := name + ".tmp"
+	file, err := os.OpenFile(tmpName, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, name)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(dir)
+}
+
+// 主榜排在快照榜之前, 加载时才能找到对应的主榜
+func (d *Dispatcher) sortedDynamicRanks() []*serverproto.CreateRankRequest {
+	ranks := make([]*serverproto.CreateRankRequest, 0, len(d.dynamicRanks))
+	for _, req := range d.dynamicRanks {
+		ranks = append(ranks, req)
+	}
+	sort.Slice(ranks, func(i, j int) bool {
+		pi := ranks[i].Config.GetPrimaryRank() != 0
+		pj := ranks[j].Config.GetPrimaryRank() != 0
+		if pi != pj {
+			return pj
+		}
+		return ranks[i].GetRank() < ranks[j].GetRank()
+	})
+	return ranks
+}
+
+// 未配置DataDir时运行时创建的排行榜只保存在内存中
+func (d *Dispatcher) saveDynamicRanks() error {
+	if d.config.DataDir == "" {
+		return nil
+	}
+	return SaveRankRegistry(d.config.DataDir, &serverproto.RankRegistry{
+		NextDynamicRankId: proto.Uint32(d.nextDynamicRankID),
+		Ranks:             d.sortedDynamicRanks(),
+	})
+}
+
+// 添加之前运行时创建的排行榜, 必须在RankHandler恢复数据之前调用
+func (d *Dispatcher) loadDynamicRanks() error {
+	registry, err := LoadRankRegistry(d.config.DataDir)
+	if err != nil || registry == nil {
+		return err
+	}
+	if registry.GetNextDynamicRankId() > d.nextDynamicRankID {
+		d.nextDynamicRankID = registry.GetNextDynamicRankId()
+	}
+	for _, req := range registry.Ranks {
+		rankID := req.GetRank()
+		config, err := RankConfigFromProto(req.Config)
+		if err != nil {
+			return fmt.Errorf("Load dynamic rank %d: %v", rankID, err)
+		}
+		rank, err := engine.NewRankEngine(config)
+		if err != nil {
+			return fmt.Errorf("Load dynamic rank %d: %v", rankID, err)
+		}
+		if err := d.addRank(rankID, rank); err != nil {
+			return fmt.Errorf("Load dynamic rank %d: %v", rankID, err)
+		}
+		d.dynamicRanks[rankID] = req
+	}
+	glog.Infof("Load %d dynamic ranks", len(registry.Ranks))
+	return nil
+}
+
+// 在RankHandler的goroutine中执行f并等待完成
+// 之前发给该RankHandler的请求都会先处理完成
+func (d *Dispatcher) runInHandler(h *RankHandler,
+	f func(h *RankHandler) error) error {
+	errChan := make(chan error, 1)
+	h.jobQueue <- Job{run: func(h *RankHandler) {
+		errChan <- f(h)
+	}}
+	return <-errChan
+}
+
+// 处理管理请求, 第二个返回值表示是否是管理请求
+// 管理请求会修改Dispatcher自身的数据, 只能在分发请求的goroutine中处理
+func (d *Dispatcher) HandleAdminJob(job Job) (JobResult, bool) {
+	var errCode int32
+	var resp proto.Message
+	var respType serverproto.MessageType
+	switch job.Msg.(type) {
+	case *serverproto.CreateRankRequest, *serverproto.DropRankRequest,
+		*serverproto.ReloadConfigRequest, *serverproto.SetNoUpdateWindowsRequest:
+		if !job.privileged {
+			glog.Warningf("Admin request %T not from admin address", job.Msg)
+			return JobResult{
+				FrameCtx: job.Frame.Ctx,
+				ErrCode:  ErrPermissionDenied,
+			}, true
+		}
+	}
+	switch msg := job.Msg.(type) {
+	case *serverproto.CreateRankRequest:
+		respType = serverproto.MessageType_TypeCreateRankResponse
+		resp, errCode = d.CreateRank(msg, job.Time)
+	case *serverproto.DropRankRequest:
+		respType = serverproto.MessageType_TypeDropRankResponse
+		resp, errCode = d.DropRank(msg)
+	case *serverproto.ListRanksRequest:
+		respType = serverproto.MessageType_TypeListRanksResponse
+		resp = d.ListRanks()
+	case *serverproto.ReloadConfigRequest:
+		respType = serverproto.MessageType_TypeReloadConfigResponse
+		var reloadResp *serverproto.ReloadConfigResponse
+		reloadResp, errCode = d.ReloadConfig(job.Time)
+		// 加载失败时同样返回错误信息
+		return JobResult{
+			FrameCtx:         job.Frame.Ctx,
+			FramePayloadType: uint32(respType),
+			ErrCode:          errCode,
+			Msg:              reloadResp,
+		}, true
+	default:
+		return JobResult{}, false
+	}
+	if errCode != 0 {
+		return JobResult{
+			FrameCtx: job.Frame.Ctx,
+			ErrCode:  errCode,
+		}, true
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(respType),
+		Msg:              resp,
+	}, true
+}
+
+// 分配下一个没有使用的动态ID
+func (d *Dispatcher) allocRankID() uint32 {
+	for {
+		rankID := d.nextDynamicRankID
+		d.nextDynamicRankID++
+		if d.nextDynamicRankID == 0 {
+			d.nextDynamicRankID = DYNAMIC_RANK_ID_BASE
+		}
+		if _, exist := d.mappedHandlers[rankID]; !exist {
+			return rankID
+		}
+	}
+}
+
+// 创建排行榜并保存到DataDir, 快照榜由主榜的RankHandler处理
+// 同一ID之前的排行榜在WAL中的记录会被清除
+func (d *Dispatcher) CreateRank(msg *serverproto.CreateRankRequest,
+	now time.Time) (*serverproto.CreateRankResponse, int32) {
+	config, err := RankConfigFromProto(msg.Config)
+	if err == nil {
+		_, err = engine.NewRankEngine(config)
+	}
+	if err != nil {
+		glog.Infof("Drop create rank request: %v", err)
+		return nil, ErrInvalidArgument
+	}
+	rankID := msg.GetRank()
+	if _, exist := d.mappedHandlers[rankID]; exist {
+		glog.Infof("Drop create rank request: rank %d already exist", rankID)
+		return nil, ErrRankExist
+	}
+	primaryRankID := config.PrimaryRankID
+	if primaryRankID != 0 && d.primaryHandler(primaryRankID) == nil {
+		glog.Infof("Drop create rank request: primary rank %d not found",
+			primaryRankID)
+		return nil, ErrRankNotFound
+	}
+	if rankID == 0 {
+		rankID = d.allocRankID()
+	}
+	if err := d.startRank(rankID, config, now); err != nil {
+		glog.Errorf("Create rank %d: %v", rankID, err)
+		return nil, ErrServerFailure
+	}
+	d.dynamicRanks[rankID] = &serverproto.CreateRankRequest{
+		Rank:   proto.Uint32(rankID),
+		Config: msg.Config,
+	}
+	if err := d.saveDynamicRanks(); err != nil {
+		glog.Errorf("Create rank %d: save rank registry: %v", rankID, err)
+		d.removeRanks([]uint32{rankID})
+		return nil, ErrServerFailure
+	}
+	glog.Infof("Create rank %d, primary rank %d", rankID, primaryRankID)
+	return &serverproto.CreateRankResponse{Rank: proto.Uint32(rankID)}, 0
+}
+
+// 返回主榜对应的RankHandler, rankID不是主榜时返回nil
+func (d *Dispatcher) primaryHandler(rankID uint32) *RankHandler {
+	handler := d.mappedHandlers[rankID]
+	if handler == nil || handler.primaryRankID != rankID {
+		return nil
+	}
+	return handler
+}
+
+// 运行时添加排行榜, 主榜使用新的RankHandler, 快照榜由主榜的RankHandler处理
+// 调用之前需要检查ID没有使用并且主榜存在
+func (d *Dispatcher) startRank(rankID uint32, config engine.RankEngineConfig,
+	now time.Time) error {
+	rank, err := engine.NewRankEngine(config)
+	if err != nil {
+		return err
+	}
+	handler := d.primaryHandler(config.PrimaryRankID)
+	if config.PrimaryRankID == 0 {
+		handler = NewRankHandler(rankID, rank, d.clock)
+		handler.replica = d.config.ReplicaOf != ""
+		if err := d.openRankHandler(handler, now); err != nil {
+			return err
+		}
+		handler.Start(&d.wg)
+		d.rankHandlers = append(d.rankHandlers, handler)
+	} else {
+		err = d.runInHandler(handler, func(h *RankHandler) error {
+			if err := h.AddSnapshotRank(rankID, rank); err != nil {
+				return err
+			}
+			return h.ResetRank(rankID, now)
+		})
+		if err != nil {
+			d.runInHandler(handler, func(h *RankHandler) error {
+				h.RemoveSnapshotRank(rankID)
+				return nil
+			})
+			return err
+		}
+	}
+	// 添加成功之后才断开副本, 由副本重新同步
+	d.closeReplicas()
+	d.mappedHandlers[rankID] = handler
+	d.rankConfigs[rankID] = config
+	return nil
+}
+
+// 为运行时添加的主榜准备数据目录, 并在WAL中记录添加的时间
+func (d *Dispatcher) openRankHandler(h *RankHandler, now time.Time) error {
+	if d.config.DataDir == "" {
+		if err := h.OpenOutbox("", d.config.SettlementSinks); err != nil {
+			return err
+		}
+		return h.ResetRank(h.primaryRankID, now)
+	}
+	// 删除同一ID之前的排行榜留下的数据
+	dir := d.handlerDir(h.primaryRankID)
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	if err := h.Recover(dir, d.config); err != nil {
+		return err
+	}
+	if err := h.ResetRank(h.primaryRankID, now); err != nil {
+		h.CloseWAL()
+		return err
+	}
+	return nil
+}
+
+// 只能删除运行时创建的排行榜, 删除主榜时同时删除其快照榜
+func (d *Dispatcher) DropRank(msg *serverproto.DropRankRequest) (
+	*serverproto.DropRankResponse, int32) {
+	rankID := msg.GetRank()
+	handler, exist := d.mappedHandlers[rankID]
+	if !exist {
+		glog.Infof("Drop drop rank request: rank %d not found", rankID)
+		return nil, ErrRankNotFound
+	}
+	dropped := []uint32{rankID}
+	if handler.primaryRankID == rankID {
+		for snapshotRankID := range d.rankConfigs {
+			if d.mappedHandlers[snapshotRankID] == handler &&
+				snapshotRankID != rankID {
+				dropped = append(dropped, snapshotRankID)
+			}
+		}
+	}
+	sort.Slice(dropped[1:], func(i, j int) bool {
+		return dropped[1+i] < dropped[1+j]
+	})
+	for _, id := range dropped {
+		if _, exist := d.dynamicRanks[id]; !exist {
+			glog.Infof("Drop drop rank request: rank %d is not dynamic", id)
+			return nil, ErrStaticRank
+		}
+	}
+	d.removeRanks(dropped)
+	if err := d.saveDynamicRanks(); err != nil {
+		// 重启之后排行榜会重新出现, 数据已经删除
+		glog.Errorf("Drop rank %d: save rank registry: %v", rankID, err)
+		return nil, ErrServerFailure
+	}
+	glog.Infof("Drop ranks %v", dropped)
+	return &serverproto.DropRankResponse{
+		Rank:    proto.Uint32(rankID),
+		Dropped: dropped,
+	}, 0
+}
+
+// 从Dispatcher以及RankHandler中删除排行榜, 删除主榜时ranks必须包含其快照榜
+// 并且主榜排在最前面, 先删除快照榜再停止RankHandler并删除其数据目录
+func (d *Dispatcher) removeRanks(ranks []uint32) {
+	d.closeReplicas()
+	for i := len(ranks) - 1; i >= 0; i-- {
+		rankID := ranks[i]
+		handler := d.mappedHandlers[rankID]
+		delete(d.mappedHandlers, rankID)
+		delete(d.rankConfigs, rankID)
+		delete(d.dynamicRanks, rankID)
+		if handler.primaryRankID != rankID {
+			d.runInHandler(handler, func(h *RankHandler) error {
+				h.RemoveSnapshotRank(rankID)
+				return nil
+			})
+			continue
+		}
+		// 等待之前的请求处理完成再停止
+		d.runInHandler(handler, func(h *RankHandler) error { return nil })
+		handler.Stop()
+		<-handler.exited
+		for i, h := range d.rankHandlers {
+			if h == handler {
+				d.rankHandlers = append(d.rankHandlers[:i], d.rankHandlers[i+1:]...)
+				break
+			}
+		}
+		if d.config.DataDir != "" {
+			if err := os.RemoveAll(d.handlerDir(rankID)); err != nil {
+				glog.Errorf("Remove data of rank %d: %v", rankID, err)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) ListRanks() *serverproto.ListRanksResponse {
+	resp := &serverproto.ListRanksResponse{}
+	for rankID, config := range d.rankConfigs {
+		_, dynamic := d.dynamicRanks[rankID]
+		resp.Ranks = append(resp.Ranks, &serverproto.RankInfo{
+			Rank:        proto.Uint32(rankID),
+			PrimaryRank: proto.Uint32(config.PrimaryRankID),
+			Kind:        proto.String(config.EngineKind()),
+			MaxSize:     proto.Uint32(config.MaxSize),
+			Dynamic:     proto.Bool(dynamic),
+		})
+	}
+	sort.Slice(resp.Ranks, func(i, j int) bool {
+		return resp.Ranks[i].GetRank() < resp.Ranks[j].GetRank()
+	})
+	return resp
+}
+
+// 清空排行榜并删除其历史排行榜以及禁止更新时间段, 用于运行时创建排行榜
+// 重放WAL时同一ID之前的排行榜的记录都会被这条记录之后的状态覆盖
+func (h *RankHandler) ResetRank(rankID uint32, now time.Time) error {
+	rank := h.FindRank(rankID)
+	if rank == nil {
+		return fmt.Errorf("Rank %d not found", rankID)
+	}
+	err := h.AppendWAL(&WALRecord{
+		Type:   WALRecordReset,
+		RankID: rankID,
+		Time:   now,
+	})
+	if err != nil {
+		return err
+	}
+	h.resetRank(rankID, rank, now)
+	return nil
+}
+
+func (h *RankHandler) resetRank(rankID uint32, rank engine.RankEngine,
+	now time.Time) {
+	rank.Clear()
+	rank.SetLastClearTime(now)
+	rank.SetLastSnapshotTime(now)
+	delete(h.history, rankID)
+	delete(h.noUpdateWindows, rankID)
+}
+
+// 删除快照榜, 之后的转储中不再包含它
+func (h *RankHandler) RemoveSnapshotRank(rankID uint32) {
+	delete(h.snapshotRanks, rankID)
+	delete(h.history, rankID)
+	delete(h.noUpdateWindows, rankID)
+	glog.Infof("Remove snapshot rank %d from %d", rankID, h.primaryRankID)
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"fmt"
+	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,23 +15,50 @@ import (
 
 const (
 	MAX_BUFFERED_JOB = 128
+	// 单次查询最多返回的数据量, 保证回包不超过frame.MAX_PAYLOAD_SIZE
+	MAX_QUERY_NUM = 1000
 )
 
 type RankHandler struct {
+	clock         engine.Clock
 	primaryRankID uint32
 	primaryRank   engine.RankEngine
 	snapshotRanks map[uint32]engine.RankEngine
-	done          chan struct{}
-	jobQueue      chan Job
+	// 每个排行榜的历史排行榜, 从旧到新排列
+	history map[uint32][]*HistoryBoard
+	// 运行时修改过的禁止更新时间段, 优先于排行榜的配置
+	noUpdateWindows map[uint32]*NoUpdateWindows
+	done            chan struct{}
+	// 处理请求的goroutine退出时关闭
+	exited    chan struct{}
+	jobQueue  chan Job
+	walDir    string
+	wal       *WAL
+	appConfig AppConfig
+	// 最近一次转储的LSN
+	checkpointLSN   uint64
+	checkpointQueue chan *pendingCheckpoint
+	checkpointExit  chan struct{}
+	// 等待发送的结算, 没有接收方时为nil
+	outbox *Outbox
+	// 订阅修改记录的副本
+	replicas []*replicaSubscriber
+	// 副本的排行榜只通过主节点的记录修改, 不执行到期的清空以及快照
+	replica bool
 }
 
-func NewRankHandler(rankID uint32, rank engine.RankEngine) *RankHandler {
+func NewRankHandler(rankID uint32, rank engine.RankEngine,
+	clock engine.Clock) *RankHandler {
 	return &RankHandler{
-		primaryRankID: rankID,
-		primaryRank:   rank,
-		done:          make(chan struct{}),
-		jobQueue:      make(chan Job, MAX_BUFFERED_JOB),
-		snapshotRanks: make(map[uint32]engine.RankEngine),
+		clock:           clock,
+		primaryRankID:   rankID,
+		primaryRank:     rank,
+		done:            make(chan struct{}),
+		exited:          make(chan struct{}),
+		jobQueue:        make(chan Job, MAX_BUFFERED_JOB),
+		snapshotRanks:   make(map[uint32]engine.RankEngine),
+		history:         make(map[uint32][]*HistoryBoard),
+		noUpdateWindows: make(map[uint32]*NoUpdateWindows),
 	}
 }
 
@@ -62,21 +91,437 @@ func RankUnitFromProto(u *serverproto.RankUnit) engine.RankUnit {
 	}
 }
 
+// 加载dir中最新的转储并重放之后的WAL, 然后打开WAL记录之后的修改
+// 必须在Start之前调用
+func (h *RankHandler) Recover(dir string, config AppConfig) error {
+	if config.WALSyncPolicy == WALSyncInterval && config.WALSyncInterval <= 0 {
+		return fmt.Errorf("Invalid WAL sync interval %s", config.WALSyncInterval)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	// 重放WAL时会重新生成结算, 由outbox忽略已经加入的结算
+	if err := h.OpenOutbox(dir, config.SettlementSinks); err != nil {
+		return err
+	}
+	checkpoint, err := LoadLatestCheckpoint(dir)
+	if err != nil {
+		return err
+	}
+	fromLSN := uint64(1)
+	if checkpoint != nil {
+		if err := h.LoadCheckpoint(checkpoint); err != nil {
+			return err
+		}
+		fromLSN = checkpoint.LSN
+		glog.Infof("RankHandler %d load checkpoint %d", h.primaryRankID,
+			checkpoint.LSN)
+	}
+	nextLSN, err := ReplayWAL(dir, fromLSN, h.ApplyWALRecord)
+	if err != nil {
+		return err
+	}
+	glog.Infof("RankHandler %d replay WAL [%d, %d)", h.primaryRankID,
+		fromLSN, nextLSN)
+	if h.wal, err = OpenWAL(dir, nextLSN, config.WALSyncPolicy); err != nil {
+		return err
+	}
+	h.walDir = dir
+	h.appConfig = config
+	h.checkpointLSN = fromLSN
+	return nil
+}
+
+// 加载转储中的排行榜, 历史排行榜以及禁止更新时间段, 忽略未知的排行榜
+func (h *RankHandler) LoadCheckpoint(checkpoint *Checkpoint) error {
+	for rankID, data := range checkpoint.Ranks {
+		rank := h.FindRank(rankID)
+		if rank == nil {
+			glog.Warningf("Ignore checkpoint of unknown rank %d", rankID)
+			continue
+		}
+		if err := rank.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("Load rank %d: %v", rankID, err)
+		}
+	}
+	for _, history := range checkpoint.History {
+		if err := h.LoadHistory(history); err != nil {
+			return err
+		}
+	}
+	for rankID, windows := range checkpoint.NoUpdateWindows {
+		if h.FindRank(rankID) == nil {
+			glog.Warningf("Ignore no update windows of unknown rank %d", rankID)
+			continue
+		}
+		h.noUpdateWindows[rankID] = windows
+	}
+	return nil
+}
+
+// 打开dir中的outbox, dir为空时只在内存中保存结算, 必须在Start之前调用
+func (h *RankHandler) OpenOutbox(dir string, sinks []SettlementSink) error {
+	if len(sinks) == 0 {
+		return nil
+	}
+	outbox, err := OpenOutbox(dir, sinks)
+	if err != nil {
+		return err
+	}
+	h.outbox = outbox
+	return nil
+}
+
+// 到期的清空或者快照
+type periodEvent struct {
+	rankID     uint32
+	rank       engine.RankEngine
+	recordType WALRecordType
+	time       time.Time
+}
+
+// 恢复数据之后补上停机期间错过的清空以及快照, 必须在Start之前调用
+func (h *RankHandler) CatchUp(now time.Time) error {
+	glog.Infof("RankHandler %d catch up periods until %s", h.primaryRankID, now)
+	return h.AdvanceTo(now)
+}
+
+// 排行榜从未执行过清空或者快照时从now开始计算周期, 不补之前的周期
+// 否则cron等周期需要从零值时间开始逐个计算
+func (h *RankHandler) InitPeriodTimes(now time.Time) error {
+	initRank := func(rankID uint32, rank engine.RankEngine) error {
+		config := rank.Config()
+		clearZero := !engine.ScheduleEmpty(config.ClearPeriod) &&
+			rank.LastClearTime().IsZero()
+		snapshotZero := rankID != h.primaryRankID &&
+			!engine.ScheduleEmpty(config.SnapshotPeriod) &&
+			rank.LastSnapshotTime().IsZero()
+		if !clearZero && !snapshotZero {
+			return nil
+		}
+		err := h.AppendWAL(&WALRecord{
+			Type:   WALRecordInitTime,
+			RankID: rankID,
+			Time:   now,
+		})
+		if err != nil {
+			return err
+		}
+		initPeriodTimes(rank, now)
+		glog.Infof("Rank %d init period times at %s", rankID, now)
+		return nil
+	}
+	if err := initRank(h.primaryRankID, h.primaryRank); err != nil {
+		return err
+	}
+	for rankID, rank := range h.snapshotRanks {
+		if err := initRank(rankID, rank); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 只设置为零值的时间, 已经执行过的周期不受影响
+func initPeriodTimes(rank engine.RankEngine, t time.Time) {
+	if rank.LastClearTime().IsZero() {
+		rank.SetLastClearTime(t)
+	}
+	if rank.LastSnapshotTime().IsZero() {
+		rank.SetLastSnapshotTime(t)
+	}
+}
+
+// 执行(上一次执行的时间, now]之间所有到期的清空以及快照
+// 按时间顺序执行, 同一时刻先快照再清空, 快照的内容就是到期那一刻的排行榜
+// 这段时间内排行榜没有更新, 连续的周期中只有第一个和最后一个会影响结果
+func (h *RankHandler) AdvanceTo(now time.Time) error {
+	if err := h.InitPeriodTimes(now); err != nil {
+		return err
+	}
+	var events []periodEvent
+	add := func(rankID uint32, rank engine.RankEngine,
+		recordType WALRecordType, period engine.Schedule, last time.Time) {
+		if engine.ScheduleEmpty(period) {
+			return
+		}
+		n, first, lastBoundary := period.Between(last, now)
+		if n == 0 {
+			return
+		}
+		events = append(events, periodEvent{rankID, rank, recordType, first})
+		if n > 1 {
+			glog.Infof("Rank %d reach %d %s periods from %s to %s",
+				rankID, n, recordType, first, lastBoundary)
+			events = append(events,
+				periodEvent{rankID, rank, recordType, lastBoundary})
+		}
+	}
+	for rankID, rank := range h.snapshotRanks {
+		add(rankID, rank, WALRecordSnapshot, rank.Config().SnapshotPeriod,
+			rank.LastSnapshotTime())
+		add(rankID, rank, WALRecordClear, rank.Config().ClearPeriod,
+			rank.LastClearTime())
+	}
+	add(h.primaryRankID, h.primaryRank, WALRecordClear,
+		h.primaryRank.Config().ClearPeriod, h.primaryRank.LastClearTime())
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].time.Equal(events[j].time) {
+			return events[i].time.Before(events[j].time)
+		}
+		return events[i].recordType == WALRecordSnapshot &&
+			events[j].recordType == WALRecordClear
+	})
+
+	for _, e := range events {
+		record := &WALRecord{
+			Type:   e.recordType,
+			RankID: e.rankID,
+			Time:   e.time,
+		}
+		if err := h.AppendWAL(record); err != nil {
+			// 已经执行的事件更新了对应的时间, 下一次从失败的事件继续
+			return err
+		}
+		if e.recordType == WALRecordSnapshot {
+			h.SnapshotRank(e.rankID, e.rank, e.time, record.LSN)
+			glog.Infof("Snapshot primary rank %d to rank %d at %s",
+				h.primaryRankID, e.rankID, e.time)
+		} else {
+			h.ClearRank(e.rankID, e.rank, e.time, record.LSN)
+			glog.Infof("Clear rank %d at %s", e.rankID, e.time)
+		}
+	}
+	return nil
+}
+
+// 重放一条WAL记录, 不做任何时间相关的检查
+func (h *RankHandler) ApplyWALRecord(r *WALRecord) error {
+	rank := h.FindRank(r.RankID)
+	if rank == nil {
+		glog.Warningf("Ignore WAL record %d of unknown rank %d", r.LSN, r.RankID)
+		return nil
+	}
+	switch r.Type {
+	case WALRecordUpdate:
+		for _, u := range r.Units {
+			rank.Update(u, r.Mode)
+		}
+	case WALRecordUpdateMany:
+		rank.UpdateMany(r.Units, r.Mode)
+	case WALRecordDelete:
+		rank.Delete(r.ID)
+	case WALRecordClear:
+		h.ClearRank(r.RankID, rank, r.Time, r.LSN)
+	case WALRecordSnapshot:
+		h.SnapshotRank(r.RankID, rank, r.Time, r.LSN)
+	case WALRecordInitTime:
+		initPeriodTimes(rank, r.Time)
+	case WALRecordNoUpdateWindows:
+		h.SetNoUpdateWindows(r.RankID, r.Windows)
+	case WALRecordReset:
+		h.resetRank(r.RankID, rank, r.Time)
+	default:
+		return fmt.Errorf("Unexpected WAL record type %s", r.Type)
+	}
+	return nil
+}
+
+// 在修改排行榜之前写入WAL并发送给副本, 未开启WAL时只发送给副本
+func (h *RankHandler) AppendWAL(r *WALRecord) error {
+	if h.wal != nil {
+		if err := h.wal.Append(r); err != nil {
+			glog.Errorf("RankHandler %d append WAL: %v", h.primaryRankID, err)
+			return err
+		}
+	}
+	h.PublishRecord(r)
+	return nil
+}
+
+// 为所有排行榜创建快照, 交给后台goroutine写入
+// 排行榜没有修改或者上一次转储还没有完成时不做任何操作
+func (h *RankHandler) Checkpoint() {
+	lsn := h.wal.NextLSN()
+	if lsn == h.checkpointLSN {
+		return
+	}
+	// 切换WAL段, 转储之前的段才能在转储完成后删除
+	if err := h.wal.Rotate(); err != nil {
+		glog.Errorf("RankHandler %d rotate WAL: %v", h.primaryRankID, err)
+		return
+	}
+	pending := h.snapshotState(lsn)
+	select {
+	case h.checkpointQueue <- pending:
+		h.checkpointLSN = lsn
+	default:
+		glog.Warningf("RankHandler %d skip checkpoint %d: writer busy",
+			h.primaryRankID, lsn)
+	}
+}
+
+// 立即转储所有排行榜并等待写入完成, 未开启WAL时直接返回
+// 用于修改排行榜的配置, 重启之后只会使用新的配置重放之后的WAL
+func (h *RankHandler) CheckpointNow() error {
+	if h.wal == nil {
+		return nil
+	}
+	lsn := h.wal.NextLSN()
+	if err := h.wal.Rotate(); err != nil {
+		return err
+	}
+	pending := h.snapshotState(lsn)
+	if h.checkpointQueue == nil {
+		if err := h.WriteCheckpoint(pending); err != nil {
+			return err
+		}
+	} else {
+		// 等待后台正在写入的转储完成, 保证删除转储时不会并发
+		pending.done = make(chan error, 1)
+		h.checkpointQueue <- pending
+		if err := <-pending.done; err != nil {
+			return err
+		}
+	}
+	h.checkpointLSN = lsn
+	return nil
+}
+
+// 复制所有排行榜的当前状态, 之后可以在其他goroutine中转储
+func (h *RankHandler) snapshotState(lsn uint64) *pendingCheckpoint {
+	pending := &pendingCheckpoint{
+		lsn:             lsn,
+		ranks:           make(map[uint32]engine.RankEngine),
+		history:         make(map[uint32][]*HistoryBoard),
+		noUpdateWindows: make(map[uint32]*NoUpdateWindows),
+	}
+	pending.ranks[h.primaryRankID] = h.primaryRank.CreateSnapshot()
+	for rankID, rank := range h.snapshotRanks {
+		pending.ranks[rankID] = rank.CreateSnapshot()
+	}
+	// 历史排行榜不会再修改, 只需要复制列表
+	for rankID, boards := range h.history {
+		pending.history[rankID] = append([]*HistoryBoard(nil), boards...)
+	}
+	// 修改时整体替换, 只需要复制map
+	for rankID, windows := range h.noUpdateWindows {
+		pending.noUpdateWindows[rankID] = windows
+	}
+	return pending
+}
+
+func (h *RankHandler) WriteCheckpoint(pending *pendingCheckpoint) error {
+	checkpoint, err := pending.Checkpoint()
+	if err != nil {
+		return err
+	}
+	if err := WriteCheckpoint(h.walDir, checkpoint); err != nil {
+		return err
+	}
+	glog.Infof("RankHandler %d write checkpoint %d", h.primaryRankID,
+		checkpoint.LSN)
+	return PruneCheckpoints(h.walDir, h.appConfig.CheckpointRetain)
+}
+
+func (h *RankHandler) runCheckpointWriter(wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(h.checkpointExit)
+	for pending := range h.checkpointQueue {
+		err := h.WriteCheckpoint(pending)
+		if err != nil {
+			glog.Errorf("RankHandler %d write checkpoint %d: %v",
+				h.primaryRankID, pending.lsn, err)
+		}
+		if pending.done != nil {
+			pending.done <- err
+		}
+	}
+}
+
+// 等待后台转储完成, 然后转储所有排行榜并关闭WAL
+// 下次启动时无需重放WAL
+func (h *RankHandler) CloseWAL() {
+	if h.wal == nil {
+		return
+	}
+	if h.checkpointQueue != nil {
+		close(h.checkpointQueue)
+		<-h.checkpointExit
+		h.checkpointQueue = nil
+	}
+	// 后台转储已经退出, 可以直接使用排行榜本身
+	pending := &pendingCheckpoint{
+		lsn:             h.wal.NextLSN(),
+		ranks:           map[uint32]engine.RankEngine{h.primaryRankID: h.primaryRank},
+		history:         h.history,
+		noUpdateWindows: h.noUpdateWindows,
+	}
+	for rankID, rank := range h.snapshotRanks {
+		pending.ranks[rankID] = rank
+	}
+	if err := h.WriteCheckpoint(pending); err != nil {
+		glog.Errorf("RankHandler %d write checkpoint %d: %v",
+			h.primaryRankID, pending.lsn, err)
+	}
+	if err := h.wal.Close(); err != nil {
+		glog.Errorf("RankHandler %d close WAL: %v", h.primaryRankID, err)
+	}
+	h.wal = nil
+}
+
 func (h *RankHandler) Start(wg *sync.WaitGroup) {
-	const CRON_CHECK_INTERVAL time.Duration = time.Millisecond * 500
-	c := time.NewTicker(CRON_CHECK_INTERVAL).C
+	var syncC, checkpointC <-chan time.Time
+	var tickers []engine.Ticker
+	if h.wal != nil {
+		if h.appConfig.WALSyncPolicy == WALSyncInterval {
+			ticker := h.clock.NewTicker(h.appConfig.WALSyncInterval)
+			tickers = append(tickers, ticker)
+			syncC = ticker.C()
+		}
+		if h.appConfig.CheckpointInterval > 0 {
+			ticker := h.clock.NewTicker(h.appConfig.CheckpointInterval)
+			tickers = append(tickers, ticker)
+			checkpointC = ticker.C()
+		}
+		h.checkpointQueue = make(chan *pendingCheckpoint, 1)
+		h.checkpointExit = make(chan struct{})
+		wg.Add(1)
+		go h.runCheckpointWriter(wg)
+	}
+	if h.outbox != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.outbox.Run(h.clock, h.done)
+		}()
+	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
+		defer close(h.exited)
+		defer func() {
+			for _, ticker := range tickers {
+				ticker.Stop()
+			}
+		}()
 		for {
 			select {
 			case job := <-h.jobQueue:
 				h.HandleJob(job)
 			case <-h.done:
+				h.CloseWAL()
+				if h.outbox != nil {
+					h.outbox.Close()
+				}
 				glog.Infof("RankHandler %d exit", h.primaryRankID)
 				return
-			case now := <-c:
-				h.CronCheckAllRanks(now)
+			case <-syncC:
+				if err := h.wal.Sync(); err != nil {
+					glog.Errorf("RankHandler %d sync WAL: %v", h.primaryRankID, err)
+				}
+			case <-checkpointC:
+				h.Checkpoint()
 			}
 		}
 	}()
@@ -86,12 +531,24 @@ func (h *RankHandler) Stop() {
 	close(h.done)
 }
 
+// 定时检查由Dispatcher通过jobQueue发送, 之前收到的请求都已经处理完成
 func (h *RankHandler) CronCheckAllRanks(now time.Time) {
-	for rankID, rank := range h.snapshotRanks {
-		h.MaybeClearRank(rankID, rank, now)
-		h.MaybeSnapshotRank(rankID, rank, now)
+	if err := h.AdvanceTo(now); err != nil {
+		glog.Errorf("RankHandler %d advance to %s: %v", h.primaryRankID, now, err)
 	}
-	h.MaybeClearRank(h.primaryRankID, h.primaryRank, now)
+}
+
+// 返回请求是否需要回包
+func jobNeedReply(msg proto.Message) bool {
+	switch msg := msg.(type) {
+	case *serverproto.UpdateRequest:
+		return msg.GetReply()
+	case *serverproto.DeleteRequest:
+		return msg.GetReply()
+	case *serverproto.BatchUpdateRequest:
+		return msg.GetReply()
+	}
+	return true
 }
 
 func (h *RankHandler) FindRank(rankID uint32) engine.RankEngine {
@@ -103,18 +560,44 @@ func (h *RankHandler) FindRank(rankID uint32) engine.RankEngine {
 }
 
 func (h *RankHandler) HandleJob(job Job) {
+	if job.cron {
+		if !h.replica {
+			h.CronCheckAllRanks(job.Time)
+		}
+		return
+	}
+	if job.run != nil {
+		job.run(h)
+		return
+	}
 	glog.V(2).Infof("Rank: %d, Ctx: %d", job.RankID, job.Frame.Ctx)
+	// 按请求到达的时间执行到期的清空以及快照
+	// 快照中只包含到期之前到达的更新, 副本的清空以及快照来自主节点
+	now := job.Time
+	var err error
+	if !h.replica {
+		err = h.AdvanceTo(now)
+	}
+	if err != nil {
+		if jobNeedReply(job.Msg) {
+			job.resultChan <- JobResult{
+				FrameCtx: job.Frame.Ctx,
+				ErrCode:  ErrServerFailure,
+			}
+		}
+		return
+	}
+	if msg, ok := job.Msg.(*serverproto.BatchUpdateRequest); ok {
+		jobResult := h.HandleBatchUpdate(job, msg, now)
+		if msg.GetReply() {
+			job.resultChan <- jobResult
+		}
+		return
+	}
 	rank := h.FindRank(job.RankID)
 	if rank == nil {
-		glog.Fatalf("Rank %d not found!")
-	}
-	now := time.Now()
-	if job.RankID == h.primaryRankID {
-		h.MaybeSnapshotPrimaryRank(now)
-	} else {
-		h.MaybeSnapshotRank(job.RankID, rank, now)
+		glog.Fatalf("Rank %d not found!", job.RankID)
 	}
-	h.MaybeClearRank(job.RankID, rank, now)
 	var jobResult JobResult
 	switch msg := job.Msg.(type) {
 	case *serverproto.GetRequest:
@@ -123,6 +606,22 @@ func (h *RankHandler) HandleJob(job Job) {
 		jobResult = h.HandleGetByRank(job, rank, msg)
 	case *serverproto.GetRangeRequest:
 		jobResult = h.HandleGetRange(job, rank, msg)
+	case *serverproto.GetAroundRequest:
+		jobResult = h.HandleGetAround(job, rank, msg)
+	case *serverproto.MultiGetRequest:
+		jobResult = h.HandleMultiGet(job, rank, msg)
+	case *serverproto.SubsetRankRequest:
+		jobResult = h.HandleSubsetRank(job, rank, msg)
+	case *serverproto.RankOfKeyRequest:
+		jobResult = h.HandleRankOfKey(job, rank, msg)
+	case *serverproto.GetByKeyRangeRequest:
+		jobResult = h.HandleGetByKeyRange(job, rank, msg)
+	case *serverproto.GetHistoryRangeRequest:
+		jobResult = h.HandleGetHistoryRange(job, msg)
+	case *serverproto.GetNoUpdateWindowsRequest:
+		jobResult = h.HandleGetNoUpdateWindows(job, rank)
+	case *serverproto.SetNoUpdateWindowsRequest:
+		jobResult = h.HandleSetNoUpdateWindows(job, msg)
 	case *serverproto.UpdateRequest:
 		jobResult = h.HandleUpdate(job, rank, msg, now)
 		if !msg.GetReply() {
@@ -140,52 +639,36 @@ func (h *RankHandler) HandleJob(job Job) {
 	job.resultChan <- jobResult
 }
 
-func (h *RankHandler) MaybeSnapshotPrimaryRank(now time.Time) {
-	for rankID, rank := range h.snapshotRanks {
-		h.MaybeSnapshotRank(rankID, rank, now)
-	}
-}
-
-func (h *RankHandler) MaybeSnapshotRank(rankID uint32, rank engine.RankEngine,
-	now time.Time) bool {
-	if rank.Config().SnapshotPeriod.Empty() {
-		return false
-	}
-	lastTime := rank.LastSnapshotTime()
-	nextTime := rank.Config().SnapshotPeriod.NextTime(lastTime)
-	if now.Before(nextTime) {
-		return false
-	}
+// lsn为对应WAL记录的LSN, 未开启WAL时为0
+func (h *RankHandler) SnapshotRank(rankID uint32, rank engine.RankEngine,
+	now time.Time, lsn uint64) {
+	h.Settle(rankID, rank, WALRecordSnapshot, now, lsn)
+	h.ArchiveRank(rankID, rank, WALRecordSnapshot, now)
 	rank.CopyFrom(h.primaryRank)
 	rank.SetLastSnapshotTime(now)
-	glog.Infof("Snapshot primary rank %d to rank %d", h.primaryRankID, rankID)
-	return true
 }
 
-func (h *RankHandler) MaybeClearRank(rankID uint32, rank engine.RankEngine,
-	now time.Time) bool {
-	if rank.Config().ClearPeriod.Empty() {
-		return false
-	}
-	lastTime := rank.LastClearTime()
-	nextTime := rank.Config().ClearPeriod.NextTime(lastTime)
-	if now.Before(nextTime) {
-		return false
-	}
+func (h *RankHandler) ClearRank(rankID uint32, rank engine.RankEngine,
+	now time.Time, lsn uint64) {
+	h.Settle(rankID, rank, WALRecordClear, now, lsn)
+	h.ArchiveRank(rankID, rank, WALRecordClear, now)
 	rank.Clear()
 	rank.SetLastClearTime(now)
-	glog.Infof("Clear rank %d", rankID)
-	return true
 }
 
 func (h *RankHandler) HandleGet(job Job, rank engine.RankEngine,
 	msg *serverproto.GetRequest) JobResult {
-	_, pos, value := rank.Get(msg.GetId())
+	exist, pos, value := rank.Get(msg.GetId())
+	var displayRank uint32
+	if exist {
+		displayRank = engine.DisplayRank(rank, pos)
+	}
 
 	resp := &serverproto.GetResponse{
-		Rank: proto.Uint32(job.RankID),
-		Pos:  proto.Uint32(pos),
-		Data: RankUnitToProto(value),
+		Rank:        proto.Uint32(job.RankID),
+		Pos:         proto.Uint32(pos),
+		Data:        RankUnitToProto(value),
+		DisplayRank: proto.Uint32(displayRank),
 	}
 	return JobResult{
 		FrameCtx:         job.Frame.Ctx,
@@ -197,15 +680,17 @@ func (h *RankHandler) HandleGet(job Job, rank engine.RankEngine,
 func (h *RankHandler) HandleGetByRank(job Job, rank engine.RankEngine,
 	msg *serverproto.GetByRankRequest) JobResult {
 	exist, value := rank.GetByRank(msg.GetPos())
-	var pos uint32
+	var pos, displayRank uint32
 	if exist {
 		pos = msg.GetPos()
+		displayRank = engine.DisplayRank(rank, pos)
 	}
 
 	resp := &serverproto.GetByRankResponse{
-		Rank: proto.Uint32(job.RankID),
-		Pos:  proto.Uint32(pos),
-		Data: RankUnitToProto(value),
+		Rank:        proto.Uint32(job.RankID),
+		Pos:         proto.Uint32(pos),
+		Data:        RankUnitToProto(value),
+		DisplayRank: proto.Uint32(displayRank),
 	}
 	return JobResult{
 		FrameCtx:         job.Frame.Ctx,
@@ -223,52 +708,234 @@ func (h *RankHandler) HandleGetRange(job Job, rank engine.RankEngine,
 	}
 
 	resp := &serverproto.GetRangeResponse{
+		Rank:        proto.Uint32(job.RankID),
+		Total:       proto.Uint32(rank.Size()),
+		Data:        data,
+		Start:       proto.Uint32(msg.GetStart()),
+		DisplayRank: engine.DisplayRanks(rank, msg.GetStart(), values),
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeGetRangeResponse),
+		Msg:              resp,
+	}
+}
+
+func (h *RankHandler) HandleGetAround(job Job, rank engine.RankEngine,
+	msg *serverproto.GetAroundRequest) JobResult {
+	// 前后的数据量各自不超过maxAround, 加上查询的数据不超过MAX_QUERY_NUM
+	const maxAround = (MAX_QUERY_NUM - 1) / 2
+	before, after := msg.GetBefore(), msg.GetAfter()
+	if before > maxAround {
+		before = maxAround
+	}
+	if after > maxAround {
+		after = maxAround
+	}
+	exist, pos, start, values := rank.GetAround(msg.GetId(), before, after)
+	data := make([]*serverproto.RankUnit, len(values))
+	for i, u := range values {
+		data[i] = RankUnitToProto(u)
+	}
+
+	resp := &serverproto.GetAroundResponse{
+		Rank:        proto.Uint32(job.RankID),
+		Pos:         proto.Uint32(pos),
+		Start:       proto.Uint32(start),
+		Total:       proto.Uint32(rank.Size()),
+		Data:        data,
+		DisplayRank: engine.DisplayRanks(rank, start, values),
+		Exist:       proto.Bool(exist),
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeGetAroundResponse),
+		Msg:              resp,
+	}
+}
+
+func (h *RankHandler) HandleMultiGet(job Job, rank engine.RankEngine,
+	msg *serverproto.MultiGetRequest) JobResult {
+	results := make([]*serverproto.MultiGetResult, len(msg.Id))
+	for i, id := range msg.Id {
+		exist, pos, value := rank.Get(id)
+		var displayRank uint32
+		if exist {
+			displayRank = engine.DisplayRank(rank, pos)
+		}
+		results[i] = &serverproto.MultiGetResult{
+			Id:          proto.Uint64(id),
+			Exist:       proto.Bool(exist),
+			Pos:         proto.Uint32(pos),
+			Data:        RankUnitToProto(value),
+			DisplayRank: proto.Uint32(displayRank),
+		}
+	}
+
+	resp := &serverproto.MultiGetResponse{
+		Rank:    proto.Uint32(job.RankID),
+		Results: results,
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeMultiGetResponse),
+		Msg:              resp,
+	}
+}
+
+func (h *RankHandler) HandleSubsetRank(job Job, rank engine.RankEngine,
+	msg *serverproto.SubsetRankRequest) JobResult {
+	values, missing := engine.RankSubset(rank, msg.Id)
+	units := make([]*serverproto.SubsetRankUnit, len(values))
+	for i, u := range values {
+		units[i] = &serverproto.SubsetRankUnit{
+			Data:         RankUnitToProto(u.RankUnit),
+			Pos:          proto.Uint32(u.Pos),
+			RelativeRank: proto.Uint32(u.RelativeRank),
+		}
+	}
+
+	resp := &serverproto.SubsetRankResponse{
+		Rank:      proto.Uint32(job.RankID),
+		Units:     units,
+		MissingId: missing,
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeSubsetRankResponse),
+		Msg:              resp,
+	}
+}
+
+func (h *RankHandler) HandleRankOfKey(job Job, rank engine.RankEngine,
+	msg *serverproto.RankOfKeyRequest) JobResult {
+	resp := &serverproto.RankOfKeyResponse{
 		Rank:  proto.Uint32(job.RankID),
+		Key:   proto.Uint64(msg.GetKey()),
+		Pos:   proto.Uint32(rank.RankOfKey(msg.GetKey())),
 		Total: proto.Uint32(rank.Size()),
-		Data:  data,
 	}
 	return JobResult{
 		FrameCtx:         job.Frame.Ctx,
-		FramePayloadType: uint32(serverproto.MessageType_TypeGetRangeResponse),
+		FramePayloadType: uint32(serverproto.MessageType_TypeRankOfKeyResponse),
 		Msg:              resp,
 	}
 }
 
-func (h *RankHandler) HandleUpdate(job Job, rank engine.RankEngine,
-	msg *serverproto.UpdateRequest, now time.Time) (res JobResult) {
+func (h *RankHandler) HandleGetByKeyRange(job Job, rank engine.RankEngine,
+	msg *serverproto.GetByKeyRangeRequest) JobResult {
+	// 0以及超过上限时都按上限返回
+	limit := msg.GetLimit()
+	if limit == 0 || limit > MAX_QUERY_NUM {
+		limit = MAX_QUERY_NUM
+	}
+	start, values := rank.GetByKeyRange(msg.GetMinKey(), msg.GetMaxKey(), limit)
+	data := make([]*serverproto.RankUnit, len(values))
+	for i, u := range values {
+		data[i] = RankUnitToProto(u)
+	}
 
+	resp := &serverproto.GetByKeyRangeResponse{
+		Rank:        proto.Uint32(job.RankID),
+		Start:       proto.Uint32(start),
+		Total:       proto.Uint32(rank.Size()),
+		Data:        data,
+		DisplayRank: engine.DisplayRanks(rank, start, values),
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeGetByKeyRangeResponse),
+		Msg:              resp,
+	}
+}
+
+// 检查当前是否允许更新排行榜, 返回对应的错误码, 0表示允许
+// 禁止更新时同时返回生效的时间段名字以及重新开放更新的时间
+func (h *RankHandler) CheckUpdate(rankID uint32, rank engine.RankEngine,
+	bypassNoUpdate bool, timeRange *serverproto.ServerTimeRange,
+	now time.Time) (int32, string, time.Time) {
 	ts := now.Unix()
-	begin := msg.ServerTimeRange.GetBegin()
-	end := msg.ServerTimeRange.GetEnd()
+	begin := timeRange.GetBegin()
+	end := timeRange.GetEnd()
 	if (begin != 0 || end != 0) && (ts < begin || ts >= end) {
 		glog.Infof("Drop update request: expect time range [%d, %d), now %d",
 			begin, end, ts)
+		return ErrServerTimeRange, "", time.Time{}
+	}
+
+	if bypassNoUpdate {
+		return 0, "", time.Time{}
+	}
+	windows := h.NoUpdateWindows(rankID, rank)
+	blocked, name, reopen := windows.Check(rank.Config().NoUpdatePeriod, now)
+	if blocked {
+		glog.Infof("Drop update request: no update window %q until %s, now %d",
+			name, reopen, ts)
+		return ErrNoUpdateTimePeriod, name, reopen
+	}
+	return 0, "", time.Time{}
+}
+
+func reopenTimeToProto(reopen time.Time) *int64 {
+	if reopen.IsZero() {
+		return proto.Int64(0)
+	}
+	return proto.Int64(reopen.Unix())
+}
+
+func (h *RankHandler) HandleUpdate(job Job, rank engine.RankEngine,
+	msg *serverproto.UpdateRequest, now time.Time) (res JobResult) {
+
+	errCode, window, reopen := h.CheckUpdate(job.RankID, rank,
+		msg.GetBypassNoUpdate(), msg.ServerTimeRange, now)
+	if errCode == ErrNoUpdateTimePeriod {
+		return JobResult{
+			FrameCtx:         job.Frame.Ctx,
+			FramePayloadType: uint32(serverproto.MessageType_TypeUpdateResponse),
+			ErrCode:          errCode,
+			Msg: &serverproto.UpdateResponse{
+				Rank:           proto.Uint32(job.RankID),
+				NoUpdateWindow: proto.String(window),
+				ReopenTime:     reopenTimeToProto(reopen),
+			},
+		}
+	}
+	if errCode != 0 {
 		return JobResult{
 			FrameCtx: job.Frame.Ctx,
-			ErrCode:  ErrServerTimeRange,
+			ErrCode:  errCode,
 		}
 	}
 
-	if !msg.GetBypassNoUpdate() &&
-		!rank.Config().NoUpdatePeriod.Empty() &&
-		rank.Config().NoUpdatePeriod.Contains(now) {
-		glog.Infof("Drop update request: no update time period, now %d",
-			ts)
+	u := RankUnitFromProto(msg.Data)
+	mode := engine.UpdateMode(msg.GetMode())
+	if mode == engine.UpdateModeAddDelta {
+		u.Key = uint64(msg.GetDelta())
+	}
+	err := h.AppendWAL(&WALRecord{
+		Type:   WALRecordUpdate,
+		RankID: job.RankID,
+		Mode:   mode,
+		Units:  []engine.RankUnit{u},
+	})
+	if err != nil {
 		return JobResult{
 			FrameCtx: job.Frame.Ctx,
-			ErrCode:  ErrNoUpdateTimePeriod,
+			ErrCode:  ErrServerFailure,
 		}
 	}
-
-	_, lastPos, lastData := rank.Update(RankUnitFromProto(msg.Data))
-	_, pos, _ := rank.Get(msg.Data.GetId())
+	_, lastPos, lastData, changed := rank.Update(u, mode)
+	exist, pos, _ := rank.Get(u.ID)
 	if !msg.GetReply() {
 		return res
 	}
+	// 与批量更新相同, 更新之后仍然在排行榜上并且保存的key发生了变化
+	changed = exist && changed
 	resp := &serverproto.UpdateResponse{
 		Rank:    proto.Uint32(job.RankID),
 		LastPos: proto.Uint32(lastPos),
 		Pos:     proto.Uint32(pos),
+		Changed: proto.Bool(changed),
 	}
 	if msg.GetLastData() {
 		resp.Data = RankUnitToProto(lastData)
@@ -280,9 +947,101 @@ func (h *RankHandler) HandleUpdate(job Job, rank engine.RankEngine,
 	}
 }
 
+func (h *RankHandler) HandleBatchUpdate(job Job,
+	msg *serverproto.BatchUpdateRequest, now time.Time) JobResult {
+	mode := engine.UpdateMode(msg.GetMode())
+	results := make([]*serverproto.BatchUpdateResult, len(msg.Items))
+	var changedNum uint32
+	// 同一个排行榜的连续数据合并成一次批量更新
+	for begin := 0; begin < len(msg.Items); {
+		rankID := msg.Items[begin].GetRank()
+		end := begin + 1
+		for end < len(msg.Items) && msg.Items[end].GetRank() == rankID {
+			end++
+		}
+		items := msg.Items[begin:end]
+
+		var errCode int32
+		var window string
+		var reopen time.Time
+		rank := h.FindRank(rankID)
+		if rank == nil {
+			errCode = ErrRankNotFound
+		} else {
+			errCode, window, reopen = h.CheckUpdate(rankID, rank,
+				msg.GetBypassNoUpdate(), msg.ServerTimeRange, now)
+		}
+		var changed []bool
+		if errCode == 0 {
+			units := make([]engine.RankUnit, len(items))
+			for i, item := range items {
+				units[i] = RankUnitFromProto(item.Data)
+				if mode == engine.UpdateModeAddDelta {
+					units[i].Key = uint64(item.GetDelta())
+				}
+			}
+			err := h.AppendWAL(&WALRecord{
+				Type:   WALRecordUpdateMany,
+				RankID: rankID,
+				Mode:   mode,
+				Units:  units,
+			})
+			if err != nil {
+				errCode = ErrServerFailure
+			} else {
+				changed = rank.UpdateMany(units, mode)
+			}
+		}
+		for i, item := range items {
+			result := &serverproto.BatchUpdateResult{
+				Rank:    proto.Uint32(rankID),
+				Id:      proto.Uint64(item.Data.GetId()),
+				ErrCode: proto.Int32(errCode),
+			}
+			if errCode == ErrNoUpdateTimePeriod {
+				result.NoUpdateWindow = proto.String(window)
+				result.ReopenTime = reopenTimeToProto(reopen)
+			}
+			if errCode == 0 {
+				exist, pos, _ := rank.Get(item.Data.GetId())
+				result.Pos = proto.Uint32(pos)
+				result.Changed = proto.Bool(exist && changed[i])
+				if exist && changed[i] {
+					changedNum++
+				}
+			}
+			results[begin+i] = result
+		}
+		begin = end
+	}
+
+	resp := &serverproto.BatchUpdateResponse{
+		Changed: proto.Uint32(changedNum),
+	}
+	if msg.GetItemResult() {
+		resp.Results = results
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeBatchUpdateResponse),
+		Msg:              resp,
+	}
+}
+
 func (h *RankHandler) HandleDelete(job Job, rank engine.RankEngine,
 	msg *serverproto.DeleteRequest) (res JobResult) {
 
+	err := h.AppendWAL(&WALRecord{
+		Type:   WALRecordDelete,
+		RankID: job.RankID,
+		ID:     msg.GetId(),
+	})
+	if err != nil {
+		return JobResult{
+			FrameCtx: job.Frame.Ctx,
+			ErrCode:  ErrServerFailure,
+		}
+	}
 	_, lastPos, lastData := rank.Delete(msg.GetId())
 	if !msg.GetReply() {
 		return res
@@ -0,0 +1,386 @@
+package server
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/frame"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+func newTestRankHandler(t *testing.T, start time.Time) *RankHandler {
+	primary, err := engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize: 10,
+		ClearPeriod: engine.TimePeriod{
+			Start:    start,
+			Interval: time.Hour * 24,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize:       10,
+		PrimaryRankID: 1,
+		SnapshotPeriod: engine.TimePeriod{
+			Start:    start,
+			Interval: time.Hour * 24,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+	if err := h.AddSnapshotRank(2, snapshot); err != nil {
+		t.Fatal(err)
+	}
+	// 已经执行过start时刻的清空以及快照
+	primary.SetLastClearTime(start)
+	snapshot.SetLastSnapshotTime(start)
+	return h
+}
+
+// 同步执行一个请求, 返回回包
+func runTestJob(h *RankHandler, rankID uint32, msg proto.Message,
+	now time.Time) JobResult {
+	resultChan := make(chan JobResult, 1)
+	h.HandleJob(Job{
+		Frame:      &frame.Frame{},
+		RankID:     rankID,
+		Msg:        msg,
+		Time:       now,
+		resultChan: resultChan,
+	})
+	select {
+	case jobResult := <-resultChan:
+		return jobResult
+	default:
+		return JobResult{}
+	}
+}
+
+func updateRequest(id, key uint64) *serverproto.UpdateRequest {
+	return &serverproto.UpdateRequest{
+		Data: &serverproto.RankUnit{
+			Id:  proto.Uint64(id),
+			Key: proto.Uint64(key),
+		},
+		Reply: proto.Bool(true),
+	}
+}
+
+func TestRankHandlerSnapshotAtBoundary(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	boundary := start.Add(time.Hour * 24)
+	h := newTestRankHandler(t, start)
+
+	runTestJob(h, 1, updateRequest(1024, 10), boundary.Add(-time.Millisecond))
+	runTestJob(h, 1, updateRequest(1025, 12), boundary.Add(time.Millisecond))
+
+	snapshot := h.FindRank(2)
+	if snapshot.Size() != 1 {
+		t.Fatalf("Expect snapshot size 1, got: %d", snapshot.Size())
+	}
+	if exist, _, _ := snapshot.Get(1025); exist {
+		t.Error("Expect update after boundary not in snapshot")
+	}
+	if !snapshot.LastSnapshotTime().Equal(boundary) {
+		t.Errorf("Expect snapshot time %s, got: %s", boundary,
+			snapshot.LastSnapshotTime())
+	}
+	// 快照之后清空, 只剩下到期之后的更新
+	primary := h.FindRank(1)
+	if primary.Size() != 1 {
+		t.Fatalf("Expect primary size 1, got: %d", primary.Size())
+	}
+	if exist, _, _ := primary.Get(1025); !exist {
+		t.Error("Expect update after boundary in primary rank")
+	}
+}
+
+func TestRankHandlerSnapshotOnRead(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	boundary := start.Add(time.Hour * 24)
+	h := newTestRankHandler(t, start)
+
+	runTestJob(h, 1, updateRequest(1024, 10), boundary.Add(-time.Millisecond))
+	// 读取快照排行榜同样先执行主排行榜的清空, 之后的更新不会进入快照
+	jobResult := runTestJob(h, 2, &serverproto.GetRangeRequest{
+		Start: proto.Uint32(0),
+		Num:   proto.Uint32(10),
+	}, boundary)
+	resp := jobResult.Msg.(*serverproto.GetRangeResponse)
+	if resp.GetTotal() != 1 {
+		t.Fatalf("Expect total 1, got: %d", resp.GetTotal())
+	}
+	if h.FindRank(1).Size() != 0 {
+		t.Errorf("Expect primary rank cleared, got size: %d", h.FindRank(1).Size())
+	}
+
+	runTestJob(h, 1, updateRequest(1025, 12), boundary.Add(time.Millisecond))
+	h.CronCheckAllRanks(boundary.Add(time.Second))
+	if exist, _, _ := h.FindRank(2).Get(1025); exist {
+		t.Error("Expect update after boundary not in snapshot")
+	}
+}
+
+func TestRankHandlerHistory(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	day := time.Hour * 24
+	primary, _ := engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize: 10,
+		ClearPeriod: engine.TimePeriod{
+			Start:    start,
+			Interval: day,
+		},
+		HistorySize: 2,
+	})
+	primary.SetLastClearTime(start)
+	h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+
+	// 每天更新一条数据, 第二天开始时清空
+	for i := 0; i < 3; i++ {
+		now := start.Add(day*time.Duration(i) + time.Hour)
+		runTestJob(h, 1, updateRequest(uint64(1024+i), 10), now)
+	}
+	now := start.Add(day*3 + time.Hour)
+
+	getHistory := func(ts int64, generation uint32) JobResult {
+		return runTestJob(h, 1, &serverproto.GetHistoryRangeRequest{
+			Rank:       proto.Uint32(1),
+			Time:       proto.Int64(ts),
+			Generation: proto.Uint32(generation),
+			Num:        proto.Uint32(10),
+		}, now)
+	}
+	jobResult := getHistory(0, 0)
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Get history failed: %d", jobResult.ErrCode)
+	}
+	resp := jobResult.Msg.(*serverproto.GetHistoryRangeResponse)
+	if resp.GetTime() != start.Add(day*3).Unix() {
+		t.Errorf("Expect time %d, got: %d", start.Add(day*3).Unix(), resp.GetTime())
+	}
+	if len(resp.Data) != 1 || resp.Data[0].GetId() != 1026 {
+		t.Errorf("Expect history [1026], got: %v", resp.Data)
+	}
+	if len(resp.HistoryTime) != 2 {
+		t.Errorf("Expect 2 history, got: %v", resp.HistoryTime)
+	}
+
+	jobResult = getHistory(start.Add(day*2).Unix(), 0)
+	resp = jobResult.Msg.(*serverproto.GetHistoryRangeResponse)
+	if resp.GetGeneration() != 1 || len(resp.Data) != 1 ||
+		resp.Data[0].GetId() != 1025 {
+		t.Errorf("Expect generation 1 history [1025], got: %d %v",
+			resp.GetGeneration(), resp.Data)
+	}
+
+	// 只保留最近的两个历史排行榜
+	if jobResult = getHistory(0, 2); jobResult.ErrCode != ErrHistoryNotFound {
+		t.Errorf("Expect ErrHistoryNotFound, got: %d", jobResult.ErrCode)
+	}
+}
+
+func TestRankHandlerInitPeriodTimes(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	everyMinute, err := engine.ParseCronSchedule("* * * * *", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary, _ := engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize:     10,
+		ClearPeriod: everyMinute,
+	})
+	h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+	dir := t.TempDir()
+	if err := h.Recover(dir, AppConfig{WALSyncPolicy: WALSyncAlways}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新的排行榜从第一次执行时开始计算周期, 不从零值时间开始补
+	begin := time.Now()
+	runTestJob(h, 1, updateRequest(1024, 10), start)
+	if elapsed := time.Since(begin); elapsed > time.Second {
+		t.Errorf("Expect fast first advance, took: %s", elapsed)
+	}
+	if primary.Size() != 1 || !primary.LastClearTime().Equal(start) {
+		t.Fatalf("Expect size 1 and last clear time %s, got: %d %s", start,
+			primary.Size(), primary.LastClearTime())
+	}
+	runTestJob(h, 1, updateRequest(1025, 10), start.Add(time.Minute))
+	if primary.Size() != 1 {
+		t.Errorf("Expect clear after one minute, got size: %d", primary.Size())
+	}
+	h.CloseWAL()
+
+	// 恢复之后得到相同的时间
+	recovered, _ := engine.NewRankEngine(primary.Config())
+	h = NewRankHandler(1, recovered, engine.NewFakeClock(start))
+	if err := h.Recover(dir, AppConfig{WALSyncPolicy: WALSyncAlways}); err != nil {
+		t.Fatal(err)
+	}
+	if !recovered.LastClearTime().Equal(start.Add(time.Minute)) {
+		t.Errorf("Expect last clear time %s, got: %s", start.Add(time.Minute),
+			recovered.LastClearTime())
+	}
+	h.CloseWAL()
+}
+
+func TestRankHandlerMultiGet(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	primary, _ := engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize:     10,
+		RankingMode: engine.RankingModeCompetition,
+	})
+	h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+	runTestJob(h, 1, updateRequest(1024, 12), start)
+	runTestJob(h, 1, updateRequest(1025, 12), start)
+	runTestJob(h, 1, updateRequest(1026, 10), start)
+
+	// 结果与请求的ID一一对应, 重复的ID重复返回
+	jobResult := runTestJob(h, 1, &serverproto.MultiGetRequest{
+		Id: []uint64{1026, 2048, 1025, 1026},
+	}, start)
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("MultiGet failed: %d", jobResult.ErrCode)
+	}
+	results := jobResult.Msg.(*serverproto.MultiGetResponse).GetResults()
+	expect := []struct {
+		id          uint64
+		exist       bool
+		pos         uint32
+		key         uint64
+		displayRank uint32
+	}{
+		{1026, true, 2, 10, 3},
+		{2048, false, 0, 0, 0},
+		{1025, true, 1, 12, 1},
+		{1026, true, 2, 10, 3},
+	}
+	if len(results) != len(expect) {
+		t.Fatalf("Expect %d results, got: %d", len(expect), len(results))
+	}
+	for i, r := range results {
+		e := expect[i]
+		if r.GetId() != e.id || r.GetExist() != e.exist || r.GetPos() != e.pos ||
+			r.GetData().GetKey() != e.key || r.GetDisplayRank() != e.displayRank {
+			t.Errorf("Expect result %d %v, got: %v", i, e, r)
+		}
+	}
+}
+
+func TestRankHandlerGetAround(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	h := newTestRankHandler(t, start)
+	runTestJob(h, 1, updateRequest(1024, 12), start)
+	runTestJob(h, 1, updateRequest(1025, 10), start)
+
+	// 排在第一位和未上榜的pos都是0, 通过exist区分
+	for _, c := range []struct {
+		id    uint64
+		exist bool
+		num   int
+	}{
+		{1024, true, 2},
+		{2048, false, 0},
+	} {
+		jobResult := runTestJob(h, 1, &serverproto.GetAroundRequest{
+			Id:     proto.Uint64(c.id),
+			Before: proto.Uint32(1),
+			After:  proto.Uint32(1),
+		}, start)
+		resp := jobResult.Msg.(*serverproto.GetAroundResponse)
+		if resp.GetExist() != c.exist || resp.GetPos() != 0 ||
+			len(resp.Data) != c.num {
+			t.Errorf("%d: Expect exist %v pos 0 and %d units, got: %v", c.id,
+				c.exist, c.num, resp)
+		}
+	}
+}
+
+// 数据量超过MAX_QUERY_NUM的排行榜, ID和key都占用最大的长度
+// 每天清空一次, 保留一个历史排行榜
+func newLargeTestRankHandler(t *testing.T, start time.Time) *RankHandler {
+	primary, err := engine.NewRankEngine(engine.RankEngineConfig{
+		Kind: engine.EngineKindSkipList,
+		ClearPeriod: engine.TimePeriod{
+			Start:    start,
+			Interval: time.Hour * 24,
+		},
+		HistorySize: 1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.SetLastClearTime(start)
+	h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+	for i := uint64(0); i < MAX_QUERY_NUM*2; i++ {
+		runTestJob(h, 1, updateRequest(math.MaxUint64-i, math.MaxUint64-i), start)
+	}
+	return h
+}
+
+// 回包的数据量不超过MAX_QUERY_NUM, 长度不超过frame.MAX_PAYLOAD_SIZE
+func checkQueryResponse(t *testing.T, jobResult JobResult, num int) {
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Expect success, got: %d", jobResult.ErrCode)
+	}
+	if num > MAX_QUERY_NUM {
+		t.Errorf("Expect at most %d units, got: %d", MAX_QUERY_NUM, num)
+	}
+	if size := proto.Size(jobResult.Msg); size > frame.MAX_PAYLOAD_SIZE {
+		t.Errorf("Expect payload size at most %d, got: %d",
+			frame.MAX_PAYLOAD_SIZE, size)
+	}
+}
+
+func TestRankHandlerGetByKeyRangeLimit(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	h := newLargeTestRankHandler(t, start)
+	for _, limit := range []uint32{0, math.MaxUint32} {
+		jobResult := runTestJob(h, 1, &serverproto.GetByKeyRangeRequest{
+			MinKey: proto.Uint64(0),
+			MaxKey: proto.Uint64(math.MaxUint64),
+			Limit:  proto.Uint32(limit),
+		}, start)
+		resp := jobResult.Msg.(*serverproto.GetByKeyRangeResponse)
+		checkQueryResponse(t, jobResult, len(resp.Data))
+		if len(resp.Data) != MAX_QUERY_NUM {
+			t.Errorf("limit %d: Expect %d units, got: %d", limit, MAX_QUERY_NUM,
+				len(resp.Data))
+		}
+	}
+}
+
+func TestRankHandlerGetAroundLimit(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	h := newLargeTestRankHandler(t, start)
+	jobResult := runTestJob(h, 1, &serverproto.GetAroundRequest{
+		Id:     proto.Uint64(math.MaxUint64 - MAX_QUERY_NUM),
+		Before: proto.Uint32(math.MaxUint32),
+		After:  proto.Uint32(math.MaxUint32),
+	}, start)
+	resp := jobResult.Msg.(*serverproto.GetAroundResponse)
+	checkQueryResponse(t, jobResult, len(resp.Data))
+	if expect := (MAX_QUERY_NUM-1)/2*2 + 1; len(resp.Data) != expect {
+		t.Errorf("Expect %d units, got: %d", expect, len(resp.Data))
+	}
+}
+
+func TestRankHandlerGetHistoryRangeLimit(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	h := newLargeTestRankHandler(t, start)
+	jobResult := runTestJob(h, 1, &serverproto.GetHistoryRangeRequest{
+		Rank: proto.Uint32(1),
+		Num:  proto.Uint32(math.MaxUint32),
+	}, start.Add(time.Hour*24))
+	resp := jobResult.Msg.(*serverproto.GetHistoryRangeResponse)
+	checkQueryResponse(t, jobResult, len(resp.Data))
+	if len(resp.Data) != MAX_QUERY_NUM {
+		t.Errorf("Expect %d units, got: %d", MAX_QUERY_NUM, len(resp.Data))
+	}
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/frame"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+// 通过jobQueue在分发请求的goroutine中重新加载配置文件并等待完成, 用于SIGHUP
+func (d *Dispatcher) RequestReload() JobResult {
+	resultChan := make(chan JobResult, 1)
+	d.jobQueue <- Job{
+		Frame:      &frame.Frame{},
+		Msg:        &serverproto.ReloadConfigRequest{},
+		resultChan: resultChan,
+		privileged: true,
+	}
+	return <-resultChan
+}
+
+// 按主榜在前, ID从小到大的顺序返回
+func sortedRankIDs(configs map[uint32]engine.RankEngineConfig) []uint32 {
+	ids := make([]uint32, 0, len(configs))
+	for rankID := range configs {
+		ids = append(ids, rankID)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		pi := configs[ids[i]].PrimaryRankID != 0
+		pj := configs[ids[j]].PrimaryRankID != 0
+		if pi != pj {
+			return pj
+		}
+		return ids[i] < ids[j]
+	})
+	return ids
+}
+
+// 对比配置文件和当前的排行榜, 添加新的排行榜, 删除配置文件中去掉的排行榜
+// 并修改已有排行榜的配置, 运行时创建的排行榜不受影响
+// 无法应用的修改记录在rejected中, 对应的排行榜保持不变
+func (d *Dispatcher) ReloadConfig(now time.Time) (
+	*serverproto.ReloadConfigResponse, int32) {
+	resp := &serverproto.ReloadConfigResponse{}
+	if d.config.ConfigFile == "" {
+		glog.Errorf("Reload config: no config file")
+		resp.Error = proto.String("No config file")
+		return resp, ErrInvalidArgument
+	}
+	var configs map[uint32]engine.RankEngineConfig
+	file, err := LoadConfigFile(d.config.ConfigFile)
+	if err == nil {
+		configs, err = file.RankEngineConfigs()
+	}
+	if err != nil {
+		glog.Errorf("Reload config: %v", err)
+		resp.Error = proto.String(err.Error())
+		return resp, ErrInvalidArgument
+	}
+	reject := func(format string, args ...interface{}) {
+		reason := fmt.Sprintf(format, args...)
+		glog.Warningf("Reload config: %s", reason)
+		resp.Rejected = append(resp.Rejected, reason)
+	}
+	if file.ClientAddress != "" &&
+		file.ClientAddress != d.config.AcceptClientAddress {
+		reject("client_address: restart to change %s to %s",
+			d.config.AcceptClientAddress, file.ClientAddress)
+	}
+	if file.ServerAddress != "" &&
+		file.ServerAddress != d.config.AcceptServerAddress {
+		reject("server_address: restart to change %s to %s",
+			d.config.AcceptServerAddress, file.ServerAddress)
+	}
+	if file.AdminAddress != d.config.AcceptAdminAddress {
+		reject("admin_address: restart to change %s to %s",
+			d.config.AcceptAdminAddress, file.AdminAddress)
+	}
+
+	// 先删除快照榜, 删除主榜时剩下的只有运行时创建的快照榜
+	ids := sortedRankIDs(d.rankConfigs)
+	for i := len(ids) - 1; i >= 0; i-- {
+		rankID := ids[i]
+		if _, exist := configs[rankID]; exist {
+			continue
+		}
+		if _, dynamic := d.dynamicRanks[rankID]; dynamic {
+			continue
+		}
+		if handler := d.primaryHandler(rankID); handler != nil {
+			var snapshots []uint32
+			for id, h := range d.mappedHandlers {
+				if h == handler && id != rankID {
+					snapshots = append(snapshots, id)
+				}
+			}
+			if len(snapshots) != 0 {
+				sort.Slice(snapshots, func(i, j int) bool {
+					return snapshots[i] < snapshots[j]
+				})
+				reject("rank %d: drop snapshot ranks %v first", rankID, snapshots)
+				continue
+			}
+		}
+		d.removeRanks([]uint32{rankID})
+		resp.Dropped = append(resp.Dropped, rankID)
+	}
+
+	rejected := make(map[uint32]bool)
+	for _, rankID := range sortedRankIDs(configs) {
+		config := configs[rankID]
+		if rejected[config.PrimaryRankID] {
+			rejected[rankID] = true
+			reject("rank %d: primary rank %d rejected", rankID,
+				config.PrimaryRankID)
+			continue
+		}
+		old, exist := d.rankConfigs[rankID]
+		if !exist {
+			if err := d.startRank(rankID, config, now); err != nil {
+				rejected[rankID] = true
+				reject("rank %d: %v", rankID, err)
+				continue
+			}
+			resp.Added = append(resp.Added, rankID)
+			continue
+		}
+		if _, dynamic := d.dynamicRanks[rankID]; dynamic {
+			rejected[rankID] = true
+			reject("rank %d: created at runtime, drop it first", rankID)
+			continue
+		}
+		if reflect.DeepEqual(old, config) {
+			continue
+		}
+		if err := engine.ConfigChangeError(old, config); err != nil {
+			rejected[rankID] = true
+			reject("rank %d: %v", rankID, err)
+			continue
+		}
+		d.closeReplicas()
+		err := d.runInHandler(d.mappedHandlers[rankID],
+			func(h *RankHandler) error {
+				return h.SetRankConfig(rankID, config, now)
+			})
+		if err != nil {
+			rejected[rankID] = true
+			reject("rank %d: %v", rankID, err)
+			continue
+		}
+		d.rankConfigs[rankID] = config
+		resp.Updated = append(resp.Updated, rankID)
+	}
+	sort.Slice(resp.Dropped, func(i, j int) bool {
+		return resp.Dropped[i] < resp.Dropped[j]
+	})
+	glog.Infof("Reload config: added %v, dropped %v, updated %v, rejected %d",
+		resp.Added, resp.Dropped, resp.Updated, len(resp.Rejected))
+	return resp, 0
+}
+
+// 修改排行榜的配置, 修改之前先按原有的周期执行到期的清空以及快照
+// 新的周期从上一次执行的时间开始计算, 与使用新配置重启一致
+// 修改之后立即转储, 之前的WAL记录不会再使用新的配置重放
+// 转储失败时恢复原有的配置以及数据
+func (h *RankHandler) SetRankConfig(rankID uint32,
+	config engine.RankEngineConfig, now time.Time) error {
+	rank := h.FindRank(rankID)
+	if rank == nil {
+		return fmt.Errorf("Rank %d not found", rankID)
+	}
+	if err := h.AdvanceTo(now); err != nil {
+		return err
+	}
+	// 缩小MaxSize会淘汰数据, 先保存原有的数据
+	old := rank.Config()
+	backup, err := rank.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := rank.SetConfig(config); err != nil {
+		return err
+	}
+	if err := h.CheckpointNow(); err != nil {
+		glog.Errorf("Set rank %d config: checkpoint: %v", rankID, err)
+		if err := rank.SetConfig(old); err != nil {
+			glog.Fatalf("Restore rank %d config: %v", rankID, err)
+		}
+		if err := rank.UnmarshalBinary(backup); err != nil {
+			glog.Fatalf("Restore rank %d: %v", rankID, err)
+		}
+		return err
+	}
+	glog.Infof("Set rank %d config, max size %d", rankID, config.MaxSize)
+	return nil
+}
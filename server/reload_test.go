@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+func TestDispatcherReloadConfig(t *testing.T) {
+	start := time.Date(2017, 3, 23, 1, 0, 0, 0, time.UTC)
+	clock := engine.NewFakeClock(start)
+	name := filepath.Join(t.TempDir(), "sirius.json")
+	writeConfig := func(ranks string) {
+		if err := os.WriteFile(name, []byte(`{"ranks": [`+ranks+`]}`),
+			0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig(`
+		{"id": 1, "max_size": 10},
+		{"id": 2, "max_size": 10},
+		{"id": 3, "max_size": 10, "primary_rank_id": 1,
+		 "snapshot_period": {"cron": "@daily"}}`)
+	file, err := LoadConfigFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs, _ := file.RankEngineConfigs()
+	ranks := make(map[uint32]engine.RankEngine)
+	for rankID, config := range configs {
+		ranks[rankID], _ = engine.NewRankEngine(config)
+	}
+	d, err := NewDispatcher(ranks, AppConfig{ConfigFile: name}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Recover()
+	d.Start()
+	defer d.Stop()
+	for i := uint64(0); i < 5; i++ {
+		dispatchTestJob(t, d, 1, updateRequest(1024+i, 10+i))
+	}
+	dispatchAdminTestJob(t, d, 0, &serverproto.CreateRankRequest{
+		Rank:   proto.Uint32(5),
+		Config: &serverproto.RankConfig{MaxSize: proto.Uint32(10)},
+	})
+
+	writeConfig(`
+		{"id": 1, "max_size": 2},
+		{"id": 2, "max_size": 10, "sort_order": "asc"},
+		{"id": 4, "max_size": 10, "primary_rank_id": 1,
+		 "snapshot_period": {"cron": "@daily"}},
+		{"id": 5, "max_size": 10}`)
+	jobResult := d.RequestReload()
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Reload failed: %d", jobResult.ErrCode)
+	}
+	resp := jobResult.Msg.(*serverproto.ReloadConfigResponse)
+	if fmt.Sprint(resp.Added, resp.Dropped, resp.Updated) != "[4] [3] [1]" {
+		t.Errorf("Expect added [4], dropped [3], updated [1], got: %v %v %v",
+			resp.Added, resp.Dropped, resp.Updated)
+	}
+	if len(resp.Rejected) != 2 {
+		t.Errorf("Expect rank 2 and 5 rejected, got: %q", resp.Rejected)
+	}
+	if ids := getRangeIDs(t, d, 1); fmt.Sprint(ids) != "[1028 1027]" {
+		t.Errorf("Expect rank 1 [1028 1027], got: %v", ids)
+	}
+	if ids := listRankIDs(t, d); fmt.Sprint(ids) != "[1 2 4 5]" {
+		t.Errorf("Expect ranks [1 2 4 5], got: %v", ids)
+	}
+	if d.rankConfigs[2].SortOrder != engine.SortOrderDescending {
+		t.Error("Expect rank 2 unchanged")
+	}
+
+	// 配置文件有错误时不做任何修改
+	writeConfig(`{"id": 1}`)
+	jobResult = d.RequestReload()
+	resp = jobResult.Msg.(*serverproto.ReloadConfigResponse)
+	if jobResult.ErrCode != ErrInvalidArgument || resp.GetError() == "" {
+		t.Errorf("Expect reload error, got: %d %q", jobResult.ErrCode,
+			resp.GetError())
+	}
+	if ids := listRankIDs(t, d); fmt.Sprint(ids) != "[1 2 4 5]" {
+		t.Errorf("Expect ranks [1 2 4 5], got: %v", ids)
+	}
+}
+
+func TestRankHandlerSetRankConfigRecover(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	newHandler := func(maxSize uint32) *RankHandler {
+		primary, _ := engine.NewRankEngine(engine.RankEngineConfig{
+			MaxSize: maxSize,
+		})
+		h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+		if err := h.Recover(dir, AppConfig{WALSyncPolicy: WALSyncAlways}); err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	h := newHandler(2)
+	for _, id := range []uint64{1024, 1025, 1026} {
+		runTestJob(h, 1, updateRequest(id, id), start)
+	}
+	config := h.FindRank(1).Config()
+	config.MaxSize = 3
+	if err := h.SetRankConfig(1, config, start); err != nil {
+		t.Fatal(err)
+	}
+	runTestJob(h, 1, updateRequest(1027, 1), start)
+
+	// 没有关闭WAL, 模拟崩溃之后使用新的配置重启, 已经淘汰的数据不会重新出现
+	h = newHandler(3)
+	var ids []uint64
+	for _, u := range h.FindRank(1).GetRange(0, 10) {
+		ids = append(ids, u.ID)
+	}
+	if fmt.Sprint(ids) != "[1026 1025 1027]" {
+		t.Errorf("Expect [1026 1025 1027] after recover, got: %v", ids)
+	}
+	h.CloseWAL()
+}
+
+// 转储失败时拒绝修改, 排行榜的配置以及数据保持不变
+func TestRankHandlerSetRankConfigCheckpointFail(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	primary, _ := engine.NewRankEngine(engine.RankEngineConfig{MaxSize: 3})
+	h := NewRankHandler(1, primary, engine.NewFakeClock(start))
+	if err := h.Recover(dir, AppConfig{WALSyncPolicy: WALSyncAlways}); err != nil {
+		t.Fatal(err)
+	}
+	defer h.CloseWAL()
+	for _, id := range []uint64{1024, 1025, 1026} {
+		runTestJob(h, 1, updateRequest(id, id), start)
+	}
+
+	// 删除数据目录使转储失败
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	config := primary.Config()
+	config.MaxSize = 1
+	if err := h.SetRankConfig(1, config, start); err == nil {
+		t.Fatal("Expect checkpoint error")
+	}
+	if primary.Config().MaxSize != 3 {
+		t.Errorf("Expect max size 3, got: %d", primary.Config().MaxSize)
+	}
+	var ids []uint64
+	for _, u := range primary.GetRange(0, 10) {
+		ids = append(ids, u.ID)
+	}
+	if fmt.Sprint(ids) != "[1026 1025 1024]" {
+		t.Errorf("Expect [1026 1025 1024], got: %v", ids)
+	}
+}
@@ -0,0 +1,515 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/frame"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+const (
+	// 每个副本等待发送的数据数量, 超过时断开副本, 由副本重新同步
+	MAX_BUFFERED_REPLICA_EVENT = 4096
+	// 每个ReplicaData包中数据的最大长度
+	REPLICA_CHUNK_SIZE     = 32 << 10
+	REPLICA_WRITE_TIMEOUT  = time.Second * 10
+	REPLICA_DIAL_TIMEOUT   = time.Second * 3
+	REPLICA_RETRY_INTERVAL = time.Second
+)
+
+// 推送给副本的数据, subscribe, checkpoint和record只有一个不为空
+type replicaEvent struct {
+	subscribe *serverproto.ReplicaSubscribeResponse
+	// RankHandler的主榜ID
+	rankID     uint32
+	checkpoint *pendingCheckpoint
+	// WAL记录的payload
+	record []byte
+}
+
+// 主节点上的一个副本, 由Dispatcher以及订阅的RankHandler写入数据
+type replicaSubscriber struct {
+	events    chan replicaEvent
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newReplicaSubscriber() *replicaSubscriber {
+	return &replicaSubscriber{
+		events: make(chan replicaEvent, MAX_BUFFERED_REPLICA_EVENT),
+		closed: make(chan struct{}),
+	}
+}
+
+// 关闭之后不再接收数据, 副本重新连接之后重新同步
+func (s *replicaSubscriber) Close() {
+	s.closeOnce.Do(func() { close(s.closed) })
+}
+
+func (s *replicaSubscriber) Closed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// 不阻塞RankHandler, 副本跟不上时直接关闭
+func (s *replicaSubscriber) push(e replicaEvent) {
+	if s.Closed() {
+		return
+	}
+	select {
+	case s.events <- e:
+	default:
+		glog.Warningf("Replica too slow, %d events pending", len(s.events))
+		s.Close()
+	}
+}
+
+// 只转换排行榜结构相关的配置, 用于副本创建相同的排行榜
+// 副本不执行清空以及快照, 也没有结算
+func RankConfigToProto(config engine.RankEngineConfig) *serverproto.RankConfig {
+	return &serverproto.RankConfig{
+		Kind:             proto.String(config.Kind),
+		MaxSize:          proto.Uint32(config.MaxSize),
+		RedundantNodeNum: proto.Uint32(config.RedundantNodeNum),
+		SortOrder:        proto.Uint32(uint32(config.SortOrder)),
+		TieBreak:         proto.Uint32(uint32(config.TieBreak)),
+		RankingMode:      proto.Uint32(uint32(config.RankingMode)),
+		PrimaryRank:      proto.Uint32(config.PrimaryRankID),
+		HistorySize:      proto.Uint32(config.HistorySize),
+	}
+}
+
+// 发送所有排行榜当前的转储, 之后的修改记录都会发送给副本
+func (h *RankHandler) AddReplica(sub *replicaSubscriber) {
+	pending := h.snapshotState(0)
+	// 副本的配置中没有禁止更新时间段, 转储中包含所有排行榜当前生效的时间段
+	for rankID, rank := range pending.ranks {
+		pending.noUpdateWindows[rankID] = h.NoUpdateWindows(rankID, rank)
+	}
+	sub.push(replicaEvent{rankID: h.primaryRankID, checkpoint: pending})
+	h.replicas = append(h.replicas, sub)
+	glog.Infof("RankHandler %d add replica, %d replicas", h.primaryRankID,
+		len(h.replicas))
+}
+
+// 把修改记录发送给所有副本, 同时去掉已经关闭的副本
+func (h *RankHandler) PublishRecord(r *WALRecord) {
+	if len(h.replicas) == 0 {
+		return
+	}
+	data, err := r.MarshalPayload()
+	if err != nil {
+		// 副本无法得到这条记录, 断开之后重新同步
+		glog.Errorf("RankHandler %d marshal replica record: %v",
+			h.primaryRankID, err)
+		for _, sub := range h.replicas {
+			sub.Close()
+		}
+		h.replicas = nil
+		return
+	}
+	replicas := h.replicas[:0]
+	for _, sub := range h.replicas {
+		sub.push(replicaEvent{rankID: h.primaryRankID, record: data})
+		if !sub.Closed() {
+			replicas = append(replicas, sub)
+		}
+	}
+	h.replicas = replicas
+}
+
+// 在Dispatcher的goroutine中执行f并等待完成, Dispatcher已经退出时返回false
+func (d *Dispatcher) runInDispatcher(f func(d *Dispatcher)) bool {
+	done := make(chan struct{})
+	job := Job{admin: func(d *Dispatcher) {
+		f(d)
+		close(done)
+	}}
+	select {
+	case d.jobQueue <- job:
+	case <-d.doneChan:
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-d.doneChan:
+		return false
+	}
+}
+
+// 添加副本, 先发送所有排行榜的配置, 再由每个RankHandler发送转储以及之后的修改
+func (d *Dispatcher) SubscribeReplica() (*replicaSubscriber, bool) {
+	sub := newReplicaSubscriber()
+	ok := d.runInDispatcher(func(d *Dispatcher) {
+		resp := &serverproto.ReplicaSubscribeResponse{
+			ClientAddress: proto.String(d.config.AcceptClientAddress),
+		}
+		for _, rankID := range sortedRankIDs(d.rankConfigs) {
+			resp.Ranks = append(resp.Ranks, &serverproto.CreateRankRequest{
+				Rank:   proto.Uint32(rankID),
+				Config: RankConfigToProto(d.rankConfigs[rankID]),
+			})
+		}
+		sub.push(replicaEvent{subscribe: resp})
+		for _, handler := range d.rankHandlers {
+			handler.jobQueue <- Job{run: func(h *RankHandler) {
+				h.AddReplica(sub)
+			}}
+		}
+		replicas := d.replicas[:0]
+		for _, s := range d.replicas {
+			if !s.Closed() {
+				replicas = append(replicas, s)
+			}
+		}
+		d.replicas = append(replicas, sub)
+	})
+	return sub, ok
+}
+
+// 排行榜发生变化时断开所有副本, 副本重新连接之后重新同步
+func (d *Dispatcher) closeReplicas() {
+	if len(d.replicas) != 0 {
+		glog.Infof("Ranks changed, close %d replicas", len(d.replicas))
+	}
+	for _, sub := range d.replicas {
+		sub.Close()
+	}
+	d.replicas = nil
+}
+
+// 副本拒绝所有修改请求并返回主节点的地址, 第二个返回值表示是否拒绝
+func (d *Dispatcher) RedirectWrite(job Job) (JobResult, bool) {
+	if d.config.ReplicaOf == "" {
+		return JobResult{}, false
+	}
+	switch job.Msg.(type) {
+	case *serverproto.UpdateRequest, *serverproto.DeleteRequest,
+		*serverproto.BatchUpdateRequest, *serverproto.SetNoUpdateWindowsRequest,
+		*serverproto.CreateRankRequest, *serverproto.DropRankRequest,
+		*serverproto.ReloadConfigRequest:
+	default:
+		return JobResult{}, false
+	}
+	return JobResult{
+		FrameCtx:         job.Frame.Ctx,
+		FramePayloadType: uint32(serverproto.MessageType_TypeRedirectResponse),
+		ErrCode:          ErrRedirect,
+		Msg: &serverproto.RedirectResponse{
+			Address: proto.String(d.primaryClientAddress),
+		},
+	}, true
+}
+
+// 使用主节点的排行榜替换当前所有的排行榜, 之后由主节点发送每个排行榜的数据
+func (d *Dispatcher) resetReplica(resp *serverproto.ReplicaSubscribeResponse) error {
+	d.primaryClientAddress = resp.GetClientAddress()
+	// 快照榜排在主榜之后, 会先被删除
+	d.removeRanks(sortedRankIDs(d.rankConfigs))
+	now := d.clock.Now()
+	for _, req := range resp.Ranks {
+		rankID := req.GetRank()
+		config, err := rankConfigFromProto(req.Config)
+		if err != nil {
+			return fmt.Errorf("Rank %d: %v", rankID, err)
+		}
+		if _, exist := d.mappedHandlers[rankID]; exist {
+			return fmt.Errorf("Rank %d already exist", rankID)
+		}
+		if config.PrimaryRankID != 0 &&
+			d.primaryHandler(config.PrimaryRankID) == nil {
+			return fmt.Errorf("Primary rank %d of rank %d not found",
+				config.PrimaryRankID, rankID)
+		}
+		if err := d.startRank(rankID, config, now); err != nil {
+			return fmt.Errorf("Rank %d: %v", rankID, err)
+		}
+	}
+	glog.Infof("Replica reset %d ranks from primary %s", len(resp.Ranks),
+		d.primaryClientAddress)
+	return nil
+}
+
+// 解析主节点发送的转储或者修改记录, 交给对应的RankHandler按顺序执行
+func (d *Dispatcher) applyReplicaData(rankID uint32, checkpoint bool,
+	data []byte) error {
+	var run func(h *RankHandler)
+	if checkpoint {
+		c := &Checkpoint{}
+		if err := c.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("Unmarshal checkpoint of rank %d: %v", rankID, err)
+		}
+		run = func(h *RankHandler) {
+			if err := h.LoadCheckpoint(c); err != nil {
+				glog.Errorf("RankHandler %d load replica checkpoint: %v",
+					h.primaryRankID, err)
+			}
+		}
+	} else {
+		r := &WALRecord{}
+		if err := r.UnmarshalBinary(data); err != nil {
+			return fmt.Errorf("Unmarshal record of rank %d: %v", rankID, err)
+		}
+		run = func(h *RankHandler) {
+			if err := h.ApplyWALRecord(r); err != nil {
+				glog.Errorf("RankHandler %d apply replica record: %v",
+					h.primaryRankID, err)
+			}
+		}
+	}
+	d.runInDispatcher(func(d *Dispatcher) {
+		handler := d.primaryHandler(rankID)
+		if handler == nil {
+			glog.Warningf("Ignore replica data of unknown rank %d", rankID)
+			return
+		}
+		handler.jobQueue <- Job{run: run}
+	})
+	return nil
+}
+
+func writeFrame(w io.Writer, msgType serverproto.MessageType,
+	msg proto.Message) error {
+	payload, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	f := frame.New(uint32(msgType), payload)
+	if f == nil {
+		return fmt.Errorf("Max payload size %d, got: %d", frame.MAX_PAYLOAD_SIZE,
+			len(payload))
+	}
+	_, err = f.WriteTo(w)
+	return err
+}
+
+// 按排行榜拆分订阅的回包, 每个包的长度不超过REPLICA_CHUNK_SIZE
+func writeReplicaSubscribe(w io.Writer,
+	resp *serverproto.ReplicaSubscribeResponse) error {
+	ranks := resp.Ranks
+	for {
+		chunk := &serverproto.ReplicaSubscribeResponse{
+			ClientAddress: resp.ClientAddress,
+			Last:          proto.Bool(false),
+		}
+		size := proto.Size(chunk)
+		for len(ranks) != 0 {
+			// 每个排行榜额外需要tag以及长度前缀
+			n := proto.Size(ranks[0]) + 8
+			if len(chunk.Ranks) != 0 && size+n > REPLICA_CHUNK_SIZE {
+				break
+			}
+			chunk.Ranks = append(chunk.Ranks, ranks[0])
+			size += n
+			ranks = ranks[1:]
+		}
+		chunk.Last = proto.Bool(len(ranks) == 0)
+		err := writeFrame(w, serverproto.MessageType_TypeReplicaSubscribeResponse,
+			chunk)
+		if err != nil {
+			return err
+		}
+		if len(ranks) == 0 {
+			return nil
+		}
+	}
+}
+
+// 转储在这里序列化, 不占用RankHandler的goroutine
+func writeReplicaEvent(w io.Writer, e replicaEvent) error {
+	if e.subscribe != nil {
+		return writeReplicaSubscribe(w, e.subscribe)
+	}
+	data := e.record
+	if e.checkpoint != nil {
+		checkpoint, err := e.checkpoint.Checkpoint()
+		if err != nil {
+			return err
+		}
+		if data, err = checkpoint.MarshalBinary(); err != nil {
+			return err
+		}
+	}
+	for {
+		n := len(data)
+		if n > REPLICA_CHUNK_SIZE {
+			n = REPLICA_CHUNK_SIZE
+		}
+		err := writeFrame(w, serverproto.MessageType_TypeReplicaData,
+			&serverproto.ReplicaData{
+				Rank:       proto.Uint32(e.rankID),
+				Checkpoint: proto.Bool(e.checkpoint != nil),
+				Data:       data[:n],
+				Last:       proto.Bool(n == len(data)),
+			})
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+// done关闭时关闭conn, 结束阻塞的读写, 返回的函数用于停止等待
+func closeOnDone(conn net.Conn, done <-chan struct{}) func() {
+	exit := make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+			conn.Close()
+		case <-exit:
+		}
+	}()
+	return func() { close(exit) }
+}
+
+// 处理副本的连接, 收到订阅请求之后持续推送数据, 直到连接断开或者done关闭
+func ServeReplica(d *Dispatcher, conn net.Conn, done <-chan struct{}) error {
+	defer conn.Close()
+	defer closeOnDone(conn, done)()
+	var req frame.Frame
+	if _, err := req.ReadFrom(bufio.NewReader(conn)); err != nil {
+		return NewError("Read frame", err)
+	}
+	if req.PayloadType !=
+		uint32(serverproto.MessageType_TypeReplicaSubscribeRequest) {
+		return fmt.Errorf("Expect subscribe request, got: %d", req.PayloadType)
+	}
+	sub, ok := d.SubscribeReplica()
+	if !ok {
+		return nil
+	}
+	defer sub.Close()
+	glog.Infof("Replica %s subscribed", conn.RemoteAddr())
+	// 副本不会再发送数据, 读取失败说明连接已经断开
+	go func() {
+		io.Copy(io.Discard, conn)
+		sub.Close()
+	}()
+	w := bufio.NewWriter(conn)
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-sub.closed:
+			return fmt.Errorf("Replica subscriber closed")
+		case e := <-sub.events:
+			conn.SetWriteDeadline(time.Now().Add(REPLICA_WRITE_TIMEOUT))
+			if err := writeReplicaEvent(w, e); err != nil {
+				return NewError("Write frame", err)
+			}
+			// 没有等待发送的数据时才刷新
+			if len(sub.events) != 0 {
+				continue
+			}
+			if err := w.Flush(); err != nil {
+				return NewError("Write frame", err)
+			}
+		}
+	}
+}
+
+// 连接主节点并同步数据, 断开之后等待一段时间重新连接并重新同步, 直到done关闭
+func ReplicateFrom(d *Dispatcher, address string, done <-chan struct{}) {
+	for {
+		err := replicate(d, address, done)
+		select {
+		case <-done:
+			return
+		default:
+		}
+		glog.Warningf("Replicate from %s: %v", address, err)
+		select {
+		case <-done:
+			return
+		case <-time.After(REPLICA_RETRY_INTERVAL):
+		}
+	}
+}
+
+func replicate(d *Dispatcher, address string, done <-chan struct{}) error {
+	conn, err := net.DialTimeout("tcp", address, REPLICA_DIAL_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer closeOnDone(conn, done)()
+	err = writeFrame(conn, serverproto.MessageType_TypeReplicaSubscribeRequest,
+		&serverproto.ReplicaSubscribeRequest{})
+	if err != nil {
+		return NewError("Write frame", err)
+	}
+	r := bufio.NewReader(conn)
+	subscribed := false
+	// 拆分成多个包的订阅回包以及数据
+	var subscribe *serverproto.ReplicaSubscribeResponse
+	var pending []byte
+	for {
+		var f frame.Frame
+		if _, err := f.ReadFrom(r); err != nil {
+			return NewError("Read frame", err)
+		}
+		switch serverproto.MessageType(f.PayloadType) {
+		case serverproto.MessageType_TypeReplicaSubscribeResponse:
+			if subscribed {
+				return fmt.Errorf("Unexpected subscribe response")
+			}
+			resp := &serverproto.ReplicaSubscribeResponse{}
+			if err := proto.Unmarshal(f.Payload, resp); err != nil {
+				return err
+			}
+			if subscribe == nil {
+				subscribe = resp
+			} else {
+				subscribe.Ranks = append(subscribe.Ranks, resp.Ranks...)
+			}
+			if !resp.GetLast() {
+				continue
+			}
+			var err error
+			if !d.runInDispatcher(func(d *Dispatcher) {
+				err = d.resetReplica(subscribe)
+			}) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			subscribed = true
+		case serverproto.MessageType_TypeReplicaData:
+			if !subscribed {
+				return fmt.Errorf("Expect subscribe response first")
+			}
+			msg := &serverproto.ReplicaData{}
+			if err := proto.Unmarshal(f.Payload, msg); err != nil {
+				return err
+			}
+			pending = append(pending, msg.Data...)
+			if !msg.GetLast() {
+				continue
+			}
+			err := d.applyReplicaData(msg.GetRank(), msg.GetCheckpoint(), pending)
+			if err != nil {
+				return err
+			}
+			pending = nil
+		default:
+			return fmt.Errorf("Unexpected type: %d", f.PayloadType)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jacobwpeng/sirius/engine"
+	"github.com/jacobwpeng/sirius/frame"
+	"github.com/jacobwpeng/sirius/serverproto"
+)
+
+// 等待副本的排行榜与expect一致
+func waitRangeIDs(t *testing.T, d *Dispatcher, rankID uint32, expect []uint64) {
+	deadline := time.Now().Add(time.Second * 5)
+	for {
+		// 副本同步完成之前排行榜可能不存在
+		var ids []uint64
+		jobResult := dispatchTestJob(t, d, rankID, &serverproto.GetRangeRequest{
+			Start: proto.Uint32(0),
+			Num:   proto.Uint32(10),
+		})
+		if jobResult.ErrCode == 0 {
+			for _, u := range jobResult.Msg.(*serverproto.GetRangeResponse).Data {
+				ids = append(ids, u.GetId())
+			}
+		}
+		if reflect.DeepEqual(ids, expect) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expect rank %d of replica %v, got: %v", rankID, expect, ids)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
+
+func TestReplicateFromPrimary(t *testing.T) {
+	start := time.Date(2017, 3, 23, 0, 0, 0, 0, time.UTC)
+	clock := engine.NewFakeClock(start)
+	ranks := make(map[uint32]engine.RankEngine)
+	ranks[1], _ = engine.NewRankEngine(engine.RankEngineConfig{MaxSize: 10})
+	ranks[2], _ = engine.NewRankEngine(engine.RankEngineConfig{
+		MaxSize:       10,
+		PrimaryRankID: 1,
+		HistorySize:   2,
+		SnapshotPeriod: engine.TimePeriod{
+			Start:    start,
+			Interval: time.Hour,
+		},
+	})
+	primary, err := NewDispatcher(ranks, AppConfig{
+		AcceptClientAddress: "127.0.0.1:9427",
+	}, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	primary.Start()
+	defer primary.Stop()
+
+	// 超过一个包的数据需要拆分发送
+	bigValue := bytes.Repeat([]byte("v"), REPLICA_CHUNK_SIZE+100)
+	big := updateRequest(1, 100)
+	big.Data.Value = bigValue
+	for _, msg := range []*serverproto.UpdateRequest{big, updateRequest(2, 50)} {
+		if jobResult := dispatchTestJob(t, primary, 1, msg); jobResult.ErrCode != 0 {
+			t.Fatalf("Update failed: %d", jobResult.ErrCode)
+		}
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go ServeReplica(primary, conn, done)
+		}
+	}()
+	replicaConfig := AppConfig{ReplicaOf: l.Addr().String()}
+	replica, err := NewDispatcher(nil, replicaConfig, engine.NewFakeClock(start))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica.Start()
+	defer replica.Stop()
+	go ReplicateFrom(replica, replicaConfig.ReplicaOf, done)
+
+	// 先收到转储, 再收到之后的修改, 清空以及快照
+	waitRangeIDs(t, replica, 1, []uint64{1, 2})
+	dispatchTestJob(t, primary, 1, updateRequest(3, 200))
+	dispatchTestJob(t, primary, 1, &serverproto.DeleteRequest{
+		Id:    proto.Uint64(2),
+		Reply: proto.Bool(true),
+	})
+	waitRangeIDs(t, replica, 1, []uint64{3, 1})
+	clock.Advance(time.Hour)
+	dispatchTestJob(t, primary, 1, updateRequest(4, 300))
+	waitRangeIDs(t, replica, 1, []uint64{4, 3, 1})
+	waitRangeIDs(t, replica, 2, []uint64{3, 1})
+
+	// 回包中没有value, 直接读取副本的排行榜
+	var value []byte
+	replica.runInDispatcher(func(d *Dispatcher) {
+		d.runInHandler(d.mappedHandlers[1], func(h *RankHandler) error {
+			_, _, u := h.FindRank(1).Get(1)
+			value = u.Value
+			return nil
+		})
+	})
+	if !bytes.Equal(value, bigValue) {
+		t.Errorf("Expect value size %d, got: %d", len(bigValue), len(value))
+	}
+
+	// 副本拒绝修改请求并返回主节点的地址
+	jobResult := dispatchTestJob(t, replica, 1, updateRequest(5, 400))
+	if jobResult.ErrCode != ErrRedirect {
+		t.Fatalf("Expect ErrRedirect, got: %d", jobResult.ErrCode)
+	}
+	address := jobResult.Msg.(*serverproto.RedirectResponse).GetAddress()
+	if address != "127.0.0.1:9427" {
+		t.Errorf("Expect redirect to 127.0.0.1:9427, got: %q", address)
+	}
+
+	// 主节点添加排行榜之后副本重新同步
+	jobResult = dispatchAdminTestJob(t, primary, 0, &serverproto.CreateRankRequest{
+		Rank:   proto.Uint32(3),
+		Config: &serverproto.RankConfig{MaxSize: proto.Uint32(10)},
+	})
+	if jobResult.ErrCode != 0 {
+		t.Fatalf("Create rank failed: %d", jobResult.ErrCode)
+	}
+	dispatchTestJob(t, primary, 3, updateRequest(6, 10))
+	waitRangeIDs(t, replica, 3, []uint64{6})
+	waitRangeIDs(t, replica, 1, []uint64{4, 3, 1})
+}
+
+func TestWriteReplicaSubscribeChunks(t *testing.T) {
+	resp := &serverproto.ReplicaSubscribeResponse{
+		ClientAddress: proto.String("127.0.0.1:9427"),
+	}
+	for i := uint32(1); i <= 5000; i++ {
+		resp.Ranks = append(resp.Ranks, &serverproto.CreateRankRequest{
+			Rank:   proto.Uint32(i),
+			Config: RankConfigToProto(engine.RankEngineConfig{MaxSize: 10}),
+		})
+	}
+	if proto.Size(resp) <= frame.MAX_PAYLOAD_SIZE {
+		t.Fatalf("Expect response larger than %d, got: %d",
+			frame.MAX_PAYLOAD_SIZE, proto.Size(resp))
+	}
+	var buf bytes.Buffer
+	if err := writeReplicaEvent(&buf, replicaEvent{subscribe: resp}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每个包都不超过REPLICA_CHUNK_SIZE, 合并之后与原回包一致
+	r := bufio.NewReader(&buf)
+	var ranks []*serverproto.CreateRankRequest
+	var chunks int
+	for {
+		var f frame.Frame
+		if _, err := f.ReadFrom(r); err != nil {
+			t.Fatal(err)
+		}
+		if len(f.Payload) > REPLICA_CHUNK_SIZE {
+			t.Errorf("Expect payload size at most %d, got: %d",
+				REPLICA_CHUNK_SIZE, len(f.Payload))
+		}
+		chunk := &serverproto.ReplicaSubscribeResponse{}
+		if err := proto.Unmarshal(f.Payload, chunk); err != nil {
+			t.Fatal(err)
+		}
+		if chunk.GetClientAddress() != resp.GetClientAddress() {
+			t.Errorf("Expect client address %q, got: %q",
+				resp.GetClientAddress(), chunk.GetClientAddress())
+		}
+		ranks = append(ranks, chunk.Ranks...)
+		chunks++
+		if chunk.GetLast() {
+			break
+		}
+	}
+	if chunks < 2 || buf.Len() != 0 {
+		t.Errorf("Expect multiple chunks and nothing left, got: %d %d", chunks,
+			buf.Len())
+	}
+	if len(ranks) != len(resp.Ranks) {
+		t.Fatalf("Expect %d ranks, got: %d", len(resp.Ranks), len(ranks))
+	}
+	for i := range ranks {
+		if !proto.Equal(ranks[i], resp.Ranks[i]) {
+			t.Fatalf("Expect rank %v, got: %v", resp.Ranks[i], ranks[i])
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jacobwpeng/sirius/engine"
+)
+
+// 一次结算的名次
+type SettlementUnit struct {
+	Pos         uint32 `json:"pos"`
+	DisplayRank uint32 `json:"display_rank"`
+	ID          uint64 `json:"id"`
+	Key         uint64 `json:"key"`
+	Value       []byte `json:"value,omitempty"`
+}
+
+// 排行榜清空或者被快照覆盖之前的最终名次
+type Settlement struct {
+	// 用于去重的唯一ID, 由排行榜ID, 结算时间以及原因组成
+	ID string `json:"id"`
+	// 在RankHandler中递增的序号, 开启WAL时就是对应WAL记录的LSN
+	Seq    uint64           `json:"seq"`
+	RankID uint32           `json:"rank"`
+	Time   time.Time        `json:"time"`
+	Reason string           `json:"reason"`
+	Units  []SettlementUnit `json:"units"`
+}
+
+// 取排行榜的前n名生成结算
+func NewSettlement(rankID uint32, rank engine.RankEngine,
+	reason WALRecordType, now time.Time, n uint32) *Settlement {
+	values := rank.GetRange(0, n)
+	displayRanks := engine.DisplayRanks(rank, 0, values)
+	units := make([]SettlementUnit, len(values))
+	for i, u := range values {
+		units[i] = SettlementUnit{
+			Pos:         uint32(i),
+			DisplayRank: displayRanks[i],
+			ID:          u.ID,
+			Key:         u.Key,
+			Value:       u.Value,
+		}
+	}
+	return &Settlement{
+		ID:     fmt.Sprintf("%d-%d-%s", rankID, now.Unix(), reason),
+		RankID: rankID,
+		Time:   now,
+		Reason: reason.String(),
+		Units:  units,
+	}
+}
+
+// 结算的接收方, Emit成功返回之后才认为结算已经送达
+// 同一个结算可能会因为重启而重复发送, 接收方需要根据ID去重
+type SettlementSink interface {
+	// 用于区分不同接收方的发送进度, 重启之间需要保持不变
+	Name() string
+	Emit(s *Settlement) error
+}
+
+// 追加写入文件, 每行一个JSON格式的结算
+type JSONLSettlementSink struct {
+	Path string
+}
+
+func (s *JSONLSettlementSink) Name() string {
+	return "jsonl:" + s.Path
+}
+
+func (s *JSONLSettlementSink) Emit(settlement *Settlement) error {
+	data, err := json.Marshal(settlement)
+	if err != nil {
+		return err
+	}
+	return appendFile(s.Path, func(f *os.File) error {
+		_, err := f.Write(append(data, '\n'))
+		return err
+	})
+}
+
+// 追加写入CSV文件, 每个名次一行, 文件为空时先写入表头
+type CSVSettlementSink struct {
+	Path string
+}
+
+var csvSettlementHeader = []string{"settlement_id", "rank", "time", "reason",
+	"pos", "display_rank", "id", "key", "value"}
+
+func (s *CSVSettlementSink) Name() string {
+	return "csv:" + s.Path
+}
+
+func (s *CSVSettlementSink) Emit(settlement *Settlement) error {
+	return appendFile(s.Path, func(f *os.File) error {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		cw := csv.NewWriter(f)
+		if info.Size() == 0 {
+			cw.Write(csvSettlementHeader)
+		}
+		for _, u := range settlement.Units {
+			cw.Write([]string{
+				settlement.ID,
+				strconv.FormatUint(uint64(settlement.RankID), 10),
+				settlement.Time.Format(time.RFC3339),
+				settlement.Reason,
+				strconv.FormatUint(uint64(u.Pos), 10),
+				strconv.FormatUint(uint64(u.DisplayRank), 10),
+				strconv.FormatUint(u.ID, 10),
+				strconv.FormatUint(u.Key, 10),
+				base64.StdEncoding.EncodeToString(u.Value),
+			})
+		}
+		cw.Flush()
+		return cw.Error()
+	})
+}
+
+func appendFile(path string, write func(f *os.File) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Client为空时使用的超时, 避免一次请求卡住整个outbox
+const SETTLEMENT_HTTP_TIMEOUT = time.Second * 10
+
+var defaultSettlementClient = &http.Client{Timeout: SETTLEMENT_HTTP_TIMEOUT}
+
+// 以JSON格式POST到URL, 返回2xx时认为送达
+// Client为空时使用超时为SETTLEMENT_HTTP_TIMEOUT的默认客户端
+type HTTPSettlementSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *HTTPSettlementSink) Name() string {
+	return "http:" + s.URL
+}
+
+func (s *HTTPSettlementSink) Emit(settlement *Settlement) error {
+	data, err := json.Marshal(settlement)
+	if err != nil {
+		return err
+	}
+	client := s.Client
+	if client == nil {
+		client = defaultSettlementClient
+	}
+	resp, err := client.Post(s.URL, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Post settlement %s: %s", settlement.ID, resp.Status)
+	}
+	return nil
+}
+
+// 在清空或者覆盖rank之前结算前SettlementSize名, 交给outbox在后台发送
+// 空的排行榜不结算
+func (h *RankHandler) Settle(rankID uint32, rank engine.RankEngine,
+	reason WALRecordType, now time.Time, lsn uint64) {
+	n := rank.Config().SettlementSize
+	if h.outbox == nil || n == 0 || rank.Size() == 0 {
+		return
+	}
+	s := NewSettlement(rankID, rank, reason, now, n)
+	s.Seq = lsn
+	if err := h.outbox.Add(s); err != nil {
+		// 结算仍然在内存中, 进程退出之前会继续发送
+		glog.Errorf("Add settlement %s: %v", s.ID, err)
+		return
+	}
+	glog.Infof("Settle rank %d before %s at %s, %d units", rankID, reason, now,
+		len(s.Units))
+}
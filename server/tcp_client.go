@@ -27,12 +27,16 @@ type TCPClient struct {
 	doneChan       chan struct{}
 	errChan        chan error
 	jobResultQueue chan JobResult
+	// 通过管理端口连接
+	privileged bool
 }
 
-func NewTCPClient(dispatcher *Dispatcher, conn *net.TCPConn) *TCPClient {
+func NewTCPClient(dispatcher *Dispatcher, conn *net.TCPConn,
+	privileged bool) *TCPClient {
 	return &TCPClient{
 		dispatcher:     dispatcher,
 		conn:           conn,
+		privileged:     privileged,
 		doneChan:       make(chan struct{}, 2),
 		errChan:        make(chan error, 2),
 		jobResultQueue: make(chan JobResult, MAX_BUFFERED_JOB_RESULT),
@@ -111,6 +115,32 @@ func (c *TCPClient) CreateJob(frame *frame.Frame) (job Job, err error) {
 		msg = &serverproto.UpdateRequest{}
 	case serverproto.MessageType_TypeDeleteRequest:
 		msg = &serverproto.DeleteRequest{}
+	case serverproto.MessageType_TypeGetAroundRequest:
+		msg = &serverproto.GetAroundRequest{}
+	case serverproto.MessageType_TypeRankOfKeyRequest:
+		msg = &serverproto.RankOfKeyRequest{}
+	case serverproto.MessageType_TypeGetByKeyRangeRequest:
+		msg = &serverproto.GetByKeyRangeRequest{}
+	case serverproto.MessageType_TypeBatchUpdateRequest:
+		msg = &serverproto.BatchUpdateRequest{}
+	case serverproto.MessageType_TypeMultiGetRequest:
+		msg = &serverproto.MultiGetRequest{}
+	case serverproto.MessageType_TypeSubsetRankRequest:
+		msg = &serverproto.SubsetRankRequest{}
+	case serverproto.MessageType_TypeGetHistoryRangeRequest:
+		msg = &serverproto.GetHistoryRangeRequest{}
+	case serverproto.MessageType_TypeGetNoUpdateWindowsRequest:
+		msg = &serverproto.GetNoUpdateWindowsRequest{}
+	case serverproto.MessageType_TypeSetNoUpdateWindowsRequest:
+		msg = &serverproto.SetNoUpdateWindowsRequest{}
+	case serverproto.MessageType_TypeCreateRankRequest:
+		msg = &serverproto.CreateRankRequest{}
+	case serverproto.MessageType_TypeDropRankRequest:
+		msg = &serverproto.DropRankRequest{}
+	case serverproto.MessageType_TypeListRanksRequest:
+		msg = &serverproto.ListRanksRequest{}
+	case serverproto.MessageType_TypeReloadConfigRequest:
+		msg = &serverproto.ReloadConfigRequest{}
 	default:
 		return job, fmt.Errorf("Unexpected type: %d", msgType)
 	}
@@ -129,12 +159,37 @@ func (c *TCPClient) CreateJob(frame *frame.Frame) (job Job, err error) {
 		job.RankID = m.GetRank()
 	case *serverproto.DeleteRequest:
 		job.RankID = m.GetRank()
+	case *serverproto.GetAroundRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.RankOfKeyRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.GetByKeyRangeRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.BatchUpdateRequest:
+		// 批量更新可能涉及多个排行榜, 由Dispatcher拆分
+	case *serverproto.MultiGetRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.SubsetRankRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.GetHistoryRangeRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.GetNoUpdateWindowsRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.SetNoUpdateWindowsRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.CreateRankRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.DropRankRequest:
+		job.RankID = m.GetRank()
+	case *serverproto.ListRanksRequest, *serverproto.ReloadConfigRequest:
+		// 管理请求由Dispatcher处理
 	default:
 		glog.Warning("Unexpected message type")
 	}
 	job.Frame = frame
 	job.Msg = msg
 	job.resultChan = c.jobResultQueue
+	job.privileged = c.privileged
 	return job, nil
 }
 
@@ -150,6 +205,13 @@ func (c *TCPClient) StartWriting() {
 				payload = MustMarshal(jobResult.Msg)
 			}
 			replyFrame := frame.New(jobResult.FramePayloadType, payload)
+			if replyFrame == nil {
+				// 回包过大时只返回错误码, 不影响其他请求
+				glog.Errorf("Reply type %d to %s too large: %d",
+					jobResult.FramePayloadType, c.conn.RemoteAddr(), len(payload))
+				replyFrame = frame.New(jobResult.FramePayloadType, nil)
+				jobResult.ErrCode = ErrServerFailure
+			}
 			replyFrame.ErrCode = jobResult.ErrCode
 			replyFrame.Ctx = jobResult.FrameCtx
 			if _, err := replyFrame.WriteTo(c.conn); err != nil {
@@ -0,0 +1,468 @@
+package server
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/jacobwpeng/goutil"
+	"github.com/jacobwpeng/sirius/engine"
+)
+
+const (
+	WAL_SEGMENT_SIZE    = 64 << 20
+	MAX_WAL_RECORD_SIZE = 16 << 20
+	WAL_SEGMENT_SUFFIX  = ".wal"
+	// 每条记录payload之前的size和crc32
+	WAL_RECORD_HEADER_SIZE = 8
+)
+
+// WAL刷盘策略
+type WALSyncPolicy uint8
+
+const (
+	// 每条记录写入后立即刷盘, 回包之前数据已经落盘
+	WALSyncAlways WALSyncPolicy = iota
+	// 每隔WALSyncInterval刷盘一次
+	WALSyncInterval
+	// 不主动刷盘, 由操作系统决定
+	WALSyncNever
+)
+
+func (p WALSyncPolicy) String() string {
+	switch p {
+	case WALSyncAlways:
+		return "always"
+	case WALSyncInterval:
+		return "interval"
+	case WALSyncNever:
+		return "never"
+	}
+	return fmt.Sprintf("WALSyncPolicy(%d)", uint8(p))
+}
+
+func ParseWALSyncPolicy(s string) (WALSyncPolicy, error) {
+	for _, p := range []WALSyncPolicy{WALSyncAlways, WALSyncInterval, WALSyncNever} {
+		if p.String() == s {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown WAL sync policy %q", s)
+}
+
+// WAL记录类型
+type WALRecordType uint8
+
+const (
+	WALRecordUpdate WALRecordType = iota + 1
+	WALRecordUpdateMany
+	WALRecordDelete
+	WALRecordClear
+	WALRecordSnapshot
+	WALRecordInitTime
+	WALRecordNoUpdateWindows
+	WALRecordReset
+)
+
+func (t WALRecordType) String() string {
+	switch t {
+	case WALRecordUpdate:
+		return "update"
+	case WALRecordUpdateMany:
+		return "update_many"
+	case WALRecordDelete:
+		return "delete"
+	case WALRecordClear:
+		return "clear"
+	case WALRecordSnapshot:
+		return "snapshot"
+	case WALRecordInitTime:
+		return "init_time"
+	case WALRecordNoUpdateWindows:
+		return "no_update_windows"
+	case WALRecordReset:
+		return "reset"
+	}
+	return fmt.Sprintf("WALRecordType(%d)", uint8(t))
+}
+
+// 一条WAL记录, 记录的是修改排行榜的请求而不是结果
+// 排行榜的修改是确定性的, 按顺序重放即可得到相同的状态
+type WALRecord struct {
+	LSN    uint64
+	Type   WALRecordType
+	RankID uint32
+	// Clear, Snapshot, InitTime和Reset发生的时间
+	Time time.Time
+	Mode engine.UpdateMode
+	// Delete的ID
+	ID uint64
+	// Update和UpdateMany的数据
+	Units []engine.RankUnit
+	// NoUpdateWindows修改之后的时间段
+	Windows *NoUpdateWindows
+}
+
+// 记录格式: size(4) crc32(4) payload
+// payload: lsn(8) type(1) rank(4) time(8) mode(1) id(8) count(4)
+// [id(8) key(8) value]..., 其中value以4字节长度作为前缀
+// NoUpdateWindows记录之后是修改之后的时间段
+func (r *WALRecord) MarshalBinary() ([]byte, error) {
+	payload, err := r.MarshalPayload()
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, WAL_RECORD_HEADER_SIZE+len(payload))
+	binary.LittleEndian.PutUint32(data[0:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(data[4:], crc32.ChecksumIEEE(payload))
+	copy(data[WAL_RECORD_HEADER_SIZE:], payload)
+	return data, nil
+}
+
+// 只序列化payload, 不包含size和crc32, 与UnmarshalBinary对应
+func (r *WALRecord) MarshalPayload() ([]byte, error) {
+	var buf bytes.Buffer
+	w := goutil.NewStrickyWriter(&buf)
+	var ts int64
+	if !r.Time.IsZero() {
+		ts = r.Time.UnixNano()
+	}
+	binary.Write(w, binary.LittleEndian, r.LSN)
+	binary.Write(w, binary.LittleEndian, uint8(r.Type))
+	binary.Write(w, binary.LittleEndian, r.RankID)
+	binary.Write(w, binary.LittleEndian, ts)
+	binary.Write(w, binary.LittleEndian, uint8(r.Mode))
+	binary.Write(w, binary.LittleEndian, r.ID)
+	binary.Write(w, binary.LittleEndian, uint32(len(r.Units)))
+	for _, u := range r.Units {
+		binary.Write(w, binary.LittleEndian, u.ID)
+		binary.Write(w, binary.LittleEndian, u.Key)
+		binary.Write(w, binary.LittleEndian, uint32(len(u.Value)))
+		w.Write(u.Value)
+	}
+	if r.Type == WALRecordNoUpdateWindows {
+		r.Windows.marshal(w)
+	}
+	if w.Err != nil {
+		return nil, w.Err
+	}
+	if buf.Len() > MAX_WAL_RECORD_SIZE {
+		return nil, fmt.Errorf("Max WAL record size %d, got: %d",
+			MAX_WAL_RECORD_SIZE, buf.Len())
+	}
+	return buf.Bytes(), nil
+}
+
+func (r *WALRecord) UnmarshalBinary(payload []byte) error {
+	sr := goutil.NewStrickyReader(bytes.NewReader(payload))
+	var recordType, mode uint8
+	var ts int64
+	var count uint32
+	binary.Read(sr, binary.LittleEndian, &r.LSN)
+	binary.Read(sr, binary.LittleEndian, &recordType)
+	binary.Read(sr, binary.LittleEndian, &r.RankID)
+	binary.Read(sr, binary.LittleEndian, &ts)
+	binary.Read(sr, binary.LittleEndian, &mode)
+	binary.Read(sr, binary.LittleEndian, &r.ID)
+	binary.Read(sr, binary.LittleEndian, &count)
+	if sr.Err != nil {
+		return sr.Err
+	}
+	r.Type = WALRecordType(recordType)
+	r.Mode = engine.UpdateMode(mode)
+	r.Time = time.Time{}
+	if ts != 0 {
+		r.Time = time.Unix(0, ts)
+	}
+	// 每条数据至少占用20字节, 防止count损坏时分配过多内存
+	if uint64(count)*20 > uint64(len(payload)) {
+		return fmt.Errorf("Invalid unit count %d", count)
+	}
+	r.Units = make([]engine.RankUnit, count)
+	for i := range r.Units {
+		u := &r.Units[i]
+		var size uint32
+		binary.Read(sr, binary.LittleEndian, &u.ID)
+		binary.Read(sr, binary.LittleEndian, &u.Key)
+		binary.Read(sr, binary.LittleEndian, &size)
+		if sr.Err != nil {
+			return sr.Err
+		}
+		if size > uint32(len(payload)) {
+			return fmt.Errorf("Invalid value size %d", size)
+		}
+		if size != 0 {
+			u.Value = make([]byte, size)
+			sr.Read(u.Value)
+		}
+	}
+	r.Windows = nil
+	if r.Type == WALRecordNoUpdateWindows {
+		r.Windows = &NoUpdateWindows{}
+		return r.Windows.unmarshal(sr)
+	}
+	return sr.Err
+}
+
+// 按段追加写入的WAL, 每个段以其第一条记录的LSN命名
+// 只能在RankHandler的goroutine中使用
+type WAL struct {
+	dir         string
+	policy      WALSyncPolicy
+	file        *os.File
+	segmentSize int64
+	nextLSN     uint64
+	dirty       bool
+	// 写入失败且无法回滚时记录错误, 之后的写入都会失败
+	err error
+}
+
+func walSegmentName(firstLSN uint64) string {
+	return fmt.Sprintf("%020d%s", firstLSN, WAL_SEGMENT_SUFFIX)
+}
+
+// 返回dir下的所有段的第一条记录的LSN, 从小到大排列
+func listWALSegments(dir string) ([]uint64, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"+WAL_SEGMENT_SUFFIX))
+	if err != nil {
+		return nil, err
+	}
+	var segments []uint64
+	for _, name := range names {
+		var firstLSN uint64
+		base := filepath.Base(name)
+		if _, err := fmt.Sscanf(base, "%d"+WAL_SEGMENT_SUFFIX, &firstLSN); err != nil {
+			glog.Warningf("Ignore unexpected WAL file %s", name)
+			continue
+		}
+		segments = append(segments, firstLSN)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i] < segments[j]
+	})
+	return segments, nil
+}
+
+// 目录项的修改需要对目录本身刷盘
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+// 从nextLSN开始写入新的段
+func OpenWAL(dir string, nextLSN uint64, policy WALSyncPolicy) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	w := &WAL{
+		dir:     dir,
+		policy:  policy,
+		nextLSN: nextLSN,
+	}
+	if err := w.openSegment(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *WAL) openSegment() error {
+	name := filepath.Join(w.dir, walSegmentName(w.nextLSN))
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	if err := syncDir(w.dir); err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.segmentSize = info.Size()
+	return nil
+}
+
+func (w *WAL) NextLSN() uint64 {
+	return w.nextLSN
+}
+
+// 追加一条记录并分配LSN, 根据刷盘策略决定是否立即刷盘
+func (w *WAL) Append(r *WALRecord) error {
+	if w.err != nil {
+		return w.err
+	}
+	r.LSN = w.nextLSN
+	data, err := r.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if _, err := w.file.Write(data); err != nil {
+		// 截断写了一半的记录, 否则之后的记录在恢复时都会被丢弃
+		if terr := w.file.Truncate(w.segmentSize); terr != nil {
+			w.err = fmt.Errorf("WAL broken: %v", terr)
+		}
+		return err
+	}
+	w.segmentSize += int64(len(data))
+	w.nextLSN++
+	w.dirty = true
+	if w.policy == WALSyncAlways {
+		if err := w.Sync(); err != nil {
+			return err
+		}
+	}
+	// 记录已经写入, 切换段失败只影响之后的写入
+	if w.segmentSize >= WAL_SEGMENT_SIZE {
+		if err := w.rotate(); err != nil {
+			glog.Errorf("Rotate WAL segment in %s: %v", w.dir, err)
+		}
+	}
+	return nil
+}
+
+// 开始一个新的段, 当前段为空时不做任何操作
+func (w *WAL) Rotate() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.segmentSize == 0 {
+		return nil
+	}
+	return w.rotate()
+}
+
+func (w *WAL) rotate() error {
+	if err := w.Sync(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		w.err = err
+		return err
+	}
+	if err := w.openSegment(); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+func (w *WAL) Sync() error {
+	if !w.dirty {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		// 刷盘失败后无法确定哪些数据已经落盘
+		w.err = fmt.Errorf("WAL broken: %v", err)
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+func (w *WAL) Close() error {
+	err := w.Sync()
+	if cerr := w.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// 读取一个段中的记录, 返回最后一条完整记录之后的偏移
+// 数据损坏时返回corrupt, 读文件以及fn返回的错误通过err返回
+func readWALSegment(name string,
+	fn func(r *WALRecord) error) (offset int64, corrupt error, err error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return 0, nil, err
+	}
+	for {
+		rest := data[offset:]
+		if len(rest) == 0 {
+			return offset, nil, nil
+		}
+		if len(rest) < WAL_RECORD_HEADER_SIZE {
+			return offset, io.ErrUnexpectedEOF, nil
+		}
+		size := binary.LittleEndian.Uint32(rest[0:])
+		checksum := binary.LittleEndian.Uint32(rest[4:])
+		if size > MAX_WAL_RECORD_SIZE ||
+			uint64(len(rest)-WAL_RECORD_HEADER_SIZE) < uint64(size) {
+			return offset, io.ErrUnexpectedEOF, nil
+		}
+		payload := rest[WAL_RECORD_HEADER_SIZE : WAL_RECORD_HEADER_SIZE+size]
+		if crc32.ChecksumIEEE(payload) != checksum {
+			return offset, fmt.Errorf("Checksum mismatch"), nil
+		}
+		var r WALRecord
+		if err := r.UnmarshalBinary(payload); err != nil {
+			return offset, err, nil
+		}
+		if err := fn(&r); err != nil {
+			return offset, nil, err
+		}
+		offset += WAL_RECORD_HEADER_SIZE + int64(size)
+	}
+}
+
+// 按顺序重放dir中LSN不小于fromLSN的记录, 返回下一条记录的LSN
+// 最后一个段末尾不完整的记录是写入时崩溃导致的, 会被截断
+func ReplayWAL(dir string, fromLSN uint64,
+	fn func(r *WALRecord) error) (uint64, error) {
+	segments, err := listWALSegments(dir)
+	if err != nil {
+		return 0, err
+	}
+	nextLSN := fromLSN
+	for i, firstLSN := range segments {
+		last := i == len(segments)-1
+		// 整个段都在fromLSN之前
+		if !last && segments[i+1] <= fromLSN {
+			continue
+		}
+		if firstLSN > nextLSN {
+			return 0, fmt.Errorf("WAL records [%d, %d) missing", nextLSN, firstLSN)
+		}
+		name := filepath.Join(dir, walSegmentName(firstLSN))
+		expectLSN := firstLSN
+		offset, corrupt, err := readWALSegment(name, func(r *WALRecord) error {
+			if r.LSN != expectLSN {
+				return fmt.Errorf("Expect LSN %d, got: %d", expectLSN, r.LSN)
+			}
+			expectLSN++
+			if r.LSN < fromLSN {
+				return nil
+			}
+			return fn(r)
+		})
+		if err != nil {
+			return 0, fmt.Errorf("Replay WAL segment %s: %v", name, err)
+		}
+		if corrupt != nil {
+			if !last {
+				return 0, fmt.Errorf("Read WAL segment %s: %v", name, corrupt)
+			}
+			glog.Warningf("Truncate WAL segment %s at %d: %v", name, offset, corrupt)
+			if err := os.Truncate(name, offset); err != nil {
+				return 0, err
+			}
+		}
+		if expectLSN > nextLSN {
+			nextLSN = expectLSN
+		}
+	}
+	return nextLSN, nil
+}
@@ -21,6 +21,44 @@ It has these top-level messages:
 	UpdateResponse
 	DeleteRequest
 	DeleteResponse
+	GetAroundRequest
+	GetAroundResponse
+	RankOfKeyRequest
+	RankOfKeyResponse
+	GetByKeyRangeRequest
+	GetByKeyRangeResponse
+	BatchUpdateItem
+	BatchUpdateRequest
+	BatchUpdateResult
+	BatchUpdateResponse
+	MultiGetRequest
+	MultiGetResult
+	MultiGetResponse
+	SubsetRankRequest
+	SubsetRankUnit
+	SubsetRankResponse
+	GetHistoryRangeRequest
+	GetHistoryRangeResponse
+	NoUpdateWindow
+	GetNoUpdateWindowsRequest
+	GetNoUpdateWindowsResponse
+	SetNoUpdateWindowsRequest
+	SetNoUpdateWindowsResponse
+	RankConfig
+	CreateRankRequest
+	CreateRankResponse
+	DropRankRequest
+	DropRankResponse
+	ListRanksRequest
+	RankInfo
+	ListRanksResponse
+	RankRegistry
+	ReloadConfigRequest
+	ReloadConfigResponse
+	ReplicaSubscribeRequest
+	ReplicaSubscribeResponse
+	ReplicaData
+	RedirectResponse
 */
 package serverproto
 
@@ -40,16 +78,46 @@ const _ = proto.ProtoPackageIsVersion1
 type MessageType int32
 
 const (
-	MessageType_TypeGetRequest        MessageType = 10000
-	MessageType_TypeGetResponse       MessageType = 10001
-	MessageType_TypeGetByRankRequest  MessageType = 10002
-	MessageType_TypeGetByRankResponse MessageType = 10003
-	MessageType_TypeGetRangeRequest   MessageType = 10004
-	MessageType_TypeGetRangeResponse  MessageType = 10005
-	MessageType_TypeUpdateRequest     MessageType = 10006
-	MessageType_TypeUpdateResponse    MessageType = 10007
-	MessageType_TypeDeleteRequest     MessageType = 10008
-	MessageType_TypeDeleteResponse    MessageType = 10009
+	MessageType_TypeGetRequest                 MessageType = 10000
+	MessageType_TypeGetResponse                MessageType = 10001
+	MessageType_TypeGetByRankRequest           MessageType = 10002
+	MessageType_TypeGetByRankResponse          MessageType = 10003
+	MessageType_TypeGetRangeRequest            MessageType = 10004
+	MessageType_TypeGetRangeResponse           MessageType = 10005
+	MessageType_TypeUpdateRequest              MessageType = 10006
+	MessageType_TypeUpdateResponse             MessageType = 10007
+	MessageType_TypeDeleteRequest              MessageType = 10008
+	MessageType_TypeDeleteResponse             MessageType = 10009
+	MessageType_TypeGetAroundRequest           MessageType = 10010
+	MessageType_TypeGetAroundResponse          MessageType = 10011
+	MessageType_TypeRankOfKeyRequest           MessageType = 10012
+	MessageType_TypeRankOfKeyResponse          MessageType = 10013
+	MessageType_TypeGetByKeyRangeRequest       MessageType = 10014
+	MessageType_TypeGetByKeyRangeResponse      MessageType = 10015
+	MessageType_TypeBatchUpdateRequest         MessageType = 10016
+	MessageType_TypeBatchUpdateResponse        MessageType = 10017
+	MessageType_TypeMultiGetRequest            MessageType = 10018
+	MessageType_TypeMultiGetResponse           MessageType = 10019
+	MessageType_TypeSubsetRankRequest          MessageType = 10020
+	MessageType_TypeSubsetRankResponse         MessageType = 10021
+	MessageType_TypeGetHistoryRangeRequest     MessageType = 10022
+	MessageType_TypeGetHistoryRangeResponse    MessageType = 10023
+	MessageType_TypeGetNoUpdateWindowsRequest  MessageType = 10024
+	MessageType_TypeGetNoUpdateWindowsResponse MessageType = 10025
+	MessageType_TypeSetNoUpdateWindowsRequest  MessageType = 10026
+	MessageType_TypeSetNoUpdateWindowsResponse MessageType = 10027
+	MessageType_TypeCreateRankRequest          MessageType = 10028
+	MessageType_TypeCreateRankResponse         MessageType = 10029
+	MessageType_TypeDropRankRequest            MessageType = 10030
+	MessageType_TypeDropRankResponse           MessageType = 10031
+	MessageType_TypeListRanksRequest           MessageType = 10032
+	MessageType_TypeListRanksResponse          MessageType = 10033
+	MessageType_TypeReloadConfigRequest        MessageType = 10034
+	MessageType_TypeReloadConfigResponse       MessageType = 10035
+	MessageType_TypeReplicaSubscribeRequest    MessageType = 10036
+	MessageType_TypeReplicaSubscribeResponse   MessageType = 10037
+	MessageType_TypeReplicaData                MessageType = 10038
+	MessageType_TypeRedirectResponse           MessageType = 10039
 )
 
 var MessageType_name = map[int32]string{
@@ -63,18 +131,78 @@ var MessageType_name = map[int32]string{
 	10007: "TypeUpdateResponse",
 	10008: "TypeDeleteRequest",
 	10009: "TypeDeleteResponse",
+	10010: "TypeGetAroundRequest",
+	10011: "TypeGetAroundResponse",
+	10012: "TypeRankOfKeyRequest",
+	10013: "TypeRankOfKeyResponse",
+	10014: "TypeGetByKeyRangeRequest",
+	10015: "TypeGetByKeyRangeResponse",
+	10016: "TypeBatchUpdateRequest",
+	10017: "TypeBatchUpdateResponse",
+	10018: "TypeMultiGetRequest",
+	10019: "TypeMultiGetResponse",
+	10020: "TypeSubsetRankRequest",
+	10021: "TypeSubsetRankResponse",
+	10022: "TypeGetHistoryRangeRequest",
+	10023: "TypeGetHistoryRangeResponse",
+	10024: "TypeGetNoUpdateWindowsRequest",
+	10025: "TypeGetNoUpdateWindowsResponse",
+	10026: "TypeSetNoUpdateWindowsRequest",
+	10027: "TypeSetNoUpdateWindowsResponse",
+	10028: "TypeCreateRankRequest",
+	10029: "TypeCreateRankResponse",
+	10030: "TypeDropRankRequest",
+	10031: "TypeDropRankResponse",
+	10032: "TypeListRanksRequest",
+	10033: "TypeListRanksResponse",
+	10034: "TypeReloadConfigRequest",
+	10035: "TypeReloadConfigResponse",
+	10036: "TypeReplicaSubscribeRequest",
+	10037: "TypeReplicaSubscribeResponse",
+	10038: "TypeReplicaData",
+	10039: "TypeRedirectResponse",
 }
 var MessageType_value = map[string]int32{
-	"TypeGetRequest":        10000,
-	"TypeGetResponse":       10001,
-	"TypeGetByRankRequest":  10002,
-	"TypeGetByRankResponse": 10003,
-	"TypeGetRangeRequest":   10004,
-	"TypeGetRangeResponse":  10005,
-	"TypeUpdateRequest":     10006,
-	"TypeUpdateResponse":    10007,
-	"TypeDeleteRequest":     10008,
-	"TypeDeleteResponse":    10009,
+	"TypeGetRequest":                 10000,
+	"TypeGetResponse":                10001,
+	"TypeGetByRankRequest":           10002,
+	"TypeGetByRankResponse":          10003,
+	"TypeGetRangeRequest":            10004,
+	"TypeGetRangeResponse":           10005,
+	"TypeUpdateRequest":              10006,
+	"TypeUpdateResponse":             10007,
+	"TypeDeleteRequest":              10008,
+	"TypeDeleteResponse":             10009,
+	"TypeGetAroundRequest":           10010,
+	"TypeGetAroundResponse":          10011,
+	"TypeRankOfKeyRequest":           10012,
+	"TypeRankOfKeyResponse":          10013,
+	"TypeGetByKeyRangeRequest":       10014,
+	"TypeGetByKeyRangeResponse":      10015,
+	"TypeBatchUpdateRequest":         10016,
+	"TypeBatchUpdateResponse":        10017,
+	"TypeMultiGetRequest":            10018,
+	"TypeMultiGetResponse":           10019,
+	"TypeSubsetRankRequest":          10020,
+	"TypeSubsetRankResponse":         10021,
+	"TypeGetHistoryRangeRequest":     10022,
+	"TypeGetHistoryRangeResponse":    10023,
+	"TypeGetNoUpdateWindowsRequest":  10024,
+	"TypeGetNoUpdateWindowsResponse": 10025,
+	"TypeSetNoUpdateWindowsRequest":  10026,
+	"TypeSetNoUpdateWindowsResponse": 10027,
+	"TypeCreateRankRequest":          10028,
+	"TypeCreateRankResponse":         10029,
+	"TypeDropRankRequest":            10030,
+	"TypeDropRankResponse":           10031,
+	"TypeListRanksRequest":           10032,
+	"TypeListRanksResponse":          10033,
+	"TypeReloadConfigRequest":        10034,
+	"TypeReloadConfigResponse":       10035,
+	"TypeReplicaSubscribeRequest":    10036,
+	"TypeReplicaSubscribeResponse":   10037,
+	"TypeReplicaData":                10038,
+	"TypeRedirectResponse":           10039,
 }
 
 func (x MessageType) Enum() *MessageType {
@@ -95,6 +223,51 @@ func (x *MessageType) UnmarshalJSON(data []byte) error {
 }
 func (MessageType) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
 
+// 上报数据的更新方式
+type UpdateMode int32
+
+const (
+	// 直接覆盖原有数据
+	UpdateMode_Replace UpdateMode = 0
+	// 仅当新的key更大时更新
+	UpdateMode_KeepHigher UpdateMode = 1
+	// 仅当新的key更小时更新
+	UpdateMode_KeepLower UpdateMode = 2
+	// 在原有key上累加delta
+	UpdateMode_AddDelta UpdateMode = 3
+)
+
+var UpdateMode_name = map[int32]string{
+	0: "Replace",
+	1: "KeepHigher",
+	2: "KeepLower",
+	3: "AddDelta",
+}
+var UpdateMode_value = map[string]int32{
+	"Replace":    0,
+	"KeepHigher": 1,
+	"KeepLower":  2,
+	"AddDelta":   3,
+}
+
+func (x UpdateMode) Enum() *UpdateMode {
+	p := new(UpdateMode)
+	*p = x
+	return p
+}
+func (x UpdateMode) String() string {
+	return proto.EnumName(UpdateMode_name, int32(x))
+}
+func (x *UpdateMode) UnmarshalJSON(data []byte) error {
+	value, err := proto.UnmarshalJSONEnum(UpdateMode_value, data, "UpdateMode")
+	if err != nil {
+		return err
+	}
+	*x = UpdateMode(value)
+	return nil
+}
+func (UpdateMode) EnumDescriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
 type RankUnit struct {
 	Id               *uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
 	Key              *uint64 `protobuf:"varint,2,opt,name=key" json:"key,omitempty"`
@@ -186,8 +359,10 @@ type GetResponse struct {
 	// 查询的数据排名, 0表示未上榜
 	Pos *uint32 `protobuf:"varint,2,opt,name=pos" json:"pos,omitempty"`
 	// 查询的数据
-	Data             *RankUnit `protobuf:"bytes,3,opt,name=data" json:"data,omitempty"`
-	XXX_unrecognized []byte    `json:"-"`
+	Data *RankUnit `protobuf:"bytes,3,opt,name=data" json:"data,omitempty"`
+	// 按排行榜名次规则计算的展示名次, 从1开始, 0表示未上榜
+	DisplayRank      *uint32 `protobuf:"varint,4,opt,name=display_rank" json:"display_rank,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *GetResponse) Reset()                    { *m = GetResponse{} }
@@ -216,6 +391,13 @@ func (m *GetResponse) GetData() *RankUnit {
 	return nil
 }
 
+func (m *GetResponse) GetDisplayRank() uint32 {
+	if m != nil && m.DisplayRank != nil {
+		return *m.DisplayRank
+	}
+	return 0
+}
+
 type GetByRankRequest struct {
 	// 操作的排行榜ID
 	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
@@ -249,8 +431,10 @@ type GetByRankResponse struct {
 	// 查询的数据排名, 0表示未上榜
 	Pos *uint32 `protobuf:"varint,2,opt,name=pos" json:"pos,omitempty"`
 	// 查询的数据
-	Data             *RankUnit `protobuf:"bytes,3,opt,name=data" json:"data,omitempty"`
-	XXX_unrecognized []byte    `json:"-"`
+	Data *RankUnit `protobuf:"bytes,3,opt,name=data" json:"data,omitempty"`
+	// 按排行榜名次规则计算的展示名次, 从1开始, 0表示未上榜
+	DisplayRank      *uint32 `protobuf:"varint,4,opt,name=display_rank" json:"display_rank,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *GetByRankResponse) Reset()                    { *m = GetByRankResponse{} }
@@ -279,6 +463,13 @@ func (m *GetByRankResponse) GetData() *RankUnit {
 	return nil
 }
 
+func (m *GetByRankResponse) GetDisplayRank() uint32 {
+	if m != nil && m.DisplayRank != nil {
+		return *m.DisplayRank
+	}
+	return 0
+}
+
 type GetRangeRequest struct {
 	// 操作的排行榜ID
 	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
@@ -321,8 +512,12 @@ type GetRangeResponse struct {
 	// 查询的排行榜数据总量
 	Total *uint32 `protobuf:"varint,2,opt,name=total" json:"total,omitempty"`
 	// 查询的数据
-	Data             []*RankUnit `protobuf:"bytes,3,rep,name=data" json:"data,omitempty"`
-	XXX_unrecognized []byte      `json:"-"`
+	Data []*RankUnit `protobuf:"bytes,3,rep,name=data" json:"data,omitempty"`
+	// 第一条数据的排名
+	Start *uint32 `protobuf:"varint,4,opt,name=start" json:"start,omitempty"`
+	// 每条数据对应的展示名次, 与data一一对应
+	DisplayRank      []uint32 `protobuf:"varint,5,rep,name=display_rank" json:"display_rank,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
 }
 
 func (m *GetRangeResponse) Reset()                    { *m = GetRangeResponse{} }
@@ -351,6 +546,20 @@ func (m *GetRangeResponse) GetData() []*RankUnit {
 	return nil
 }
 
+func (m *GetRangeResponse) GetStart() uint32 {
+	if m != nil && m.Start != nil {
+		return *m.Start
+	}
+	return 0
+}
+
+func (m *GetRangeResponse) GetDisplayRank() []uint32 {
+	if m != nil {
+		return m.DisplayRank
+	}
+	return nil
+}
+
 type UpdateRequest struct {
 	// 操作的排行榜ID
 	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
@@ -363,8 +572,12 @@ type UpdateRequest struct {
 	// 是否跳过非上报时段校验
 	BypassNoUpdate *bool `protobuf:"varint,5,opt,name=bypass_no_update" json:"bypass_no_update,omitempty"`
 	// 更新需要满足的服务器时间范围
-	ServerTimeRange  *ServerTimeRange `protobuf:"bytes,6,opt,name=server_time_range" json:"server_time_range,omitempty"`
-	XXX_unrecognized []byte           `json:"-"`
+	ServerTimeRange *ServerTimeRange `protobuf:"bytes,6,opt,name=server_time_range" json:"server_time_range,omitempty"`
+	// 更新方式, 默认直接覆盖
+	Mode *UpdateMode `protobuf:"varint,7,opt,name=mode,enum=serverproto.UpdateMode" json:"mode,omitempty"`
+	// AddDelta方式下累加的增量, 可以为负数, 此时忽略data中的key
+	Delta            *int64 `protobuf:"zigzag64,8,opt,name=delta" json:"delta,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
 }
 
 func (m *UpdateRequest) Reset()                    { *m = UpdateRequest{} }
@@ -414,6 +627,20 @@ func (m *UpdateRequest) GetServerTimeRange() *ServerTimeRange {
 	return nil
 }
 
+func (m *UpdateRequest) GetMode() UpdateMode {
+	if m != nil && m.Mode != nil {
+		return *m.Mode
+	}
+	return UpdateMode_Replace
+}
+
+func (m *UpdateRequest) GetDelta() int64 {
+	if m != nil && m.Delta != nil {
+		return *m.Delta
+	}
+	return 0
+}
+
 type UpdateResponse struct {
 	// 操作的排行榜ID
 	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
@@ -422,8 +649,14 @@ type UpdateResponse struct {
 	// 操作后的排名, 0表示未上榜
 	Pos *uint32 `protobuf:"varint,3,opt,name=pos" json:"pos,omitempty"`
 	// 操作前对应的数据
-	Data             *RankUnit `protobuf:"bytes,4,opt,name=data" json:"data,omitempty"`
-	XXX_unrecognized []byte    `json:"-"`
+	Data *RankUnit `protobuf:"bytes,4,opt,name=data" json:"data,omitempty"`
+	// 排行榜上保存的key是否发生了变化
+	Changed *bool `protobuf:"varint,5,opt,name=changed" json:"changed,omitempty"`
+	// 因为禁止更新失败时, 生效的禁止更新时间段名字
+	NoUpdateWindow *string `protobuf:"bytes,6,opt,name=no_update_window" json:"no_update_window,omitempty"`
+	// 因为禁止更新失败时, 重新开放更新的时间, unix时间戳, 0表示无法确定
+	ReopenTime       *int64 `protobuf:"varint,7,opt,name=reopen_time" json:"reopen_time,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
 }
 
 func (m *UpdateResponse) Reset()                    { *m = UpdateResponse{} }
@@ -459,6 +692,27 @@ func (m *UpdateResponse) GetData() *RankUnit {
 	return nil
 }
 
+func (m *UpdateResponse) GetChanged() bool {
+	if m != nil && m.Changed != nil {
+		return *m.Changed
+	}
+	return false
+}
+
+func (m *UpdateResponse) GetNoUpdateWindow() string {
+	if m != nil && m.NoUpdateWindow != nil {
+		return *m.NoUpdateWindow
+	}
+	return ""
+}
+
+func (m *UpdateResponse) GetReopenTime() int64 {
+	if m != nil && m.ReopenTime != nil {
+		return *m.ReopenTime
+	}
+	return 0
+}
+
 type DeleteRequest struct {
 	// 操作的排行榜ID
 	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
@@ -540,6 +794,1495 @@ func (m *DeleteResponse) GetData() *RankUnit {
 	return nil
 }
 
+type GetAroundRequest struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 查询的数据ID
+	Id *uint64 `protobuf:"varint,2,opt,name=id" json:"id,omitempty"`
+	// 需要返回的排在前面的数据量, 前后的数据量各自不超过服务器上限的一半
+	Before *uint32 `protobuf:"varint,3,opt,name=before" json:"before,omitempty"`
+	// 需要返回的排在后面的数据量, 限制与before相同
+	After            *uint32 `protobuf:"varint,4,opt,name=after" json:"after,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *GetAroundRequest) Reset()                    { *m = GetAroundRequest{} }
+func (m *GetAroundRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetAroundRequest) ProtoMessage()               {}
+func (*GetAroundRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{12} }
+
+func (m *GetAroundRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *GetAroundRequest) GetId() uint64 {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return 0
+}
+
+func (m *GetAroundRequest) GetBefore() uint32 {
+	if m != nil && m.Before != nil {
+		return *m.Before
+	}
+	return 0
+}
+
+func (m *GetAroundRequest) GetAfter() uint32 {
+	if m != nil && m.After != nil {
+		return *m.After
+	}
+	return 0
+}
+
+type GetAroundResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 查询的数据排名, 从0开始, 未上榜时同样为0, 需要根据exist判断
+	Pos *uint32 `protobuf:"varint,2,opt,name=pos" json:"pos,omitempty"`
+	// 第一条数据的排名
+	Start *uint32 `protobuf:"varint,3,opt,name=start" json:"start,omitempty"`
+	// 查询的排行榜数据总量
+	Total *uint32 `protobuf:"varint,4,opt,name=total" json:"total,omitempty"`
+	// 查询的数据, 未上榜时为空
+	Data []*RankUnit `protobuf:"bytes,5,rep,name=data" json:"data,omitempty"`
+	// 每条数据对应的展示名次, 与data一一对应
+	DisplayRank []uint32 `protobuf:"varint,6,rep,name=display_rank" json:"display_rank,omitempty"`
+	// 查询的数据是否在排行榜上
+	Exist            *bool  `protobuf:"varint,7,opt,name=exist" json:"exist,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *GetAroundResponse) Reset()                    { *m = GetAroundResponse{} }
+func (m *GetAroundResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetAroundResponse) ProtoMessage()               {}
+func (*GetAroundResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{13} }
+
+func (m *GetAroundResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *GetAroundResponse) GetPos() uint32 {
+	if m != nil && m.Pos != nil {
+		return *m.Pos
+	}
+	return 0
+}
+
+func (m *GetAroundResponse) GetStart() uint32 {
+	if m != nil && m.Start != nil {
+		return *m.Start
+	}
+	return 0
+}
+
+func (m *GetAroundResponse) GetTotal() uint32 {
+	if m != nil && m.Total != nil {
+		return *m.Total
+	}
+	return 0
+}
+
+func (m *GetAroundResponse) GetData() []*RankUnit {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *GetAroundResponse) GetDisplayRank() []uint32 {
+	if m != nil {
+		return m.DisplayRank
+	}
+	return nil
+}
+
+func (m *GetAroundResponse) GetExist() bool {
+	if m != nil && m.Exist != nil {
+		return *m.Exist
+	}
+	return false
+}
+
+type RankOfKeyRequest struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 查询的key
+	Key              *uint64 `protobuf:"varint,2,opt,name=key" json:"key,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RankOfKeyRequest) Reset()                    { *m = RankOfKeyRequest{} }
+func (m *RankOfKeyRequest) String() string            { return proto.CompactTextString(m) }
+func (*RankOfKeyRequest) ProtoMessage()               {}
+func (*RankOfKeyRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{14} }
+
+func (m *RankOfKeyRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *RankOfKeyRequest) GetKey() uint64 {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return 0
+}
+
+type RankOfKeyResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 查询的key
+	Key *uint64 `protobuf:"varint,2,opt,name=key" json:"key,omitempty"`
+	// 排在key之前的数据量, 即key上榜后能达到的最好排名
+	Pos *uint32 `protobuf:"varint,3,opt,name=pos" json:"pos,omitempty"`
+	// 查询的排行榜数据总量
+	Total            *uint32 `protobuf:"varint,4,opt,name=total" json:"total,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RankOfKeyResponse) Reset()                    { *m = RankOfKeyResponse{} }
+func (m *RankOfKeyResponse) String() string            { return proto.CompactTextString(m) }
+func (*RankOfKeyResponse) ProtoMessage()               {}
+func (*RankOfKeyResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{15} }
+
+func (m *RankOfKeyResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *RankOfKeyResponse) GetKey() uint64 {
+	if m != nil && m.Key != nil {
+		return *m.Key
+	}
+	return 0
+}
+
+func (m *RankOfKeyResponse) GetPos() uint32 {
+	if m != nil && m.Pos != nil {
+		return *m.Pos
+	}
+	return 0
+}
+
+func (m *RankOfKeyResponse) GetTotal() uint32 {
+	if m != nil && m.Total != nil {
+		return *m.Total
+	}
+	return 0
+}
+
+type GetByKeyRangeRequest struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// key的下限, 包含
+	MinKey *uint64 `protobuf:"varint,2,opt,name=min_key" json:"min_key,omitempty"`
+	// key的上限, 包含
+	MaxKey *uint64 `protobuf:"varint,3,opt,name=max_key" json:"max_key,omitempty"`
+	// 最多返回的数据量, 0以及超过服务器上限时按上限返回
+	Limit            *uint32 `protobuf:"varint,4,opt,name=limit" json:"limit,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *GetByKeyRangeRequest) Reset()                    { *m = GetByKeyRangeRequest{} }
+func (m *GetByKeyRangeRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetByKeyRangeRequest) ProtoMessage()               {}
+func (*GetByKeyRangeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *GetByKeyRangeRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *GetByKeyRangeRequest) GetMinKey() uint64 {
+	if m != nil && m.MinKey != nil {
+		return *m.MinKey
+	}
+	return 0
+}
+
+func (m *GetByKeyRangeRequest) GetMaxKey() uint64 {
+	if m != nil && m.MaxKey != nil {
+		return *m.MaxKey
+	}
+	return 0
+}
+
+func (m *GetByKeyRangeRequest) GetLimit() uint32 {
+	if m != nil && m.Limit != nil {
+		return *m.Limit
+	}
+	return 0
+}
+
+type GetByKeyRangeResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 第一条数据的排名
+	Start *uint32 `protobuf:"varint,2,opt,name=start" json:"start,omitempty"`
+	// 查询的排行榜数据总量
+	Total *uint32 `protobuf:"varint,3,opt,name=total" json:"total,omitempty"`
+	// 查询的数据
+	Data []*RankUnit `protobuf:"bytes,4,rep,name=data" json:"data,omitempty"`
+	// 每条数据对应的展示名次, 与data一一对应
+	DisplayRank      []uint32 `protobuf:"varint,5,rep,name=display_rank" json:"display_rank,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *GetByKeyRangeResponse) Reset()                    { *m = GetByKeyRangeResponse{} }
+func (m *GetByKeyRangeResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetByKeyRangeResponse) ProtoMessage()               {}
+func (*GetByKeyRangeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *GetByKeyRangeResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *GetByKeyRangeResponse) GetStart() uint32 {
+	if m != nil && m.Start != nil {
+		return *m.Start
+	}
+	return 0
+}
+
+func (m *GetByKeyRangeResponse) GetTotal() uint32 {
+	if m != nil && m.Total != nil {
+		return *m.Total
+	}
+	return 0
+}
+
+func (m *GetByKeyRangeResponse) GetData() []*RankUnit {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *GetByKeyRangeResponse) GetDisplayRank() []uint32 {
+	if m != nil {
+		return m.DisplayRank
+	}
+	return nil
+}
+
+type BatchUpdateItem struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 上报的数据
+	Data *RankUnit `protobuf:"bytes,2,opt,name=data" json:"data,omitempty"`
+	// AddDelta方式下累加的增量, 可以为负数, 此时忽略data中的key
+	Delta            *int64 `protobuf:"zigzag64,3,opt,name=delta" json:"delta,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *BatchUpdateItem) Reset()                    { *m = BatchUpdateItem{} }
+func (m *BatchUpdateItem) String() string            { return proto.CompactTextString(m) }
+func (*BatchUpdateItem) ProtoMessage()               {}
+func (*BatchUpdateItem) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *BatchUpdateItem) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *BatchUpdateItem) GetData() *RankUnit {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *BatchUpdateItem) GetDelta() int64 {
+	if m != nil && m.Delta != nil {
+		return *m.Delta
+	}
+	return 0
+}
+
+type BatchUpdateRequest struct {
+	// 上报的数据, 同一个排行榜的数据按顺序更新
+	Items []*BatchUpdateItem `protobuf:"bytes,1,rep,name=items" json:"items,omitempty"`
+	// 是否需要返回上报结果
+	Reply *bool `protobuf:"varint,2,opt,name=reply" json:"reply,omitempty"`
+	// 是否需要返回每条数据的上报结果
+	ItemResult *bool `protobuf:"varint,3,opt,name=item_result" json:"item_result,omitempty"`
+	// 是否跳过非上报时段校验
+	BypassNoUpdate *bool `protobuf:"varint,4,opt,name=bypass_no_update" json:"bypass_no_update,omitempty"`
+	// 更新需要满足的服务器时间范围
+	ServerTimeRange *ServerTimeRange `protobuf:"bytes,5,opt,name=server_time_range" json:"server_time_range,omitempty"`
+	// 更新方式, 默认直接覆盖
+	Mode             *UpdateMode `protobuf:"varint,6,opt,name=mode,enum=serverproto.UpdateMode" json:"mode,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *BatchUpdateRequest) Reset()                    { *m = BatchUpdateRequest{} }
+func (m *BatchUpdateRequest) String() string            { return proto.CompactTextString(m) }
+func (*BatchUpdateRequest) ProtoMessage()               {}
+func (*BatchUpdateRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *BatchUpdateRequest) GetItems() []*BatchUpdateItem {
+	if m != nil {
+		return m.Items
+	}
+	return nil
+}
+
+func (m *BatchUpdateRequest) GetReply() bool {
+	if m != nil && m.Reply != nil {
+		return *m.Reply
+	}
+	return false
+}
+
+func (m *BatchUpdateRequest) GetItemResult() bool {
+	if m != nil && m.ItemResult != nil {
+		return *m.ItemResult
+	}
+	return false
+}
+
+func (m *BatchUpdateRequest) GetBypassNoUpdate() bool {
+	if m != nil && m.BypassNoUpdate != nil {
+		return *m.BypassNoUpdate
+	}
+	return false
+}
+
+func (m *BatchUpdateRequest) GetServerTimeRange() *ServerTimeRange {
+	if m != nil {
+		return m.ServerTimeRange
+	}
+	return nil
+}
+
+func (m *BatchUpdateRequest) GetMode() UpdateMode {
+	if m != nil && m.Mode != nil {
+		return *m.Mode
+	}
+	return UpdateMode_Replace
+}
+
+type BatchUpdateResult struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 操作数据的ID
+	Id *uint64 `protobuf:"varint,2,opt,name=id" json:"id,omitempty"`
+	// 错误码, 0表示成功
+	ErrCode *int32 `protobuf:"varint,3,opt,name=err_code" json:"err_code,omitempty"`
+	// 操作后的排名, 0表示未上榜
+	Pos *uint32 `protobuf:"varint,4,opt,name=pos" json:"pos,omitempty"`
+	// 排行榜上保存的key是否发生了变化
+	Changed *bool `protobuf:"varint,5,opt,name=changed" json:"changed,omitempty"`
+	// 因为禁止更新失败时, 生效的禁止更新时间段名字
+	NoUpdateWindow *string `protobuf:"bytes,6,opt,name=no_update_window" json:"no_update_window,omitempty"`
+	// 因为禁止更新失败时, 重新开放更新的时间, unix时间戳, 0表示无法确定
+	ReopenTime       *int64 `protobuf:"varint,7,opt,name=reopen_time" json:"reopen_time,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *BatchUpdateResult) Reset()                    { *m = BatchUpdateResult{} }
+func (m *BatchUpdateResult) String() string            { return proto.CompactTextString(m) }
+func (*BatchUpdateResult) ProtoMessage()               {}
+func (*BatchUpdateResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *BatchUpdateResult) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *BatchUpdateResult) GetId() uint64 {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return 0
+}
+
+func (m *BatchUpdateResult) GetErrCode() int32 {
+	if m != nil && m.ErrCode != nil {
+		return *m.ErrCode
+	}
+	return 0
+}
+
+func (m *BatchUpdateResult) GetPos() uint32 {
+	if m != nil && m.Pos != nil {
+		return *m.Pos
+	}
+	return 0
+}
+
+func (m *BatchUpdateResult) GetChanged() bool {
+	if m != nil && m.Changed != nil {
+		return *m.Changed
+	}
+	return false
+}
+
+func (m *BatchUpdateResult) GetNoUpdateWindow() string {
+	if m != nil && m.NoUpdateWindow != nil {
+		return *m.NoUpdateWindow
+	}
+	return ""
+}
+
+func (m *BatchUpdateResult) GetReopenTime() int64 {
+	if m != nil && m.ReopenTime != nil {
+		return *m.ReopenTime
+	}
+	return 0
+}
+
+type BatchUpdateResponse struct {
+	// key发生了变化的数据量
+	Changed *uint32 `protobuf:"varint,1,opt,name=changed" json:"changed,omitempty"`
+	// 每条数据的上报结果, 与items一一对应, 仅在item_result时返回
+	Results          []*BatchUpdateResult `protobuf:"bytes,2,rep,name=results" json:"results,omitempty"`
+	XXX_unrecognized []byte               `json:"-"`
+}
+
+func (m *BatchUpdateResponse) Reset()                    { *m = BatchUpdateResponse{} }
+func (m *BatchUpdateResponse) String() string            { return proto.CompactTextString(m) }
+func (*BatchUpdateResponse) ProtoMessage()               {}
+func (*BatchUpdateResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *BatchUpdateResponse) GetChanged() uint32 {
+	if m != nil && m.Changed != nil {
+		return *m.Changed
+	}
+	return 0
+}
+
+func (m *BatchUpdateResponse) GetResults() []*BatchUpdateResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type MultiGetRequest struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 查询的数据ID
+	Id               []uint64 `protobuf:"varint,2,rep,name=id" json:"id,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *MultiGetRequest) Reset()                    { *m = MultiGetRequest{} }
+func (m *MultiGetRequest) String() string            { return proto.CompactTextString(m) }
+func (*MultiGetRequest) ProtoMessage()               {}
+func (*MultiGetRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *MultiGetRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *MultiGetRequest) GetId() []uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+type MultiGetResult struct {
+	// 查询的数据ID
+	Id *uint64 `protobuf:"varint,1,opt,name=id" json:"id,omitempty"`
+	// 数据是否在排行榜上
+	Exist *bool `protobuf:"varint,2,opt,name=exist" json:"exist,omitempty"`
+	// 查询的数据排名, 从0开始, 未上榜时同样为0, 需要根据exist判断
+	Pos *uint32 `protobuf:"varint,3,opt,name=pos" json:"pos,omitempty"`
+	// 查询的数据
+	Data *RankUnit `protobuf:"bytes,4,opt,name=data" json:"data,omitempty"`
+	// 按排行榜名次规则计算的展示名次, 从1开始, 0表示未上榜
+	DisplayRank      *uint32 `protobuf:"varint,5,opt,name=display_rank" json:"display_rank,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *MultiGetResult) Reset()                    { *m = MultiGetResult{} }
+func (m *MultiGetResult) String() string            { return proto.CompactTextString(m) }
+func (*MultiGetResult) ProtoMessage()               {}
+func (*MultiGetResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *MultiGetResult) GetId() uint64 {
+	if m != nil && m.Id != nil {
+		return *m.Id
+	}
+	return 0
+}
+
+func (m *MultiGetResult) GetExist() bool {
+	if m != nil && m.Exist != nil {
+		return *m.Exist
+	}
+	return false
+}
+
+func (m *MultiGetResult) GetPos() uint32 {
+	if m != nil && m.Pos != nil {
+		return *m.Pos
+	}
+	return 0
+}
+
+func (m *MultiGetResult) GetData() *RankUnit {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *MultiGetResult) GetDisplayRank() uint32 {
+	if m != nil && m.DisplayRank != nil {
+		return *m.DisplayRank
+	}
+	return 0
+}
+
+type MultiGetResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 每个ID的查询结果, 与请求中的id一一对应
+	Results          []*MultiGetResult `protobuf:"bytes,2,rep,name=results" json:"results,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *MultiGetResponse) Reset()                    { *m = MultiGetResponse{} }
+func (m *MultiGetResponse) String() string            { return proto.CompactTextString(m) }
+func (*MultiGetResponse) ProtoMessage()               {}
+func (*MultiGetResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+
+func (m *MultiGetResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *MultiGetResponse) GetResults() []*MultiGetResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+type SubsetRankRequest struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 需要互相排名的数据ID, 例如好友列表
+	Id               []uint64 `protobuf:"varint,2,rep,name=id" json:"id,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *SubsetRankRequest) Reset()                    { *m = SubsetRankRequest{} }
+func (m *SubsetRankRequest) String() string            { return proto.CompactTextString(m) }
+func (*SubsetRankRequest) ProtoMessage()               {}
+func (*SubsetRankRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
+
+func (m *SubsetRankRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *SubsetRankRequest) GetId() []uint64 {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+type SubsetRankUnit struct {
+	// 数据
+	Data *RankUnit `protobuf:"bytes,1,opt,name=data" json:"data,omitempty"`
+	// 在整个排行榜中的排名
+	Pos *uint32 `protobuf:"varint,2,opt,name=pos" json:"pos,omitempty"`
+	// 在子集中按排行榜名次规则计算的名次, 从1开始
+	RelativeRank     *uint32 `protobuf:"varint,3,opt,name=relative_rank" json:"relative_rank,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *SubsetRankUnit) Reset()                    { *m = SubsetRankUnit{} }
+func (m *SubsetRankUnit) String() string            { return proto.CompactTextString(m) }
+func (*SubsetRankUnit) ProtoMessage()               {}
+func (*SubsetRankUnit) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
+
+func (m *SubsetRankUnit) GetData() *RankUnit {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *SubsetRankUnit) GetPos() uint32 {
+	if m != nil && m.Pos != nil {
+		return *m.Pos
+	}
+	return 0
+}
+
+func (m *SubsetRankUnit) GetRelativeRank() uint32 {
+	if m != nil && m.RelativeRank != nil {
+		return *m.RelativeRank
+	}
+	return 0
+}
+
+type SubsetRankResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 按排行榜中的顺序排列的数据
+	Units []*SubsetRankUnit `protobuf:"bytes,2,rep,name=units" json:"units,omitempty"`
+	// 不在排行榜上的数据ID
+	MissingId        []uint64 `protobuf:"varint,3,rep,name=missing_id" json:"missing_id,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *SubsetRankResponse) Reset()                    { *m = SubsetRankResponse{} }
+func (m *SubsetRankResponse) String() string            { return proto.CompactTextString(m) }
+func (*SubsetRankResponse) ProtoMessage()               {}
+func (*SubsetRankResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
+
+func (m *SubsetRankResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *SubsetRankResponse) GetUnits() []*SubsetRankUnit {
+	if m != nil {
+		return m.Units
+	}
+	return nil
+}
+
+func (m *SubsetRankResponse) GetMissingId() []uint64 {
+	if m != nil {
+		return m.MissingId
+	}
+	return nil
+}
+
+type GetHistoryRangeRequest struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 归档时间, unix时间戳, 为0时按generation查询
+	Time *int64 `protobuf:"varint,2,opt,name=time" json:"time,omitempty"`
+	// 第几个历史排行榜, 0表示最近一次归档的排行榜
+	Generation *uint32 `protobuf:"varint,3,opt,name=generation" json:"generation,omitempty"`
+	// 查询的起始排名
+	Start *uint32 `protobuf:"varint,4,opt,name=start" json:"start,omitempty"`
+	// 查询的数据量, 超过服务器上限时按上限返回
+	Num              *uint32 `protobuf:"varint,5,opt,name=num" json:"num,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *GetHistoryRangeRequest) Reset()                    { *m = GetHistoryRangeRequest{} }
+func (m *GetHistoryRangeRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetHistoryRangeRequest) ProtoMessage()               {}
+func (*GetHistoryRangeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
+
+func (m *GetHistoryRangeRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeRequest) GetTime() int64 {
+	if m != nil && m.Time != nil {
+		return *m.Time
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeRequest) GetGeneration() uint32 {
+	if m != nil && m.Generation != nil {
+		return *m.Generation
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeRequest) GetStart() uint32 {
+	if m != nil && m.Start != nil {
+		return *m.Start
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeRequest) GetNum() uint32 {
+	if m != nil && m.Num != nil {
+		return *m.Num
+	}
+	return 0
+}
+
+type GetHistoryRangeResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 归档时间, 即清空或者被快照覆盖的时间, unix时间戳
+	Time *int64 `protobuf:"varint,2,opt,name=time" json:"time,omitempty"`
+	// 第几个历史排行榜, 0表示最近一次归档的排行榜
+	Generation *uint32 `protobuf:"varint,3,opt,name=generation" json:"generation,omitempty"`
+	// 第一条数据的排名
+	Start *uint32 `protobuf:"varint,4,opt,name=start" json:"start,omitempty"`
+	// 历史排行榜的数据总量
+	Total *uint32 `protobuf:"varint,5,opt,name=total" json:"total,omitempty"`
+	// 查询的数据
+	Data []*RankUnit `protobuf:"bytes,6,rep,name=data" json:"data,omitempty"`
+	// 每条数据对应的展示名次, 与data一一对应
+	DisplayRank []uint32 `protobuf:"varint,7,rep,name=display_rank" json:"display_rank,omitempty"`
+	// 所有历史排行榜的归档时间, 从新到旧排列
+	HistoryTime      []int64 `protobuf:"varint,8,rep,name=history_time" json:"history_time,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *GetHistoryRangeResponse) Reset()                    { *m = GetHistoryRangeResponse{} }
+func (m *GetHistoryRangeResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetHistoryRangeResponse) ProtoMessage()               {}
+func (*GetHistoryRangeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
+
+func (m *GetHistoryRangeResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeResponse) GetTime() int64 {
+	if m != nil && m.Time != nil {
+		return *m.Time
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeResponse) GetGeneration() uint32 {
+	if m != nil && m.Generation != nil {
+		return *m.Generation
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeResponse) GetStart() uint32 {
+	if m != nil && m.Start != nil {
+		return *m.Start
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeResponse) GetTotal() uint32 {
+	if m != nil && m.Total != nil {
+		return *m.Total
+	}
+	return 0
+}
+
+func (m *GetHistoryRangeResponse) GetData() []*RankUnit {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *GetHistoryRangeResponse) GetDisplayRank() []uint32 {
+	if m != nil {
+		return m.DisplayRank
+	}
+	return nil
+}
+
+func (m *GetHistoryRangeResponse) GetHistoryTime() []int64 {
+	if m != nil {
+		return m.HistoryTime
+	}
+	return nil
+}
+
+// 一段明确的时间[begin, end), unix时间戳
+type NoUpdateWindow struct {
+	Name             *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Begin            *int64  `protobuf:"varint,2,opt,name=begin" json:"begin,omitempty"`
+	End              *int64  `protobuf:"varint,3,opt,name=end" json:"end,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *NoUpdateWindow) Reset()                    { *m = NoUpdateWindow{} }
+func (m *NoUpdateWindow) String() string            { return proto.CompactTextString(m) }
+func (*NoUpdateWindow) ProtoMessage()               {}
+func (*NoUpdateWindow) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
+
+func (m *NoUpdateWindow) GetName() string {
+	if m != nil && m.Name != nil {
+		return *m.Name
+	}
+	return ""
+}
+
+func (m *NoUpdateWindow) GetBegin() int64 {
+	if m != nil && m.Begin != nil {
+		return *m.Begin
+	}
+	return 0
+}
+
+func (m *NoUpdateWindow) GetEnd() int64 {
+	if m != nil && m.End != nil {
+		return *m.End
+	}
+	return 0
+}
+
+type GetNoUpdateWindowsRequest struct {
+	// 操作的排行榜ID
+	Rank             *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *GetNoUpdateWindowsRequest) Reset()                    { *m = GetNoUpdateWindowsRequest{} }
+func (m *GetNoUpdateWindowsRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetNoUpdateWindowsRequest) ProtoMessage()               {}
+func (*GetNoUpdateWindowsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *GetNoUpdateWindowsRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+type GetNoUpdateWindowsResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 在周期性的禁止更新时间段之外额外禁止更新的时间段
+	Ranges []*NoUpdateWindow `protobuf:"bytes,2,rep,name=ranges" json:"ranges,omitempty"`
+	// 周期性的禁止更新时间段在这些时间段内不生效
+	Exclusions       []*NoUpdateWindow `protobuf:"bytes,3,rep,name=exclusions" json:"exclusions,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *GetNoUpdateWindowsResponse) Reset()                    { *m = GetNoUpdateWindowsResponse{} }
+func (m *GetNoUpdateWindowsResponse) String() string            { return proto.CompactTextString(m) }
+func (*GetNoUpdateWindowsResponse) ProtoMessage()               {}
+func (*GetNoUpdateWindowsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *GetNoUpdateWindowsResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *GetNoUpdateWindowsResponse) GetRanges() []*NoUpdateWindow {
+	if m != nil {
+		return m.Ranges
+	}
+	return nil
+}
+
+func (m *GetNoUpdateWindowsResponse) GetExclusions() []*NoUpdateWindow {
+	if m != nil {
+		return m.Exclusions
+	}
+	return nil
+}
+
+// 替换排行榜的禁止更新时间段以及排除的时间段, 只能发送到管理端口
+type SetNoUpdateWindowsRequest struct {
+	// 操作的排行榜ID
+	Rank             *uint32           `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	Ranges           []*NoUpdateWindow `protobuf:"bytes,2,rep,name=ranges" json:"ranges,omitempty"`
+	Exclusions       []*NoUpdateWindow `protobuf:"bytes,3,rep,name=exclusions" json:"exclusions,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *SetNoUpdateWindowsRequest) Reset()                    { *m = SetNoUpdateWindowsRequest{} }
+func (m *SetNoUpdateWindowsRequest) String() string            { return proto.CompactTextString(m) }
+func (*SetNoUpdateWindowsRequest) ProtoMessage()               {}
+func (*SetNoUpdateWindowsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *SetNoUpdateWindowsRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *SetNoUpdateWindowsRequest) GetRanges() []*NoUpdateWindow {
+	if m != nil {
+		return m.Ranges
+	}
+	return nil
+}
+
+func (m *SetNoUpdateWindowsRequest) GetExclusions() []*NoUpdateWindow {
+	if m != nil {
+		return m.Exclusions
+	}
+	return nil
+}
+
+type SetNoUpdateWindowsResponse struct {
+	// 操作的排行榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 修改之后的时间段
+	Ranges           []*NoUpdateWindow `protobuf:"bytes,2,rep,name=ranges" json:"ranges,omitempty"`
+	Exclusions       []*NoUpdateWindow `protobuf:"bytes,3,rep,name=exclusions" json:"exclusions,omitempty"`
+	XXX_unrecognized []byte            `json:"-"`
+}
+
+func (m *SetNoUpdateWindowsResponse) Reset()                    { *m = SetNoUpdateWindowsResponse{} }
+func (m *SetNoUpdateWindowsResponse) String() string            { return proto.CompactTextString(m) }
+func (*SetNoUpdateWindowsResponse) ProtoMessage()               {}
+func (*SetNoUpdateWindowsResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
+
+func (m *SetNoUpdateWindowsResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *SetNoUpdateWindowsResponse) GetRanges() []*NoUpdateWindow {
+	if m != nil {
+		return m.Ranges
+	}
+	return nil
+}
+
+func (m *SetNoUpdateWindowsResponse) GetExclusions() []*NoUpdateWindow {
+	if m != nil {
+		return m.Exclusions
+	}
+	return nil
+}
+
+// 运行时创建的排行榜的配置
+type RankConfig struct {
+	// 排行榜引擎类型, 为空时根据redundant_node_num选择redundant或者array
+	Kind *string `protobuf:"bytes,1,opt,name=kind" json:"kind,omitempty"`
+	// 排行榜需要保存排名的数量
+	MaxSize *uint32 `protobuf:"varint,2,opt,name=max_size" json:"max_size,omitempty"`
+	// 需要缓存的暂时不在排行榜上的冗余节点数量
+	RedundantNodeNum *uint32 `protobuf:"varint,3,opt,name=redundant_node_num" json:"redundant_node_num,omitempty"`
+	// 排序方向, 0表示key大的排在前面, 1表示key小的排在前面
+	SortOrder *uint32 `protobuf:"varint,4,opt,name=sort_order" json:"sort_order,omitempty"`
+	// key相同时的排序规则, 0先达到的在前, 1后达到的在前, 2ID小的在前
+	TieBreak *uint32 `protobuf:"varint,5,opt,name=tie_break" json:"tie_break,omitempty"`
+	// 展示名次的计算方式, 0每个位置不同, 1并列跳过, 2并列不跳过
+	RankingMode *uint32 `protobuf:"varint,6,opt,name=ranking_mode" json:"ranking_mode,omitempty"`
+	// 主榜ID, 仅用于快照榜配置
+	PrimaryRank *uint32 `protobuf:"varint,7,opt,name=primary_rank" json:"primary_rank,omitempty"`
+	// 清空, 快照以及禁止更新周期的cron表达式, 为空表示不启用
+	ClearCron    *string `protobuf:"bytes,8,opt,name=clear_cron" json:"clear_cron,omitempty"`
+	SnapshotCron *string `protobuf:"bytes,9,opt,name=snapshot_cron" json:"snapshot_cron,omitempty"`
+	NoUpdateCron *string `protobuf:"bytes,10,opt,name=no_update_cron" json:"no_update_cron,omitempty"`
+	// 每次禁止更新持续的秒数
+	NoUpdateDuration *uint32 `protobuf:"varint,11,opt,name=no_update_duration" json:"no_update_duration,omitempty"`
+	// cron表达式使用的时区, 例如Asia/Shanghai, 为空时使用UTC
+	Location *string `protobuf:"bytes,12,opt,name=location" json:"location,omitempty"`
+	// 清空或者被快照覆盖之前归档的历史排行榜数量
+	HistorySize *uint32 `protobuf:"varint,13,opt,name=history_size" json:"history_size,omitempty"`
+	// 清空或者被快照覆盖之前结算的名次数量
+	SettlementSize   *uint32 `protobuf:"varint,14,opt,name=settlement_size" json:"settlement_size,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RankConfig) Reset()                    { *m = RankConfig{} }
+func (m *RankConfig) String() string            { return proto.CompactTextString(m) }
+func (*RankConfig) ProtoMessage()               {}
+func (*RankConfig) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *RankConfig) GetKind() string {
+	if m != nil && m.Kind != nil {
+		return *m.Kind
+	}
+	return ""
+}
+
+func (m *RankConfig) GetMaxSize() uint32 {
+	if m != nil && m.MaxSize != nil {
+		return *m.MaxSize
+	}
+	return 0
+}
+
+func (m *RankConfig) GetRedundantNodeNum() uint32 {
+	if m != nil && m.RedundantNodeNum != nil {
+		return *m.RedundantNodeNum
+	}
+	return 0
+}
+
+func (m *RankConfig) GetSortOrder() uint32 {
+	if m != nil && m.SortOrder != nil {
+		return *m.SortOrder
+	}
+	return 0
+}
+
+func (m *RankConfig) GetTieBreak() uint32 {
+	if m != nil && m.TieBreak != nil {
+		return *m.TieBreak
+	}
+	return 0
+}
+
+func (m *RankConfig) GetRankingMode() uint32 {
+	if m != nil && m.RankingMode != nil {
+		return *m.RankingMode
+	}
+	return 0
+}
+
+func (m *RankConfig) GetPrimaryRank() uint32 {
+	if m != nil && m.PrimaryRank != nil {
+		return *m.PrimaryRank
+	}
+	return 0
+}
+
+func (m *RankConfig) GetClearCron() string {
+	if m != nil && m.ClearCron != nil {
+		return *m.ClearCron
+	}
+	return ""
+}
+
+func (m *RankConfig) GetSnapshotCron() string {
+	if m != nil && m.SnapshotCron != nil {
+		return *m.SnapshotCron
+	}
+	return ""
+}
+
+func (m *RankConfig) GetNoUpdateCron() string {
+	if m != nil && m.NoUpdateCron != nil {
+		return *m.NoUpdateCron
+	}
+	return ""
+}
+
+func (m *RankConfig) GetNoUpdateDuration() uint32 {
+	if m != nil && m.NoUpdateDuration != nil {
+		return *m.NoUpdateDuration
+	}
+	return 0
+}
+
+func (m *RankConfig) GetLocation() string {
+	if m != nil && m.Location != nil {
+		return *m.Location
+	}
+	return ""
+}
+
+func (m *RankConfig) GetHistorySize() uint32 {
+	if m != nil && m.HistorySize != nil {
+		return *m.HistorySize
+	}
+	return 0
+}
+
+func (m *RankConfig) GetSettlementSize() uint32 {
+	if m != nil && m.SettlementSize != nil {
+		return *m.SettlementSize
+	}
+	return 0
+}
+
+// 管理请求只能发送到管理端口, 否则返回ErrPermissionDenied
+type CreateRankRequest struct {
+	// 排行榜ID, 0表示从动态ID范围中分配
+	Rank             *uint32     `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	Config           *RankConfig `protobuf:"bytes,2,opt,name=config" json:"config,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *CreateRankRequest) Reset()                    { *m = CreateRankRequest{} }
+func (m *CreateRankRequest) String() string            { return proto.CompactTextString(m) }
+func (*CreateRankRequest) ProtoMessage()               {}
+func (*CreateRankRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *CreateRankRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *CreateRankRequest) GetConfig() *RankConfig {
+	if m != nil {
+		return m.Config
+	}
+	return nil
+}
+
+type CreateRankResponse struct {
+	// 创建的排行榜ID
+	Rank             *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *CreateRankResponse) Reset()                    { *m = CreateRankResponse{} }
+func (m *CreateRankResponse) String() string            { return proto.CompactTextString(m) }
+func (*CreateRankResponse) ProtoMessage()               {}
+func (*CreateRankResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *CreateRankResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+// 只能删除运行时创建的排行榜, 删除主榜时同时删除其快照榜
+// 只能发送到管理端口
+type DropRankRequest struct {
+	Rank             *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *DropRankRequest) Reset()                    { *m = DropRankRequest{} }
+func (m *DropRankRequest) String() string            { return proto.CompactTextString(m) }
+func (*DropRankRequest) ProtoMessage()               {}
+func (*DropRankRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+func (m *DropRankRequest) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+type DropRankResponse struct {
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 删除的所有排行榜ID, 包括一起删除的快照榜
+	Dropped          []uint32 `protobuf:"varint,2,rep,name=dropped" json:"dropped,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *DropRankResponse) Reset()                    { *m = DropRankResponse{} }
+func (m *DropRankResponse) String() string            { return proto.CompactTextString(m) }
+func (*DropRankResponse) ProtoMessage()               {}
+func (*DropRankResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
+
+func (m *DropRankResponse) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *DropRankResponse) GetDropped() []uint32 {
+	if m != nil {
+		return m.Dropped
+	}
+	return nil
+}
+
+type ListRanksRequest struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ListRanksRequest) Reset()                    { *m = ListRanksRequest{} }
+func (m *ListRanksRequest) String() string            { return proto.CompactTextString(m) }
+func (*ListRanksRequest) ProtoMessage()               {}
+func (*ListRanksRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+type RankInfo struct {
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 主榜ID, 0表示主榜
+	PrimaryRank *uint32 `protobuf:"varint,2,opt,name=primary_rank" json:"primary_rank,omitempty"`
+	Kind        *string `protobuf:"bytes,3,opt,name=kind" json:"kind,omitempty"`
+	MaxSize     *uint32 `protobuf:"varint,4,opt,name=max_size" json:"max_size,omitempty"`
+	// 是否是运行时创建的排行榜
+	Dynamic          *bool  `protobuf:"varint,5,opt,name=dynamic" json:"dynamic,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *RankInfo) Reset()                    { *m = RankInfo{} }
+func (m *RankInfo) String() string            { return proto.CompactTextString(m) }
+func (*RankInfo) ProtoMessage()               {}
+func (*RankInfo) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *RankInfo) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *RankInfo) GetPrimaryRank() uint32 {
+	if m != nil && m.PrimaryRank != nil {
+		return *m.PrimaryRank
+	}
+	return 0
+}
+
+func (m *RankInfo) GetKind() string {
+	if m != nil && m.Kind != nil {
+		return *m.Kind
+	}
+	return ""
+}
+
+func (m *RankInfo) GetMaxSize() uint32 {
+	if m != nil && m.MaxSize != nil {
+		return *m.MaxSize
+	}
+	return 0
+}
+
+func (m *RankInfo) GetDynamic() bool {
+	if m != nil && m.Dynamic != nil {
+		return *m.Dynamic
+	}
+	return false
+}
+
+type ListRanksResponse struct {
+	// 按ID从小到大排列
+	Ranks            []*RankInfo `protobuf:"bytes,1,rep,name=ranks" json:"ranks,omitempty"`
+	XXX_unrecognized []byte      `json:"-"`
+}
+
+func (m *ListRanksResponse) Reset()                    { *m = ListRanksResponse{} }
+func (m *ListRanksResponse) String() string            { return proto.CompactTextString(m) }
+func (*ListRanksResponse) ProtoMessage()               {}
+func (*ListRanksResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *ListRanksResponse) GetRanks() []*RankInfo {
+	if m != nil {
+		return m.Ranks
+	}
+	return nil
+}
+
+// 保存在DataDir中的运行时创建的排行榜
+type RankRegistry struct {
+	NextDynamicRankId *uint32              `protobuf:"varint,1,opt,name=next_dynamic_rank_id" json:"next_dynamic_rank_id,omitempty"`
+	Ranks             []*CreateRankRequest `protobuf:"bytes,2,rep,name=ranks" json:"ranks,omitempty"`
+	XXX_unrecognized  []byte               `json:"-"`
+}
+
+func (m *RankRegistry) Reset()                    { *m = RankRegistry{} }
+func (m *RankRegistry) String() string            { return proto.CompactTextString(m) }
+func (*RankRegistry) ProtoMessage()               {}
+func (*RankRegistry) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+func (m *RankRegistry) GetNextDynamicRankId() uint32 {
+	if m != nil && m.NextDynamicRankId != nil {
+		return *m.NextDynamicRankId
+	}
+	return 0
+}
+
+func (m *RankRegistry) GetRanks() []*CreateRankRequest {
+	if m != nil {
+		return m.Ranks
+	}
+	return nil
+}
+
+// 重新加载配置文件中的排行榜, 运行时创建的排行榜不受影响
+// 只能发送到管理端口
+type ReloadConfigRequest struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ReloadConfigRequest) Reset()                    { *m = ReloadConfigRequest{} }
+func (m *ReloadConfigRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReloadConfigRequest) ProtoMessage()               {}
+func (*ReloadConfigRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+type ReloadConfigResponse struct {
+	// 配置文件无法加载时的错误, 此时没有做任何修改
+	Error   *string  `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	Added   []uint32 `protobuf:"varint,2,rep,name=added" json:"added,omitempty"`
+	Dropped []uint32 `protobuf:"varint,3,rep,name=dropped" json:"dropped,omitempty"`
+	Updated []uint32 `protobuf:"varint,4,rep,name=updated" json:"updated,omitempty"`
+	// 无法应用的修改, 对应的排行榜保持不变
+	Rejected         []string `protobuf:"bytes,5,rep,name=rejected" json:"rejected,omitempty"`
+	XXX_unrecognized []byte   `json:"-"`
+}
+
+func (m *ReloadConfigResponse) Reset()                    { *m = ReloadConfigResponse{} }
+func (m *ReloadConfigResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReloadConfigResponse) ProtoMessage()               {}
+func (*ReloadConfigResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
+
+func (m *ReloadConfigResponse) GetError() string {
+	if m != nil && m.Error != nil {
+		return *m.Error
+	}
+	return ""
+}
+
+func (m *ReloadConfigResponse) GetAdded() []uint32 {
+	if m != nil {
+		return m.Added
+	}
+	return nil
+}
+
+func (m *ReloadConfigResponse) GetDropped() []uint32 {
+	if m != nil {
+		return m.Dropped
+	}
+	return nil
+}
+
+func (m *ReloadConfigResponse) GetUpdated() []uint32 {
+	if m != nil {
+		return m.Updated
+	}
+	return nil
+}
+
+func (m *ReloadConfigResponse) GetRejected() []string {
+	if m != nil {
+		return m.Rejected
+	}
+	return nil
+}
+
+// 副本连接主节点的server_address之后发送的第一个请求
+type ReplicaSubscribeRequest struct {
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ReplicaSubscribeRequest) Reset()                    { *m = ReplicaSubscribeRequest{} }
+func (m *ReplicaSubscribeRequest) String() string            { return proto.CompactTextString(m) }
+func (*ReplicaSubscribeRequest) ProtoMessage()               {}
+func (*ReplicaSubscribeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+// 主节点当前所有的排行榜, 主榜排在快照榜之前
+// 副本收到之后替换自己的排行榜, 之后是每个RankHandler的ReplicaData
+// 排行榜较多时按排行榜拆分成多个包连续发送
+type ReplicaSubscribeResponse struct {
+	// 主节点接受客户端连接的地址, 副本拒绝修改请求时返回给客户端
+	ClientAddress *string `protobuf:"bytes,1,opt,name=client_address" json:"client_address,omitempty"`
+	// 只包含排行榜结构相关的配置, 副本不执行清空以及快照
+	Ranks []*CreateRankRequest `protobuf:"bytes,2,rep,name=ranks" json:"ranks,omitempty"`
+	// 是否是最后一个包
+	Last             *bool  `protobuf:"varint,3,opt,name=last" json:"last,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ReplicaSubscribeResponse) Reset()                    { *m = ReplicaSubscribeResponse{} }
+func (m *ReplicaSubscribeResponse) String() string            { return proto.CompactTextString(m) }
+func (*ReplicaSubscribeResponse) ProtoMessage()               {}
+func (*ReplicaSubscribeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
+
+func (m *ReplicaSubscribeResponse) GetClientAddress() string {
+	if m != nil && m.ClientAddress != nil {
+		return *m.ClientAddress
+	}
+	return ""
+}
+
+func (m *ReplicaSubscribeResponse) GetRanks() []*CreateRankRequest {
+	if m != nil {
+		return m.Ranks
+	}
+	return nil
+}
+
+func (m *ReplicaSubscribeResponse) GetLast() bool {
+	if m != nil && m.Last != nil {
+		return *m.Last
+	}
+	return false
+}
+
+// 主节点推送给副本的数据, 超过单个包的大小时拆分成多个包连续发送
+type ReplicaData struct {
+	// RankHandler的主榜ID
+	Rank *uint32 `protobuf:"varint,1,opt,name=rank" json:"rank,omitempty"`
+	// 为true时是RankHandler所有排行榜的转储, 否则是一条WAL记录
+	Checkpoint *bool  `protobuf:"varint,2,opt,name=checkpoint" json:"checkpoint,omitempty"`
+	Data       []byte `protobuf:"bytes,3,opt,name=data" json:"data,omitempty"`
+	// 是否是最后一个包
+	Last             *bool  `protobuf:"varint,4,opt,name=last" json:"last,omitempty"`
+	XXX_unrecognized []byte `json:"-"`
+}
+
+func (m *ReplicaData) Reset()                    { *m = ReplicaData{} }
+func (m *ReplicaData) String() string            { return proto.CompactTextString(m) }
+func (*ReplicaData) ProtoMessage()               {}
+func (*ReplicaData) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
+
+func (m *ReplicaData) GetRank() uint32 {
+	if m != nil && m.Rank != nil {
+		return *m.Rank
+	}
+	return 0
+}
+
+func (m *ReplicaData) GetCheckpoint() bool {
+	if m != nil && m.Checkpoint != nil {
+		return *m.Checkpoint
+	}
+	return false
+}
+
+func (m *ReplicaData) GetData() []byte {
+	if m != nil {
+		return m.Data
+	}
+	return nil
+}
+
+func (m *ReplicaData) GetLast() bool {
+	if m != nil && m.Last != nil {
+		return *m.Last
+	}
+	return false
+}
+
+// 副本收到修改请求时返回ErrRedirect以及主节点的地址
+type RedirectResponse struct {
+	// 主节点接受客户端连接的地址, 还没有连接上主节点时为空
+	Address          *string `protobuf:"bytes,1,opt,name=address" json:"address,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
+}
+
+func (m *RedirectResponse) Reset()                    { *m = RedirectResponse{} }
+func (m *RedirectResponse) String() string            { return proto.CompactTextString(m) }
+func (*RedirectResponse) ProtoMessage()               {}
+func (*RedirectResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
+
+func (m *RedirectResponse) GetAddress() string {
+	if m != nil && m.Address != nil {
+		return *m.Address
+	}
+	return ""
+}
+
 func init() {
 	proto.RegisterType((*RankUnit)(nil), "serverproto.RankUnit")
 	proto.RegisterType((*ServerTimeRange)(nil), "serverproto.ServerTimeRange")
@@ -553,40 +2296,185 @@ func init() {
 	proto.RegisterType((*UpdateResponse)(nil), "serverproto.UpdateResponse")
 	proto.RegisterType((*DeleteRequest)(nil), "serverproto.DeleteRequest")
 	proto.RegisterType((*DeleteResponse)(nil), "serverproto.DeleteResponse")
+	proto.RegisterType((*GetAroundRequest)(nil), "serverproto.GetAroundRequest")
+	proto.RegisterType((*GetAroundResponse)(nil), "serverproto.GetAroundResponse")
+	proto.RegisterType((*RankOfKeyRequest)(nil), "serverproto.RankOfKeyRequest")
+	proto.RegisterType((*RankOfKeyResponse)(nil), "serverproto.RankOfKeyResponse")
+	proto.RegisterType((*GetByKeyRangeRequest)(nil), "serverproto.GetByKeyRangeRequest")
+	proto.RegisterType((*GetByKeyRangeResponse)(nil), "serverproto.GetByKeyRangeResponse")
+	proto.RegisterType((*BatchUpdateItem)(nil), "serverproto.BatchUpdateItem")
+	proto.RegisterType((*BatchUpdateRequest)(nil), "serverproto.BatchUpdateRequest")
+	proto.RegisterType((*BatchUpdateResult)(nil), "serverproto.BatchUpdateResult")
+	proto.RegisterType((*BatchUpdateResponse)(nil), "serverproto.BatchUpdateResponse")
+	proto.RegisterType((*MultiGetRequest)(nil), "serverproto.MultiGetRequest")
+	proto.RegisterType((*MultiGetResult)(nil), "serverproto.MultiGetResult")
+	proto.RegisterType((*MultiGetResponse)(nil), "serverproto.MultiGetResponse")
+	proto.RegisterType((*SubsetRankRequest)(nil), "serverproto.SubsetRankRequest")
+	proto.RegisterType((*SubsetRankUnit)(nil), "serverproto.SubsetRankUnit")
+	proto.RegisterType((*SubsetRankResponse)(nil), "serverproto.SubsetRankResponse")
+	proto.RegisterType((*GetHistoryRangeRequest)(nil), "serverproto.GetHistoryRangeRequest")
+	proto.RegisterType((*GetHistoryRangeResponse)(nil), "serverproto.GetHistoryRangeResponse")
+	proto.RegisterType((*NoUpdateWindow)(nil), "serverproto.NoUpdateWindow")
+	proto.RegisterType((*GetNoUpdateWindowsRequest)(nil), "serverproto.GetNoUpdateWindowsRequest")
+	proto.RegisterType((*GetNoUpdateWindowsResponse)(nil), "serverproto.GetNoUpdateWindowsResponse")
+	proto.RegisterType((*SetNoUpdateWindowsRequest)(nil), "serverproto.SetNoUpdateWindowsRequest")
+	proto.RegisterType((*SetNoUpdateWindowsResponse)(nil), "serverproto.SetNoUpdateWindowsResponse")
+	proto.RegisterType((*RankConfig)(nil), "serverproto.RankConfig")
+	proto.RegisterType((*CreateRankRequest)(nil), "serverproto.CreateRankRequest")
+	proto.RegisterType((*CreateRankResponse)(nil), "serverproto.CreateRankResponse")
+	proto.RegisterType((*DropRankRequest)(nil), "serverproto.DropRankRequest")
+	proto.RegisterType((*DropRankResponse)(nil), "serverproto.DropRankResponse")
+	proto.RegisterType((*ListRanksRequest)(nil), "serverproto.ListRanksRequest")
+	proto.RegisterType((*RankInfo)(nil), "serverproto.RankInfo")
+	proto.RegisterType((*ListRanksResponse)(nil), "serverproto.ListRanksResponse")
+	proto.RegisterType((*RankRegistry)(nil), "serverproto.RankRegistry")
+	proto.RegisterType((*ReloadConfigRequest)(nil), "serverproto.ReloadConfigRequest")
+	proto.RegisterType((*ReloadConfigResponse)(nil), "serverproto.ReloadConfigResponse")
+	proto.RegisterType((*ReplicaSubscribeRequest)(nil), "serverproto.ReplicaSubscribeRequest")
+	proto.RegisterType((*ReplicaSubscribeResponse)(nil), "serverproto.ReplicaSubscribeResponse")
+	proto.RegisterType((*ReplicaData)(nil), "serverproto.ReplicaData")
+	proto.RegisterType((*RedirectResponse)(nil), "serverproto.RedirectResponse")
 	proto.RegisterEnum("serverproto.MessageType", MessageType_name, MessageType_value)
+	proto.RegisterEnum("serverproto.UpdateMode", UpdateMode_name, UpdateMode_value)
 }
 
 var fileDescriptor0 = []byte{
-	// 485 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xac, 0x53, 0x4b, 0x6f, 0xd3, 0x40,
-	0x10, 0x56, 0x62, 0x17, 0x85, 0x71, 0x9d, 0x6c, 0xb6, 0x2d, 0x18, 0xc4, 0x01, 0x2d, 0x12, 0x42,
-	0x48, 0xe4, 0xd0, 0x0b, 0x07, 0x6e, 0x08, 0x89, 0x03, 0xaa, 0x0f, 0x6d, 0x72, 0xb6, 0x36, 0xca,
-	0x28, 0xb2, 0xea, 0xda, 0xc6, 0xbb, 0xae, 0xe4, 0x3f, 0xc0, 0x99, 0xf7, 0xe3, 0x97, 0xf0, 0xf7,
-	0xd8, 0x9d, 0xda, 0xa9, 0x1d, 0x9c, 0xd2, 0x03, 0x27, 0x6b, 0x67, 0xe6, 0x7b, 0xcc, 0xc3, 0x00,
-	0x85, 0x4c, 0xcf, 0x67, 0x79, 0x91, 0xe9, 0x8c, 0x7b, 0x0a, 0x8b, 0x4b, 0x2c, 0xe8, 0x21, 0x8e,
-	0x61, 0x74, 0x6a, 0x52, 0x8b, 0x34, 0xd6, 0x1c, 0x60, 0x18, 0xaf, 0x82, 0xc1, 0xe3, 0xc1, 0x33,
-	0x97, 0x7b, 0xe0, 0x9c, 0x63, 0x15, 0x0c, 0xe9, 0xe1, 0xc3, 0xde, 0xa5, 0x4c, 0x4a, 0x0c, 0x1c,
-	0xf3, 0xdc, 0x17, 0x2f, 0x60, 0x72, 0x46, 0x14, 0xf3, 0xf8, 0x02, 0x0d, 0x7a, 0x8d, 0xb6, 0x62,
-	0x89, 0xeb, 0x38, 0x25, 0xb4, 0x63, 0xd1, 0x98, 0xae, 0x08, 0xed, 0x88, 0xa7, 0x00, 0x6f, 0x51,
-	0x9f, 0xe2, 0xfb, 0x12, 0x95, 0xe6, 0xfb, 0xe0, 0x5a, 0x2f, 0x54, 0xe8, 0xd7, 0x92, 0xa4, 0x22,
-	0x4e, 0xc0, 0xa3, 0x3a, 0x95, 0x67, 0xa9, 0xc2, 0xad, 0x42, 0xc3, 0x98, 0x67, 0x8a, 0x2a, 0x7d,
-	0xfe, 0x04, 0xdc, 0x95, 0xd4, 0x92, 0xec, 0x78, 0xc7, 0x47, 0xb3, 0x56, 0x43, 0xb3, 0xa6, 0x1b,
-	0xe3, 0x92, 0x19, 0xba, 0xd7, 0x95, 0x0d, 0xf4, 0x8b, 0xb7, 0x39, 0xc5, 0x19, 0x4c, 0x5b, 0xe5,
-	0xff, 0xc9, 0xc3, 0x2b, 0x98, 0xd8, 0x96, 0xec, 0x88, 0xfa, 0x2d, 0x98, 0xb9, 0x29, 0x2d, 0x0b,
-	0x5d, 0x93, 0x1a, 0x85, 0xb4, 0xbc, 0x20, 0x4e, 0x5f, 0xcc, 0xa9, 0x81, 0x1a, 0xdc, 0x6b, 0xc8,
-	0xa0, 0x75, 0xa6, 0x65, 0xf2, 0x97, 0x25, 0x67, 0xb7, 0xa5, 0xdf, 0x03, 0xf0, 0x17, 0xb9, 0xa9,
-	0xdb, 0xe1, 0xa8, 0x21, 0x19, 0xde, 0xd0, 0x97, 0x15, 0x2e, 0x30, 0x4f, 0x2a, 0x72, 0x3a, 0xe2,
-	0x53, 0xb8, 0x9b, 0x48, 0xa5, 0x23, 0x02, 0xba, 0x14, 0x0a, 0x80, 0x2d, 0xab, 0x5c, 0x2a, 0x15,
-	0xa5, 0x59, 0x54, 0x92, 0x5e, 0xb0, 0x47, 0x99, 0x97, 0x30, 0xbd, 0xe2, 0x8c, 0xb4, 0x39, 0x9f,
-	0xa8, 0xb0, 0xfd, 0x05, 0x77, 0x48, 0xed, 0x51, 0x47, 0x6d, 0xeb, 0xc6, 0xc4, 0x12, 0xc6, 0x8d,
-	0xf1, 0xde, 0x69, 0x30, 0x18, 0x91, 0x8b, 0xeb, 0x1d, 0xd5, 0x0b, 0x73, 0x3a, 0x8d, 0xb9, 0x37,
-	0x2d, 0xec, 0x1d, 0xf8, 0x6f, 0x30, 0xc1, 0x5d, 0xc3, 0x69, 0x9d, 0xeb, 0xbf, 0x67, 0x20, 0x16,
-	0x30, 0x6e, 0xc8, 0x6e, 0x69, 0xf8, 0x36, 0x47, 0xf5, 0xfc, 0xc3, 0x10, 0xbc, 0x13, 0x54, 0x4a,
-	0xae, 0x71, 0x5e, 0xe5, 0xc8, 0x0f, 0x60, 0x6c, 0xbf, 0xd7, 0xff, 0x18, 0xfb, 0x18, 0xf2, 0x43,
-	0x98, 0x6c, 0x82, 0x57, 0xe2, 0xec, 0x53, 0xc8, 0x1f, 0xc0, 0x61, 0x1d, 0xed, 0xfc, 0x17, 0xec,
-	0x73, 0xc8, 0x1f, 0xc2, 0xd1, 0x56, 0xaa, 0x86, 0x7d, 0x09, 0xcd, 0x32, 0x0f, 0x1a, 0xb2, 0xd6,
-	0x29, 0xb3, 0xaf, 0x6d, 0xc2, 0xce, 0x9d, 0xb2, 0x6f, 0x21, 0xbf, 0x07, 0x53, 0x9b, 0xea, 0xdc,
-	0x1a, 0xfb, 0x1e, 0xf2, 0xfb, 0xc0, 0xdb, 0xf1, 0x1a, 0xf0, 0x63, 0x03, 0xe8, 0xcc, 0x9f, 0xfd,
-	0xdc, 0x00, 0xba, 0xa3, 0x64, 0xbf, 0xc2, 0x3f, 0x01, 0x00, 0x00, 0xff, 0xff, 0x98, 0xac, 0xe8,
-	0x60, 0xd5, 0x04, 0x00, 0x00,
+	// 2184 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xcc, 0x58, 0xdd, 0x73, 0xdb, 0xc6,
+	0x11, 0x2f, 0x08, 0x52, 0x22, 0x97, 0x22, 0x75, 0x3a, 0xcb, 0x16, 0xe5, 0x4f, 0x19, 0x69, 0xa6,
+	0x6a, 0xe2, 0xb1, 0x5b, 0xb7, 0x99, 0xa6, 0xd3, 0x97, 0xd8, 0xd6, 0xd4, 0xf6, 0xd8, 0x46, 0x3a,
+	0x50, 0x32, 0xed, 0x4b, 0x87, 0x03, 0x11, 0x2b, 0xe9, 0x22, 0x12, 0x60, 0x0f, 0x47, 0x5b, 0xf2,
+	0x5b, 0x9f, 0xfa, 0xf5, 0xd2, 0xa6, 0x6d, 0xfa, 0xdd, 0x3a, 0x69, 0xd3, 0xef, 0xef, 0xef, 0x7f,
+	0xa1, 0x7f, 0x46, 0x9f, 0xfb, 0x3f, 0x74, 0x3a, 0xf7, 0x01, 0x10, 0x20, 0x40, 0x52, 0x72, 0x32,
+	0x4d, 0x9e, 0x80, 0xdd, 0xdb, 0xbb, 0xdb, 0xdd, 0xbb, 0xfd, 0xed, 0xee, 0x01, 0x70, 0x3f, 0x3c,
+	0xb8, 0x3a, 0xe4, 0x91, 0x88, 0x68, 0x33, 0x46, 0xfe, 0x10, 0xb9, 0x22, 0x9c, 0x9b, 0x50, 0xf7,
+	0xfc, 0xf0, 0xe0, 0xd5, 0x90, 0x09, 0xda, 0x86, 0x0a, 0x0b, 0x3a, 0xd6, 0x86, 0xb5, 0x59, 0xf5,
+	0x2a, 0x2c, 0xa0, 0x04, 0xec, 0x03, 0x3c, 0xea, 0x54, 0x14, 0x43, 0xfe, 0xd2, 0x55, 0xa8, 0x3d,
+	0xf4, 0xfb, 0x23, 0xec, 0xd8, 0x1b, 0xd6, 0xe6, 0x92, 0xa7, 0x09, 0xe7, 0x93, 0xb0, 0xbc, 0xad,
+	0x96, 0x7c, 0x85, 0x0d, 0xd0, 0xf3, 0xc3, 0x3d, 0x94, 0x82, 0x3b, 0xb8, 0xc7, 0x42, 0xb5, 0x9a,
+	0xed, 0x69, 0x42, 0x2e, 0x88, 0x61, 0xa0, 0x16, 0xb4, 0x3d, 0xf9, 0xeb, 0x7c, 0x04, 0xe0, 0x36,
+	0x0a, 0x0f, 0xbf, 0x30, 0xc2, 0x58, 0x50, 0x0a, 0x55, 0xa9, 0xa7, 0x9a, 0xd4, 0xf2, 0xd4, 0xbf,
+	0x51, 0xaa, 0x92, 0x28, 0xe5, 0x7c, 0xd1, 0x82, 0xa6, 0x9a, 0x12, 0x0f, 0xa3, 0x30, 0xc6, 0xd2,
+	0x39, 0x04, 0xec, 0x61, 0x14, 0xab, 0x49, 0x2d, 0x4f, 0xfe, 0xd2, 0x0f, 0x43, 0x35, 0xf0, 0x85,
+	0xaf, 0xf4, 0x6e, 0x5e, 0x3f, 0x7d, 0x35, 0xe3, 0x82, 0xab, 0x89, 0xfd, 0x9e, 0x12, 0xa1, 0x97,
+	0x61, 0x29, 0x60, 0xf1, 0xb0, 0xef, 0x1f, 0x75, 0xd5, 0xc2, 0x55, 0xb5, 0x4a, 0xd3, 0xf0, 0xa4,
+	0xb0, 0xf3, 0x22, 0x90, 0xdb, 0x28, 0x6e, 0x2a, 0x62, 0x96, 0xee, 0x05, 0x3d, 0x9c, 0x2f, 0x59,
+	0xb0, 0x92, 0x99, 0xfa, 0x1e, 0xda, 0xf0, 0x00, 0x96, 0xa5, 0x1b, 0xe5, 0x69, 0xcd, 0x32, 0x61,
+	0x15, 0x6a, 0xb1, 0xf0, 0xb9, 0x30, 0x8a, 0x68, 0x42, 0x2a, 0x17, 0x8e, 0x06, 0x4a, 0x93, 0x96,
+	0x27, 0x7f, 0x9d, 0x27, 0x16, 0x90, 0xf1, 0x7a, 0x33, 0xec, 0x5a, 0x85, 0x9a, 0x88, 0x84, 0xdf,
+	0x4f, 0x16, 0x54, 0x44, 0xc6, 0x36, 0x7b, 0x9e, 0x6d, 0xa9, 0x46, 0xd5, 0xac, 0x46, 0x93, 0x16,
+	0xd7, 0x36, 0xec, 0x49, 0x8b, 0xff, 0x59, 0x81, 0xd6, 0xab, 0xc3, 0xc0, 0x17, 0x33, 0x0d, 0x4e,
+	0x34, 0xa9, 0xcc, 0xf7, 0xf2, 0x2a, 0xd4, 0x38, 0x0e, 0xfb, 0x47, 0xca, 0x0f, 0x75, 0x4f, 0x13,
+	0xf4, 0x1c, 0x34, 0xfa, 0x7e, 0x2c, 0xba, 0x6a, 0x95, 0xaa, 0x1a, 0xa9, 0x4b, 0xc6, 0x96, 0x9c,
+	0xb2, 0x09, 0x64, 0xe7, 0x68, 0xe8, 0xc7, 0x71, 0x37, 0x8c, 0xba, 0x23, 0xa5, 0x4c, 0xa7, 0xa6,
+	0x64, 0xda, 0x9a, 0xef, 0x46, 0x5a, 0x45, 0x7a, 0x07, 0x56, 0xf4, 0xd6, 0x5d, 0xc1, 0x06, 0x28,
+	0x8d, 0xda, 0xc3, 0xce, 0x82, 0x52, 0xea, 0x7c, 0x4e, 0xa9, 0x89, 0xd0, 0xf3, 0x96, 0xe3, 0x3c,
+	0x83, 0x3e, 0x0f, 0xd5, 0x41, 0x14, 0x60, 0x67, 0x71, 0xc3, 0xda, 0x6c, 0x5f, 0x5f, 0xcb, 0x4d,
+	0xd6, 0x9b, 0x3d, 0x88, 0x02, 0xf4, 0x94, 0x90, 0xb4, 0x29, 0xc0, 0xbe, 0xf0, 0x3b, 0xf5, 0x0d,
+	0x6b, 0x93, 0x7a, 0x9a, 0x70, 0xfe, 0x6d, 0x41, 0x3b, 0x71, 0xdd, 0x8c, 0xb3, 0x5d, 0x07, 0x65,
+	0x69, 0x77, 0x7c, 0x71, 0x17, 0x25, 0xfd, 0x99, 0x28, 0x4e, 0xae, 0xb3, 0x5d, 0xbc, 0xce, 0xd5,
+	0xf9, 0x8e, 0xee, 0xc0, 0x62, 0x6f, 0x5f, 0xda, 0x12, 0x18, 0x67, 0x25, 0xa4, 0xf4, 0x67, 0xea,
+	0xc8, 0xee, 0x23, 0x16, 0x06, 0xd1, 0x23, 0xe5, 0xa4, 0x86, 0xd7, 0x0e, 0x8d, 0x27, 0x3f, 0xab,
+	0xb8, 0xf4, 0x12, 0x34, 0x39, 0x46, 0x43, 0x0c, 0x95, 0x3f, 0x95, 0x33, 0x6c, 0x0f, 0x34, 0x4b,
+	0xfa, 0xca, 0xd9, 0x85, 0xd6, 0x16, 0xf6, 0x51, 0xe0, 0x09, 0xd0, 0xe8, 0x29, 0xae, 0x80, 0xf3,
+	0x1a, 0xb4, 0x93, 0x7d, 0x9e, 0xce, 0x95, 0xc7, 0xc7, 0x01, 0x27, 0x50, 0x41, 0x79, 0x83, 0x47,
+	0xa3, 0x30, 0x38, 0x89, 0x59, 0x67, 0x60, 0x61, 0x07, 0x77, 0x23, 0x8e, 0xe6, 0xc0, 0x0c, 0x25,
+	0xcd, 0xf5, 0x77, 0x05, 0xf2, 0x24, 0xf6, 0x14, 0xe1, 0xfc, 0x4b, 0x83, 0x5a, 0xb2, 0xcd, 0x89,
+	0x40, 0x2d, 0x8d, 0x66, 0x3b, 0x1b, 0xcd, 0x29, 0x48, 0x54, 0xcb, 0x40, 0xa2, 0x36, 0x1f, 0x24,
+	0x26, 0xe1, 0x60, 0xa1, 0x00, 0x07, 0x72, 0x0f, 0x3c, 0x64, 0xb1, 0x50, 0x57, 0xa1, 0xee, 0x69,
+	0x42, 0x42, 0xbb, 0x1c, 0x7d, 0x79, 0xf7, 0x1e, 0x1e, 0xcd, 0x81, 0xf6, 0x7c, 0x6e, 0x74, 0x7c,
+	0x58, 0xc9, 0xcc, 0x9c, 0xed, 0x84, 0xfc, 0xd4, 0x92, 0xe0, 0x28, 0x75, 0x80, 0xc3, 0x61, 0x55,
+	0x25, 0x0f, 0xb9, 0xc3, 0x3c, 0xe0, 0x5e, 0x83, 0xc5, 0x01, 0x0b, 0xbb, 0xe3, 0x9d, 0x16, 0x06,
+	0x2c, 0xbc, 0x87, 0x47, 0x6a, 0xc0, 0x3f, 0x54, 0x03, 0xb6, 0x19, 0xf0, 0x0f, 0xef, 0xe9, 0xe4,
+	0xde, 0x67, 0x03, 0x96, 0x02, 0xab, 0x22, 0x9c, 0xb7, 0x2d, 0x38, 0x3d, 0xb1, 0xe9, 0x6c, 0x74,
+	0x2f, 0x49, 0x17, 0xa9, 0x35, 0x76, 0xd9, 0x71, 0x56, 0x4f, 0x7e, 0x9c, 0x25, 0xe8, 0xbe, 0x0b,
+	0xcb, 0x37, 0x7d, 0xd1, 0xdb, 0xd7, 0x41, 0x7f, 0x57, 0xe0, 0xe0, 0x5d, 0x80, 0x77, 0x0d, 0x85,
+	0x76, 0x16, 0x0a, 0x9f, 0x54, 0x80, 0x66, 0x36, 0x4a, 0x8e, 0xe0, 0x3a, 0xd4, 0x98, 0xc0, 0x41,
+	0xdc, 0xb1, 0x36, 0xec, 0x02, 0x44, 0x4f, 0x28, 0xe6, 0x69, 0xd1, 0x31, 0x78, 0x54, 0xb2, 0xe0,
+	0x71, 0x09, 0x9a, 0x72, 0xb8, 0xcb, 0x31, 0x1e, 0xf5, 0x85, 0x01, 0x16, 0x90, 0x2c, 0x4f, 0x71,
+	0x4a, 0x73, 0x48, 0xf5, 0xf8, 0x39, 0xa4, 0xf6, 0x4e, 0x72, 0xc8, 0xc2, 0x31, 0x72, 0x88, 0xc2,
+	0x83, 0x9c, 0x8b, 0x94, 0xda, 0xc7, 0xc1, 0x9d, 0x75, 0xa8, 0x23, 0xe7, 0xdd, 0x9e, 0xdc, 0x4a,
+	0x1a, 0x5e, 0xf3, 0x16, 0x91, 0xf3, 0x5b, 0x32, 0x31, 0x99, 0x18, 0xa9, 0x8e, 0x63, 0xe4, 0xff,
+	0x92, 0x15, 0x18, 0x9c, 0xca, 0x9b, 0xa2, 0xef, 0x7e, 0x66, 0x6f, 0x6d, 0x4f, 0xba, 0xf7, 0x8b,
+	0xb0, 0xa8, 0x4f, 0x4e, 0xc2, 0x9c, 0xbc, 0x0a, 0x17, 0xa7, 0x5d, 0x05, 0xed, 0x17, 0x2f, 0x11,
+	0x77, 0x5e, 0x80, 0xe5, 0x07, 0xa3, 0xbe, 0x60, 0xc7, 0x2c, 0x88, 0x6d, 0x53, 0x10, 0xbf, 0x61,
+	0x41, 0x7b, 0x3c, 0x4f, 0xb9, 0x7a, 0xb2, 0x90, 0x4f, 0xa1, 0xae, 0x92, 0x81, 0xba, 0x77, 0x96,
+	0x92, 0x8b, 0x11, 0x59, 0xa8, 0x30, 0x3f, 0x0f, 0x24, 0xa3, 0xd7, 0x74, 0xcc, 0x78, 0x61, 0xd2,
+	0x63, 0xe7, 0x72, 0x1b, 0xe7, 0x6d, 0x1b, 0xbb, 0xeb, 0x13, 0xb0, 0xb2, 0x3d, 0xda, 0x89, 0x51,
+	0xcc, 0xab, 0xc2, 0x27, 0x1d, 0xc6, 0xa1, 0x3d, 0x9e, 0xa8, 0x1a, 0x9f, 0xc4, 0x6e, 0x6b, 0xbe,
+	0xdd, 0xc5, 0x0c, 0xf6, 0x0c, 0xb4, 0x38, 0xf6, 0x7d, 0xc1, 0x1e, 0xa2, 0x76, 0x85, 0x76, 0xe8,
+	0x52, 0xc2, 0x54, 0xbe, 0x78, 0x0c, 0x34, 0xab, 0xec, 0x0c, 0x6f, 0x7c, 0x14, 0x6a, 0xa3, 0x90,
+	0x4d, 0xf1, 0x45, 0x5e, 0x6f, 0x4f, 0x4b, 0xd2, 0x0b, 0x00, 0x03, 0x16, 0xc7, 0x2c, 0xdc, 0xeb,
+	0xb2, 0x40, 0x95, 0xd0, 0x55, 0xaf, 0x61, 0x38, 0x77, 0x03, 0xe7, 0xcb, 0x16, 0x9c, 0xb9, 0x8d,
+	0xe2, 0x0e, 0x8b, 0x45, 0xc4, 0xe7, 0x27, 0x0e, 0x0a, 0x55, 0x15, 0x0b, 0xba, 0x4b, 0x53, 0xff,
+	0xf4, 0x22, 0xc0, 0x1e, 0x86, 0xc8, 0x7d, 0xc1, 0xa2, 0xd0, 0x18, 0x98, 0xe1, 0x4c, 0xa9, 0xc9,
+	0x4d, 0x97, 0x50, 0x1b, 0x77, 0x09, 0xff, 0xb5, 0x60, 0xad, 0xa0, 0xca, 0x0c, 0x67, 0xbc, 0x7b,
+	0xba, 0xa4, 0x29, 0xa8, 0x56, 0x96, 0x82, 0x16, 0x4e, 0x9e, 0x82, 0x16, 0x8b, 0x15, 0xc5, 0x65,
+	0x58, 0xda, 0xd7, 0x96, 0x69, 0x34, 0xa9, 0x6f, 0xd8, 0x9b, 0xb6, 0xd7, 0x34, 0x3c, 0x05, 0x27,
+	0xf7, 0xa1, 0xed, 0xe6, 0x11, 0x88, 0x42, 0x35, 0xf4, 0x07, 0xa8, 0xcc, 0x6e, 0x78, 0xea, 0x7f,
+	0xdc, 0x3d, 0x57, 0x4a, 0xba, 0x67, 0x7b, 0xdc, 0x3d, 0x5f, 0x83, 0xf5, 0xdb, 0x28, 0xf2, 0x0b,
+	0xc6, 0x33, 0xce, 0xd6, 0x79, 0xcb, 0x82, 0xb3, 0x65, 0x33, 0x66, 0x1c, 0xc1, 0xc7, 0x60, 0x41,
+	0xe5, 0x8d, 0xf2, 0x0b, 0x99, 0x5f, 0xc9, 0x33, 0xa2, 0xf4, 0x53, 0x00, 0x78, 0xd8, 0xeb, 0x8f,
+	0x62, 0x16, 0x85, 0x71, 0xc7, 0x9e, 0x3f, 0x31, 0x23, 0xee, 0xbc, 0x69, 0xc1, 0xfa, 0xf6, 0x49,
+	0xcc, 0x7a, 0x0f, 0x74, 0x94, 0x8e, 0xdc, 0x7e, 0xbf, 0x3b, 0xf2, 0x3f, 0x36, 0x80, 0xbc, 0x98,
+	0xb7, 0xa2, 0x70, 0x97, 0xed, 0x49, 0xa5, 0x0e, 0x58, 0x18, 0x24, 0x37, 0x4d, 0xfe, 0xcb, 0x84,
+	0x2b, 0x8b, 0xc1, 0x98, 0x3d, 0xc6, 0xa4, 0xcd, 0x18, 0xf8, 0x87, 0xdb, 0xec, 0x31, 0xd2, 0x2b,
+	0x40, 0x39, 0x06, 0xa3, 0x30, 0xf0, 0x43, 0xd1, 0x0d, 0xa3, 0x00, 0xbb, 0xe3, 0x96, 0x9f, 0xa4,
+	0x23, 0x6e, 0x14, 0xa0, 0x3b, 0x1a, 0x48, 0x0c, 0x8a, 0x23, 0x2e, 0xba, 0x11, 0x0f, 0xd2, 0xf6,
+	0xa0, 0x21, 0x39, 0x2f, 0x4b, 0x86, 0xec, 0x88, 0x04, 0xc3, 0xee, 0x0e, 0x47, 0x3f, 0xc9, 0x15,
+	0x75, 0xc1, 0xf0, 0xa6, 0xa4, 0x65, 0xdc, 0x48, 0x0f, 0x49, 0xfc, 0x4a, 0x8b, 0x8c, 0x96, 0xd7,
+	0x34, 0x3c, 0x59, 0x58, 0x48, 0x91, 0x21, 0x67, 0x03, 0x9f, 0xa7, 0xd1, 0xa7, 0x44, 0x0c, 0x4f,
+	0x45, 0xdf, 0x05, 0x80, 0x5e, 0x1f, 0x7d, 0xde, 0xed, 0xf1, 0x28, 0x54, 0xed, 0x6b, 0xc3, 0x6b,
+	0x28, 0xce, 0x2d, 0x1e, 0x85, 0x12, 0xa6, 0xe3, 0xd0, 0x1f, 0xc6, 0xfb, 0x91, 0xd0, 0x12, 0x0d,
+	0x25, 0xb1, 0x94, 0x30, 0x95, 0xd0, 0x07, 0xa1, 0x3d, 0x2e, 0x1c, 0x94, 0x14, 0x68, 0xa9, 0xa4,
+	0x6c, 0x50, 0x52, 0x57, 0x80, 0x8e, 0xa5, 0x82, 0x91, 0x41, 0xa2, 0xa6, 0xf6, 0x4c, 0x22, 0xb9,
+	0x65, 0xf8, 0xf4, 0x2c, 0xd4, 0xfb, 0x51, 0x4f, 0xcb, 0x2c, 0xa9, 0xd5, 0x52, 0x3a, 0x8b, 0x18,
+	0xea, 0x08, 0x5a, 0xda, 0x2c, 0xc3, 0x53, 0xc7, 0xf0, 0x21, 0x58, 0x8e, 0x51, 0x88, 0x3e, 0x0e,
+	0x30, 0x14, 0x5a, 0xaa, 0xad, 0xa4, 0xda, 0x63, 0xb6, 0x14, 0x74, 0x3e, 0x07, 0x2b, 0xb7, 0x38,
+	0xfa, 0x02, 0xe7, 0xe5, 0xc3, 0x6b, 0xb0, 0xd0, 0x53, 0x37, 0xc2, 0x14, 0xc1, 0x6b, 0x05, 0xd8,
+	0xd3, 0x17, 0xc6, 0x33, 0x62, 0xce, 0x26, 0xd0, 0xec, 0xca, 0xd3, 0xef, 0xb8, 0xf3, 0x2c, 0x2c,
+	0x6f, 0xf1, 0x68, 0x38, 0x47, 0x03, 0xe7, 0x25, 0x20, 0x63, 0xb1, 0x19, 0x21, 0xd3, 0x81, 0xc5,
+	0x80, 0x47, 0xc3, 0x21, 0xea, 0xf4, 0xdd, 0xf2, 0x12, 0xd2, 0xa1, 0x40, 0xee, 0xb3, 0x58, 0x65,
+	0xc2, 0x04, 0x19, 0x9c, 0xaf, 0x5a, 0xfa, 0x2d, 0xf3, 0x6e, 0xb8, 0x1b, 0x95, 0x2e, 0x37, 0x79,
+	0x89, 0x2a, 0xc5, 0x4b, 0x94, 0xc4, 0x88, 0x3d, 0x25, 0x46, 0xaa, 0xf9, 0x18, 0x91, 0x0a, 0x1e,
+	0x85, 0xfe, 0x80, 0xf5, 0x92, 0x12, 0xd4, 0x90, 0xce, 0x4b, 0xb0, 0x92, 0x51, 0xd0, 0xd8, 0xf8,
+	0x3c, 0xd4, 0xe4, 0xc6, 0x49, 0x93, 0x50, 0xcc, 0x37, 0x52, 0x75, 0x4f, 0xcb, 0x38, 0x23, 0x58,
+	0xd2, 0x0e, 0xda, 0x63, 0xb1, 0xe0, 0x47, 0xf4, 0x1a, 0xac, 0x86, 0x78, 0x28, 0xba, 0x66, 0x07,
+	0x65, 0x42, 0x97, 0x25, 0xf5, 0xe7, 0x8a, 0x1c, 0xdb, 0xd2, 0x43, 0x6a, 0xa5, 0x80, 0x7e, 0x3c,
+	0xd9, 0xad, 0xac, 0x0e, 0x2d, 0x5c, 0x95, 0x64, 0xdb, 0xd3, 0x70, 0xca, 0xc3, 0x7e, 0xe4, 0x07,
+	0xe6, 0x12, 0x18, 0xe7, 0xbe, 0x6e, 0xc1, 0x6a, 0x9e, 0x6f, 0x6c, 0x92, 0xb5, 0x25, 0xe7, 0x11,
+	0x37, 0xb0, 0xa2, 0x09, 0xc9, 0xf5, 0x83, 0x20, 0x3d, 0x37, 0x4d, 0x64, 0xcf, 0xd3, 0xce, 0x9d,
+	0xa7, 0x1c, 0xd1, 0xf1, 0x14, 0xa8, 0x76, 0xb0, 0xe5, 0x25, 0xa4, 0x0c, 0x1f, 0x8e, 0xaf, 0x61,
+	0x4f, 0xa8, 0x32, 0xdf, 0x96, 0xe1, 0x93, 0xd0, 0xce, 0x3a, 0xac, 0x79, 0x38, 0xec, 0xb3, 0x9e,
+	0x2f, 0x0b, 0xa3, 0x1e, 0x67, 0x3b, 0x49, 0x65, 0xe3, 0x7c, 0xcd, 0x82, 0x4e, 0x71, 0xcc, 0xe8,
+	0xfc, 0x2c, 0xb4, 0x7b, 0x7d, 0x26, 0xe3, 0xc9, 0x0f, 0x02, 0x8e, 0x71, 0x6c, 0x94, 0x6f, 0x69,
+	0xee, 0x0d, 0xcd, 0x7c, 0x3a, 0x07, 0xca, 0x2b, 0x24, 0x5f, 0x6a, 0x4c, 0xe3, 0xa6, 0xfe, 0x1d,
+	0x06, 0x4d, 0xa3, 0x8c, 0x7a, 0x05, 0x2c, 0xbb, 0x9c, 0x17, 0x01, 0x7a, 0xfb, 0xd8, 0x3b, 0x18,
+	0x46, 0x2c, 0x4c, 0x0a, 0xf5, 0x0c, 0x47, 0xce, 0x49, 0x5f, 0x7d, 0x96, 0x4c, 0x4d, 0x92, 0x6c,
+	0x55, 0xcd, 0x6c, 0x75, 0x05, 0x88, 0x87, 0x01, 0xe3, 0xd8, 0x13, 0xd9, 0x6e, 0x25, 0x6f, 0x68,
+	0x42, 0x3e, 0xf7, 0x95, 0x06, 0x34, 0x1f, 0x60, 0x1c, 0xfb, 0x7b, 0xf8, 0xca, 0xd1, 0x10, 0xe9,
+	0x29, 0x68, 0xcb, 0xef, 0xb8, 0x05, 0x21, 0x5f, 0x77, 0xe9, 0x2a, 0x2c, 0xa7, 0x4c, 0xbd, 0x22,
+	0xf9, 0x86, 0x4b, 0xd7, 0x61, 0xd5, 0x70, 0x73, 0x0f, 0xe1, 0xe4, 0x75, 0x97, 0x9e, 0x85, 0xd3,
+	0x13, 0x43, 0x66, 0xda, 0x37, 0x5d, 0xda, 0x81, 0x53, 0xc9, 0x62, 0x99, 0x4a, 0x94, 0x7c, 0x2b,
+	0xbb, 0x60, 0xae, 0x30, 0x24, 0xdf, 0x76, 0xe9, 0x19, 0x58, 0x91, 0x43, 0xb9, 0x96, 0x9b, 0xbc,
+	0xe1, 0xd2, 0x35, 0xa0, 0x59, 0xbe, 0x99, 0xf0, 0x9d, 0x74, 0x42, 0xee, 0x41, 0x8f, 0x7c, 0x37,
+	0x9d, 0x90, 0x7f, 0x80, 0x23, 0xdf, 0xcb, 0x6e, 0x9e, 0x7b, 0x2d, 0x23, 0xdf, 0xcf, 0x5a, 0x93,
+	0x7f, 0xe1, 0x22, 0x3f, 0x48, 0xa7, 0x4d, 0x3e, 0x19, 0x91, 0x1f, 0xa6, 0xd3, 0x0a, 0x6f, 0x42,
+	0xe4, 0x47, 0x2e, 0xbd, 0x00, 0x9d, 0xd4, 0x41, 0x13, 0x8f, 0x39, 0xe4, 0xc7, 0x2e, 0xbd, 0x08,
+	0xeb, 0x25, 0xc3, 0x66, 0xfa, 0x4f, 0x5c, 0x7a, 0x0e, 0xce, 0xc8, 0xf1, 0xe2, 0x33, 0x04, 0x79,
+	0xe2, 0xd2, 0xf3, 0xb0, 0x56, 0x18, 0x34, 0x53, 0xdf, 0x4c, 0xdd, 0x3f, 0xd1, 0x68, 0x92, 0xb7,
+	0x52, 0x53, 0x26, 0x5b, 0x36, 0xf2, 0xd3, 0xd4, 0x94, 0x42, 0xbb, 0x45, 0x7e, 0x96, 0xea, 0x52,
+	0xec, 0x6e, 0xc8, 0xdb, 0x2e, 0xbd, 0x04, 0x67, 0x8d, 0x21, 0x25, 0xdd, 0x07, 0xf9, 0xb9, 0x4b,
+	0x37, 0xe0, 0x5c, 0xa9, 0x80, 0x59, 0xe2, 0x17, 0x2e, 0x75, 0xe0, 0x82, 0x91, 0x28, 0x2f, 0x08,
+	0xc9, 0x2f, 0x5d, 0xfa, 0x0c, 0x5c, 0x9c, 0x26, 0x63, 0x16, 0xfa, 0x55, 0xba, 0xd0, 0xd4, 0xca,
+	0x92, 0xfc, 0x3a, 0x5d, 0x68, 0x7a, 0x65, 0x47, 0x7e, 0x93, 0x7a, 0xa3, 0x00, 0x00, 0xe4, 0xb7,
+	0xa9, 0x37, 0x8a, 0xe9, 0x92, 0xfc, 0x2e, 0xf5, 0xfd, 0x44, 0x86, 0x24, 0xbf, 0x4f, 0x7d, 0x3f,
+	0x99, 0x14, 0xc9, 0x1f, 0xd2, 0xa1, 0xc9, 0x6c, 0x47, 0xfe, 0x98, 0x2a, 0x52, 0xc8, 0x33, 0xe4,
+	0x4f, 0xe9, 0x2d, 0x28, 0x81, 0x72, 0xf2, 0xe7, 0xf4, 0xfe, 0x95, 0x01, 0x3a, 0xf9, 0x4b, 0x7a,
+	0x2a, 0x53, 0xb0, 0x95, 0xfc, 0xd5, 0xa5, 0x97, 0xe1, 0x7c, 0xb9, 0x84, 0x59, 0xe4, 0x6f, 0x29,
+	0x6a, 0x64, 0x70, 0x8f, 0xfc, 0x7d, 0x1c, 0x30, 0x13, 0x10, 0x45, 0xfe, 0xe1, 0x3e, 0xf7, 0x69,
+	0x80, 0xf1, 0x53, 0x12, 0x6d, 0xc2, 0xa2, 0x9c, 0xea, 0xf7, 0x90, 0x7c, 0x80, 0xb6, 0x01, 0xee,
+	0x21, 0x0e, 0xef, 0xb0, 0xbd, 0x7d, 0xe4, 0xc4, 0xa2, 0x2d, 0x68, 0x48, 0xfa, 0x7e, 0xf4, 0x08,
+	0x39, 0xa9, 0xd0, 0x25, 0xa8, 0xdf, 0x08, 0x82, 0x2d, 0xec, 0x0b, 0x9f, 0xd8, 0xff, 0x1b, 0x00,
+	0x9d, 0x32, 0xe6, 0x3b, 0xec, 0x1c, 0x00, 0x00,
 }